@@ -0,0 +1,47 @@
+// KanowinsBackfillTTL sets a TTL on the WINs missing one, counted from each
+// WIN's CreatedAt. Check first with -dry-run:
+//
+//	REGION=us-west-1 TABLE_NAME=kanome-kanowins-db-test go run cmd/KanowinsBackfillTTL/main.go -dry-run
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+func main() {
+	batch := flag.Int("batch", 25, "number of WINs to update between progress reports")
+	dryRun := flag.Bool("dry-run", false, "only count the WINs missing a TTL")
+	flag.Parse()
+	if *batch <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	kanowins.MustEnv("REGION")
+	kanowins.MustEnv("TABLE_NAME")
+	db, err := kanowins.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "KanowinsBackfillTTL - NewClient error: %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun {
+		wins, err := db.Get()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "KanowinsBackfillTTL - Get error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("KanowinsBackfillTTL - %d of %d WINs in %s are missing a TTL\n", len(kanowins.MissingTTL(wins)), len(wins), os.Getenv("TABLE_NAME"))
+		return
+	}
+	set, err := db.BackfillTTL(*batch, func(done, total int) {
+		fmt.Printf("KanowinsBackfillTTL - processed %d of %d\n", done, total)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "KanowinsBackfillTTL - BackfillTTL error after %d: %v\n", set, err)
+		os.Exit(1)
+	}
+	fmt.Printf("KanowinsBackfillTTL - set a TTL on %d WINs in %s\n", set, os.Getenv("TABLE_NAME"))
+}
@@ -0,0 +1,62 @@
+// KanowinsSeed bulk-inserts synthetic WINs for load and pagination testing,
+// run it against a test stage's table, never production:
+//
+//	REGION=us-west-1 TABLE_NAME=kanome-kanowins-db-test go run cmd/KanowinsSeed/main.go -n 500 -team T012AB3C4
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+var (
+	titles = []string{"Shipped the release", "Closed a big deal", "Fixed the flaky build", "Ran a great workshop", "Onboarded a new hire"}
+	people = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank"}
+)
+
+// seedWins generates n synthetic WINs for teamID spread over the last week
+func seedWins(n int, teamID string, now time.Time) []kanowins.Win {
+	picker := rand.New(rand.NewSource(now.UnixNano()))
+	wins := make([]kanowins.Win, 0, n)
+	for i := 0; i < n; i++ {
+		p := picker.Intn(len(people))
+		person := people[p]
+		wins = append(wins, kanowins.Win{
+			UserID:      fmt.Sprintf("USEED%03d", p),
+			UserName:    "seed-" + person,
+			TeamID:      teamID,
+			Who:         person,
+			Title:       titles[picker.Intn(len(titles))],
+			Description: fmt.Sprintf("Synthetic WIN %d of %d", i+1, n),
+			CreatedAt:   now.Add(-time.Duration(picker.Int63n(int64(7 * 24 * time.Hour)))),
+		})
+	}
+	return wins
+}
+
+func main() {
+	n := flag.Int("n", 100, "number of WINs to generate")
+	teamID := flag.String("team", "", "team ID to generate WINs for")
+	flag.Parse()
+	if *n <= 0 || *teamID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	kanowins.MustEnv("REGION")
+	kanowins.MustEnv("TABLE_NAME")
+	db, err := kanowins.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "KanowinsSeed - NewClient error: %v\n", err)
+		os.Exit(1)
+	}
+	if err = db.BatchPut(seedWins(*n, *teamID, time.Now())); err != nil {
+		fmt.Fprintf(os.Stderr, "KanowinsSeed - BatchPut error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("KanowinsSeed - wrote %d WINs for %s to %s\n", *n, *teamID, os.Getenv("TABLE_NAME"))
+}
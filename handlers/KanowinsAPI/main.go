@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/apperror"
+	"github.com/anzellai/kanowins/internal/gateway"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+const handler = "KanowinsAPI"
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest is the API Gateway request, decoded from either REST API or
+// HTTP API payloads
+type ProxyRequest = gateway.Request
+
+// authorized reports whether the request carries the DASHBOARD_API_KEY in
+// its X-Api-Key header
+func authorized(r ProxyRequest) bool {
+	key := r.Header("X-Api-Key")
+	return key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(os.Getenv("DASHBOARD_API_KEY"))) == 1
+}
+
+// selectWins returns the current WINS visible to audience, newest first,
+// restricted to teamID and userID when they are set and capped at limit when
+// it is positive. Anonymous WINS have their submitter removed and never
+// match a userID
+func selectWins(wins []kanowins.Win, audience, teamID, userID string, limit int, now time.Time) []kanowins.Win {
+	selected := []kanowins.Win{}
+	for _, win := range wins {
+		if win.Expired(now) || win.Archived || win.Hidden(now) || (userID != "" && (win.UserID != userID || win.Anonymous)) {
+			continue
+		}
+		if !win.VisibleTo(audience) || (teamID != "" && win.TeamID != teamID) {
+			continue
+		}
+		if win.Anonymous {
+			win.UserID = ""
+			win.UserName = win.Submitter()
+		}
+		selected = append(selected, win)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].CreatedAt.After(selected[j].CreatedAt)
+	})
+	if limit > 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+// epochWin is a WIN as served with `?time=epoch`, its timestamps as Unix
+// seconds, the shallower CreatedAt and UpdatedAt shadow the embedded Win's
+// when marshalled
+type epochWin struct {
+	kanowins.Win
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// renderWins returns wins for marshalling in timeFormat, RFC 3339 strings
+// by default or Unix seconds for `epoch`
+func renderWins(wins []kanowins.Win, timeFormat string) interface{} {
+	if timeFormat != "epoch" {
+		return wins
+	}
+	rendered := []epochWin{}
+	for _, win := range wins {
+		rendered = append(rendered, epochWin{Win: win, CreatedAt: win.CreatedAt.Unix(), UpdatedAt: win.UpdatedAt.Unix()})
+	}
+	return rendered
+}
+
+// renderer renders the selected WINS as a response body of one content type
+type renderer func(wins []kanowins.Win, timeFormat string) (string, error)
+
+// renderers maps each content type the API serves to its renderer, JSON
+// being the default
+var renderers = map[string]renderer{
+	"application/json": renderJSON,
+	"text/csv":         renderCSV,
+	"text/html":        renderHTML,
+}
+
+// negotiate picks the content type for the Accept header, JSON for an empty
+// header or a wildcard, and false when none is served
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return "application/json", true
+	}
+	for _, value := range strings.Split(accept, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(value, ";", 2)[0]))
+		if mediaType == "*/*" || mediaType == "application/*" {
+			return "application/json", true
+		}
+		if _, ok := renderers[mediaType]; ok {
+			return mediaType, true
+		}
+	}
+	return "", false
+}
+
+// renderJSON renders wins as a JSON array with timestamps in timeFormat
+func renderJSON(wins []kanowins.Win, timeFormat string) (string, error) {
+	body, err := json.Marshal(renderWins(wins, timeFormat))
+	return string(body), err
+}
+
+// renderCSV renders wins as the same CSV as `/wins export`
+func renderCSV(wins []kanowins.Win, timeFormat string) (string, error) {
+	body, err := kanowins.CSV(wins)
+	return string(body), err
+}
+
+// renderHTML renders wins as a minimal HTML table, every field escaped
+func renderHTML(wins []kanowins.Win, timeFormat string) (string, error) {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<table>\n<tr><th>Created</th><th>Who</th><th>Title</th><th>Description</th><th>Category</th><th>Kudos</th></tr>\n")
+	for _, win := range wins {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%d</td></tr>\n",
+			win.CreatedAt.Format(time.RFC3339),
+			html.EscapeString(win.Who),
+			html.EscapeString(win.Title),
+			html.EscapeString(win.Description),
+			html.EscapeString(win.Category),
+			win.Kudos,
+		)
+	}
+	b.WriteString("</table>\n")
+	return b.String(), nil
+}
+
+// Handler is our lambda handler serving `GET /wins`, or an HTML summary for
+// a `?token=` link from `/wins summary --link`
+func Handler(ctx context.Context, r ProxyRequest) (resp Response, err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		alert.Notify(ctx, handler, apperror.ErrInternal)
+		resp, err = errorResponse(apperror.ErrInternal), nil
+	})
+	logging.Printf("Handler - invoke: %s %s %v", r.HTTPMethod, r.Path, r.QueryStringParameters)
+	teamID := ""
+	if token := r.QueryStringParameters["token"]; token != "" {
+		var err error
+		if teamID, err = kanowins.VerifyLink(token, time.Now()); err != nil {
+			logging.Printf("Handler - summary link error: %v", err)
+			return errorResponse(apperror.Wrap(apperror.ErrForbidden, err)), nil
+		}
+	} else if !authorized(r) {
+		return errorResponse(apperror.ErrInvalidToken), nil
+	}
+	limit := 0
+	if value := r.QueryStringParameters["limit"]; value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return response(400, fmt.Sprintf(`{"error":"invalid limit %q"}`, value)), nil
+		}
+	}
+	timeFormat := strings.ToLower(r.QueryStringParameters["time"])
+	if timeFormat != "" && timeFormat != "epoch" && timeFormat != "rfc3339" {
+		return response(400, fmt.Sprintf(`{"error":"invalid time %q, use epoch or rfc3339"}`, timeFormat)), nil
+	}
+	contentType, ok := negotiate(r.Header("Accept"))
+	audience := kanowins.VisibilityTeam
+	if teamID != "" {
+		// a summary link is opened in a browser, by people outside the team
+		contentType, ok = "text/html", true
+		audience = kanowins.VisibilityPublic
+	}
+	if !ok {
+		return response(406, `{"error":"not acceptable, use application/json, text/csv or text/html"}`), nil
+	}
+	db, err := kanowins.NewClient()
+	if err != nil {
+		logging.Printf("Handler - NewClient error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return errorResponse(apperror.Wrap(apperror.ErrStorage, err)), nil
+	}
+	userID := r.QueryStringParameters["user_id"]
+	var wins []kanowins.Win
+	if userID != "" {
+		wins, err = db.GetByUser(userID)
+	} else {
+		wins, err = db.Get()
+	}
+	if err != nil {
+		logging.Printf("Handler - get WINS error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return errorResponse(apperror.Wrap(apperror.ErrStorage, err)), nil
+	}
+	body, err := renderers[contentType](selectWins(wins, audience, teamID, userID, limit, time.Now()), timeFormat)
+	if err != nil {
+		logging.Printf("Handler - render WINS as %s error: %v", contentType, err)
+		return errorResponse(err), nil
+	}
+	resp = response(200, body)
+	resp.Headers["Content-Type"] = contentType
+	resp.Headers["Vary"] = "Accept"
+	return compress(r, resp), nil
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding lists gzip
+func acceptsGzip(r ProxyRequest) bool {
+	for _, encoding := range strings.Split(r.Header("Accept-Encoding"), ",") {
+		if strings.EqualFold(strings.TrimSpace(strings.SplitN(encoding, ";", 2)[0]), "gzip") {
+			return true
+		}
+	}
+	return false
+}
+
+// compress gzips resp's body when the client accepts it, base64 encoded as
+// API Gateway requires for a binary body, resp is returned as is otherwise
+func compress(r ProxyRequest, resp Response) Response {
+	if !acceptsGzip(r) {
+		return resp
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(resp.Body)); err != nil {
+		logging.Printf("compress - gzip error: %v", err)
+		return resp
+	}
+	if err := writer.Close(); err != nil {
+		logging.Printf("compress - gzip error: %v", err)
+		return resp
+	}
+	resp.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	resp.IsBase64Encoded = true
+	resp.Headers["Content-Encoding"] = "gzip"
+	resp.Headers["Vary"] = strings.TrimPrefix(resp.Headers["Vary"]+", Accept-Encoding", ", ")
+	return resp
+}
+
+// errorResponse responds with err's status and user facing message as JSON,
+// the internal detail is left to the caller's log line
+func errorResponse(err error) Response {
+	status, message := apperror.Public(err)
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return response(status, string(body))
+}
+
+// response builds a JSON proxy response with the given status and body,
+// allowing the dashboard origin from CORS_ALLOW_ORIGIN (any origin when unset)
+func response(statusCode int, body string) Response {
+	origin := os.Getenv("CORS_ALLOW_ORIGIN")
+	if origin == "" {
+		origin = "*"
+	}
+	return Response{
+		StatusCode:      statusCode,
+		IsBase64Encoded: false,
+		Body:            body,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  origin,
+			"Access-Control-Allow-Headers": "X-Api-Key",
+		},
+	}
+}
+
+// requiredEnv are the environment variables KanowinsAPI can't run without
+var requiredEnv = []string{"REGION", "TABLE_NAME", "DASHBOARD_API_KEY"}
+
+func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
+	lambda.Start(Handler)
+}
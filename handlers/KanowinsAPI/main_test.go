@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+// fakeTable points the DynamoDB client at a server scanning to wins
+func fakeTable(t *testing.T, wins ...kanowins.Win) {
+	t.Helper()
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, win := range wins {
+		item, err := dynamodbattribute.MarshalMap(win)
+		if err != nil {
+			t.Fatalf("MarshalMap error: %v", err)
+		}
+		items = append(items, item)
+	}
+	reply, err := jsonutil.BuildJSON(&dynamodb.ScanOutput{Items: items})
+	if err != nil {
+		t.Fatalf("BuildJSON error: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write(reply)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DYNAMODB_ENDPOINT", server.URL)
+	t.Setenv("REGION", "us-west-1")
+	t.Setenv("TABLE_NAME", "wins")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+}
+
+// gunzip decodes a compressed response body
+func gunzip(t *testing.T, resp Response) string {
+	t.Helper()
+	compressed, err := base64.StdEncoding.DecodeString(resp.Body)
+	if err != nil {
+		t.Fatalf("decoding base64 body: %v", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("gzip.NewReader error: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading gzip body: %v", err)
+	}
+	return string(body)
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		encoding string
+		want     bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, GZIP;q=0.8", true},
+		{"br, deflate", false},
+		{"x-gzip", false},
+	}
+	for _, tt := range tests {
+		r := ProxyRequest{Headers: map[string]string{"accept-encoding": tt.encoding}}
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %t, want %t", tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerCompression(t *testing.T) {
+	fakeTable(t, kanowins.Win{WinID: "1", TeamID: "T1", Who: "Bob", Title: "Shipped the release", CreatedAt: time.Now().Add(-time.Hour)})
+	t.Setenv("DASHBOARD_API_KEY", "key")
+	plain, err := Handler(context.Background(), ProxyRequest{Headers: map[string]string{"X-Api-Key": "key"}})
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if plain.IsBase64Encoded || plain.Headers["Content-Encoding"] != "" || !strings.Contains(plain.Body, "Shipped the release") {
+		t.Fatalf("uncompressed response = %+v, want the plain JSON", plain)
+	}
+	resp, err := Handler(context.Background(), ProxyRequest{Headers: map[string]string{"X-Api-Key": "key", "Accept-Encoding": "gzip, deflate"}})
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if !resp.IsBase64Encoded || resp.Headers["Content-Encoding"] != "gzip" {
+		t.Errorf("response IsBase64Encoded %t, Content-Encoding %q, want a base64 gzip body", resp.IsBase64Encoded, resp.Headers["Content-Encoding"])
+	}
+	if resp.Headers["Vary"] != "Accept, Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept, Accept-Encoding", resp.Headers["Vary"])
+	}
+	if body := gunzip(t, resp); body != plain.Body {
+		t.Errorf("decompressed body = %s, want %s", body, plain.Body)
+	}
+}
+
+func TestHandlerTimeFormat(t *testing.T) {
+	created := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	fakeTable(t, kanowins.Win{WinID: "1", TeamID: "T1", Who: "Bob", Title: "Shipped", CreatedAt: created, UpdatedAt: updated, TTL: time.Now().Add(time.Hour).Unix()})
+	t.Setenv("DASHBOARD_API_KEY", "key")
+	tests := []struct {
+		name        string
+		time        string
+		wantStatus  int
+		wantCreated interface{}
+		wantUpdated interface{}
+	}{
+		{"default", "", 200, "2024-02-01T10:00:00Z", "2024-02-01T11:00:00Z"},
+		{"rfc3339", "rfc3339", 200, "2024-02-01T10:00:00Z", "2024-02-01T11:00:00Z"},
+		{"epoch", "EPOCH", 200, float64(created.Unix()), float64(updated.Unix())},
+		{"invalid", "unix", 400, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := Handler(context.Background(), ProxyRequest{
+				Headers:               map[string]string{"X-Api-Key": "key"},
+				QueryStringParameters: map[string]string{"time": tt.time},
+			})
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, resp.Body)
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			var wins []map[string]interface{}
+			if err := json.Unmarshal([]byte(resp.Body), &wins); err != nil || len(wins) != 1 {
+				t.Fatalf("body %s: %v, want one WIN", resp.Body, err)
+			}
+			if wins[0]["created_at"] != tt.wantCreated || wins[0]["updated_at"] != tt.wantUpdated {
+				t.Errorf("created_at %v, updated_at %v, want %v and %v", wins[0]["created_at"], wins[0]["updated_at"], tt.wantCreated, tt.wantUpdated)
+			}
+			if wins[0]["title"] != "Shipped" {
+				t.Errorf("title = %v, want the other fields kept", wins[0]["title"])
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "application/json", true},
+		{"*/*", "application/json", true},
+		{"application/json", "application/json", true},
+		{"text/csv", "text/csv", true},
+		{"Text/HTML; charset=utf-8", "text/html", true},
+		{"application/xml, text/csv;q=0.5", "text/csv", true},
+		{"image/png", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := negotiate(tt.accept); got != tt.want || ok != tt.ok {
+			t.Errorf("negotiate(%q) = %q, %t, want %q, %t", tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHandlerAccept(t *testing.T) {
+	fakeTable(t, kanowins.Win{WinID: "1", TeamID: "T1", Who: "Bob", Title: "Shipped <the> release", CreatedAt: time.Now().Add(-time.Hour)})
+	t.Setenv("DASHBOARD_API_KEY", "key")
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		want        string
+	}{
+		{"", 200, "application/json", `"title":"Shipped \u003cthe\u003e release"`},
+		{"application/json", 200, "application/json", `"title":"Shipped \u003cthe\u003e release"`},
+		{"text/csv", 200, "text/csv", "Shipped <the> release"},
+		{"text/html", 200, "text/html", "<td>Shipped &lt;the&gt; release</td>"},
+		{"application/xml", 406, "", "not acceptable"},
+	}
+	for _, tt := range tests {
+		resp, err := Handler(context.Background(), ProxyRequest{Headers: map[string]string{"X-Api-Key": "key", "Accept": tt.accept}})
+		if err != nil {
+			t.Fatalf("Accept %q: Handler error: %v", tt.accept, err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("Accept %q: status = %d, want %d", tt.accept, resp.StatusCode, tt.status)
+		}
+		if tt.contentType != "" && resp.Headers["Content-Type"] != tt.contentType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, resp.Headers["Content-Type"], tt.contentType)
+		}
+		if !strings.Contains(resp.Body, tt.want) {
+			t.Errorf("Accept %q: body = %s, want %s", tt.accept, resp.Body, tt.want)
+		}
+	}
+}
+
+func TestHandlerSummaryLink(t *testing.T) {
+	now := time.Now()
+	fakeTable(t,
+		kanowins.Win{WinID: "1", TeamID: "T1", Who: "Bob", Title: "Public launch", Visibility: kanowins.VisibilityPublic, CreatedAt: now.Add(-time.Hour)},
+		kanowins.Win{WinID: "2", TeamID: "T1", Who: "Carol", Title: "Team only", CreatedAt: now.Add(-time.Hour)},
+		kanowins.Win{WinID: "3", TeamID: "T2", Who: "Dan", Title: "Other team", Visibility: kanowins.VisibilityPublic, CreatedAt: now.Add(-time.Hour)},
+	)
+	t.Setenv("DASHBOARD_API_KEY", "key")
+	t.Setenv("SUMMARY_LINK_SECRET", "secret")
+	valid, _ := kanowins.SignLink("T1", now.Add(time.Hour))
+	expired, _ := kanowins.SignLink("T1", now.Add(-time.Hour))
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid", valid, 200},
+		{"expired", expired, 403},
+		{"tampered", "T2" + strings.TrimPrefix(valid, "T1"), 403},
+	}
+	for _, tt := range tests {
+		resp, err := Handler(context.Background(), ProxyRequest{QueryStringParameters: map[string]string{"token": tt.token}})
+		if err != nil {
+			t.Fatalf("%s: Handler error: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+		if tt.status != 200 {
+			continue
+		}
+		if resp.Headers["Content-Type"] != "text/html" {
+			t.Errorf("%s: Content-Type = %q, want text/html", tt.name, resp.Headers["Content-Type"])
+		}
+		for title, want := range map[string]bool{"Public launch": true, "Team only": false, "Other team": false} {
+			if got := strings.Contains(resp.Body, title); got != want {
+				t.Errorf("%s: page shows %q = %t, want %t", tt.name, title, got, want)
+			}
+		}
+	}
+}
@@ -6,34 +6,166 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/apperror"
+	"github.com/anzellai/kanowins/internal/dialog"
+	"github.com/anzellai/kanowins/internal/gateway"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/metrics"
+	"github.com/anzellai/kanowins/internal/slack"
 )
 
 const (
-	handler     = "KanowinsCommand"
-	apiEndpoint = "https://slack.com/api/dialog.open"
+	handler = "KanowinsCommand"
+
+	// maxDeleteChoices caps how many recent WINS the delete message lists
+	maxDeleteChoices = 10
+
+	// maxThreadReplies caps how many WINS a threaded summary posts as replies,
+	// few enough, with threadReplyDelay between them, to send well inside
+	// Slack's 3 second ack
+	maxThreadReplies = 10
+
+	// undoWindow is how recently a WIN must have been logged for `/wins undo`
+	// to archive it without asking first
+	undoWindow = 10 * time.Minute
+
+	defaultSummaryHeader = "Summary for {window}"
+
+	// exportPageSize is how many items each `/wins export json` page scans
+	exportPageSize = 500
+
+	// maxInlineCSV bounds the CSV `/wins summary --csv-inline` posts, leaving
+	// room under Slack's 4,000 character guidance for the message around it
+	maxInlineCSV = 3500
 )
 
+// subcmd is a `/wins` subcommand and its help line
+type subcmd struct {
+	Name        string
+	Args        string
+	Description string
+}
+
+// subcommands are the `/wins` subcommands in help order, any other text
+// submits a WIN
+// threadReplyDelay is the pause between a threaded summary's replies, to
+// stay under chat.postMessage's rate limit. Tests set it to 0
+var threadReplyDelay = 100 * time.Millisecond
+
+var subcommands = []subcmd{
+	{"summary", "[3d|48h|from=2024-01-01 to=2024-01-07] [category=name] [--sort=newest|oldest|who] [--format=markdown|--csv-inline] [--group-by=who|category] [--all|--channel #name] [--include-archived] [--exclude-me] [--link]", "summarise recent WINS in this channel, another with --channel, or every channel with --all, --link shares the team's summary as a web page"},
+	{"count", "[3d|48h] [category=name] [--all] [--include-archived] [--exclude-me]", "count recent WINS"},
+	{"mine", "", "list your own WINS"},
+	{"search", "<term>", "find WINS mentioning a word"},
+	{"edit", "", "edit your latest WIN"},
+	{"delete", "", "archive one of your WINS"},
+	{"undo", "", "archive the WIN you just logged"},
+	{"reassign", "<win id> <who>", "correct who one of your WINS is for"},
+	{"recategorize", "<win id> <category>", "move one of your WINS to another category"},
+	{"renew", "<win id>", "keep one of your WINS for another TTL period"},
+	{"export", "[json [token]]", "upload current WINS as CSV, or as JSON a page at a time"},
+	{"kudos", "<win id>", "celebrate a WIN"},
+	{"react", "<win id> <:emoji:>", "react to a WIN with any emoji"},
+	{"leaderboard", "", "rank WIN submitters"},
+	{"stats", "", "chart WINS per day"},
+	{"streak", "[who]", "count the days in a row you, or who, logged WINS"},
+	{"random", "", "spotlight a random WIN"},
+	{"top", "", "crown the WIN with the most kudos"},
+	{"preview", "[who]", "submit a WIN, seeing how it looks before it is saved"},
+	{"schedule", "YYYY-MM-DD \"who\" \"title\" [\"description\"]", "save a WIN that only appears from that date"},
+	{"purge", "confirm", "admins only: delete every WIN in this workspace"},
+	{"setup", "", "admins only: create the WINS table if it is missing"},
+	{"help", "", "show this help"},
+}
+
+// submitUsage are the help lines for submitting a WIN, which has no
+// subcommand of its own
+var submitUsage = []subcmd{
+	{"", "[who]", "submit a WIN"},
+	{"", `"who" "title" ["description"]`, "submit a WIN without the dialog"},
+}
+
+// helpLine renders c as a help bullet such as `/wins renew <win id>`
+func helpLine(c subcmd) string {
+	parts := []string{"/wins"}
+	for _, part := range []string{c.Name, c.Args} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return fmt.Sprintf("• `%s` %s", strings.Join(parts, " "), c.Description)
+}
+
+// helpMessage builds teamID's `/wins help` message, leaving out the
+// subcommands disabled for the team. Each subcommand gets its own section so
+// a long usage stays under Slack's section text limit
+func helpMessage(teamID string) map[string]interface{} {
+	config := kanowins.ConfigFor(teamID)
+	title := "*KanoWINS* — celebrate your team's WINS"
+	lines := []string{title}
+	submit := []string{}
+	for _, c := range submitUsage {
+		submit = append(submit, helpLine(c))
+	}
+	lines = append(lines, submit...)
+	blocks := []map[string]interface{}{
+		helpSection(title),
+		helpSection(strings.Join(submit, "\n")),
+		{"type": "divider"},
+	}
+	for _, c := range subcommands {
+		if config.Disabled(c.Name) {
+			continue
+		}
+		lines = append(lines, helpLine(c))
+		blocks = append(blocks, helpSection(helpLine(c)))
+	}
+	return map[string]interface{}{
+		"text":   strings.Join(lines, "\n"),
+		"blocks": blocks,
+	}
+}
+
+// helpSection is a mrkdwn section block for the help message
+func helpSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{"type": "mrkdwn", "text": text},
+	}
+}
+
+// notConfigured is shown when there is no Slack token to call the Web API
+// with
+const notConfigured = "Bot is not configured — contact your admin."
+
+// storageMissing is shown when the WINs table hasn't been provisioned
+const storageMissing = "WINS storage isn't set up yet — contact your admin, who can run `/wins setup`."
+
 // Response is of type APIGatewayProxyResponse since we're leveraging the
 // AWS Lambda Proxy Request functionality (default behavior)
 //
 // https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
-type Response events.APIGatewayProxyResponse
+type Response = events.APIGatewayProxyResponse
 
-// ProxyRequest struct ...
-type ProxyRequest events.APIGatewayProxyRequest
+// ProxyRequest is the API Gateway request, decoded from either REST API or
+// HTTP API payloads
+type ProxyRequest = gateway.Request
 
 // Request is the proxy request from lambda
 type Request struct {
@@ -49,243 +181,1879 @@ type Request struct {
 	ResponseURL string `json:"response_url"`
 }
 
-// Payload struct type ...
-type Payload struct {
-	TriggerID string `json:"trigger_id"`
-	Dialog    Dialog `json:"dialog"`
+// Handler is our lambda handler invoked by the `lambda.Start` function call
+func Handler(ctx context.Context, r ProxyRequest) (resp Response, err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		alert.Notify(ctx, handler, apperror.ErrInternal)
+		resp, err = ephemeral(":warning: Sorry, something went wrong, please try again."), nil
+	})
+	defer metrics.Flush(ctx)
+	if kanowins.BodyTooLarge(r.Body, r.IsBase64Encoded) {
+		logging.Printf("Handler - rejected %d byte body", len(r.Body))
+		return gateway.Error(apperror.ErrTooLarge), nil
+	}
+	logging.Printf("Handler - invoke: %+v", r)
+	if err := r.DecodeBody(); err != nil {
+		logging.Printf("Handler - decode body error: %v", err)
+		return ephemeral(":warning: Sorry, I couldn't read that command, please try again."), nil
+	}
+	if slack.SSLCheck(r.Body) {
+		logging.Printf("Handler - ssl_check ping")
+		return gateway.JSON(200, ""), nil
+	}
+	if err := slack.VerifyRequest(r); err != nil {
+		logging.Printf("Handler - signature error: %v", err)
+		return gateway.Error(apperror.Wrap(apperror.ErrInvalidToken, err)), nil
+	}
+	if challenge, ok := slack.URLVerification(r.Body); ok {
+		logging.Printf("Handler - url_verification challenge")
+		body, _ := json.Marshal(map[string]string{"challenge": challenge})
+		return gateway.JSON(200, string(body)), nil
+	}
+	if kanowins.Duplicate(kanowins.Fingerprint(r.Body)) {
+		logging.Printf("Handler - duplicate request ignored")
+		return gateway.JSON(200, ""), nil
+	}
+	query, err := url.ParseQuery(r.Body)
+	if err != nil {
+		logging.Printf("Handler - unmarhsal error: %+v", err)
+		return ephemeral(":warning: Sorry, I couldn't read that command, please try again."), nil
+	}
+	request := Request{
+		Token:       query.Get("token"),
+		TeamID:      query.Get("team_id"),
+		TeamDomain:  query.Get("team_domain"),
+		ChannelID:   query.Get("channel_id"),
+		ChannelName: query.Get("channel_name"),
+		UserID:      query.Get("user_id"),
+		UserName:    query.Get("user_name"),
+		Text:        query.Get("text"),
+		TriggerID:   query.Get("trigger_id"),
+		ResponseURL: query.Get("response_url"),
+	}
+	logging.SetUser(request.UserID, request.TeamID)
+	ctx = slack.WithTeam(ctx, request.TeamID)
+	logging.Printf("Handler - invoke: %+v, for: %s, trigger_id: %s", request, request.Text, request.TriggerID)
+	fields := strings.Fields(request.Text)
+	command := ""
+	if len(fields) > 0 {
+		command = strings.ToLower(fields[0])
+	}
+	metrics.Count("CommandInvoked", map[string]string{
+		"Command": subcommand(command),
+		"TeamID":  request.TeamID,
+	})
+	if run, ok := routes[command]; ok {
+		if kanowins.ConfigFor(request.TeamID).Disabled(command) {
+			return ephemeral("That command is disabled for your team."), nil
+		}
+		return run(ctx, request, fields)
+	}
+	return submitCommand(ctx, request)
+}
+
+// route runs one `/wins` subcommand, fields being the command text split
+// on whitespace with the subcommand first
+type route func(ctx context.Context, request Request, fields []string) (Response, error)
+
+// routes dispatches on the first word of the command text, any other text
+// submits a WIN
+var routes = map[string]route{
+	"help":         helpCommand,
+	"summary":      summaryCommand,
+	"edit":         editCommand,
+	"delete":       deleteCommand,
+	"undo":         undoCommand,
+	"count":        countCommand,
+	"search":       searchCommand,
+	"mine":         mineCommand,
+	"export":       exportCommand,
+	"kudos":        kudosCommand,
+	"react":        reactCommand,
+	"reassign":     reassignCommand,
+	"recategorize": recategorizeCommand,
+	"renew":        renewCommand,
+	"random":       randomCommand,
+	"top":          topCommand,
+	"stats":        statsCommand,
+	"streak":       streakCommand,
+	"leaderboard":  leaderboardCommand,
+	"purge":        purgeCommand,
+	"schedule":     scheduleCommand,
+	"setup":        setupCommand,
+	"preview":      previewCommand,
 }
 
-// Dialog struct type ...
-type Dialog struct {
-	Title       string    `json:"title"`
-	CallbackID  string    `json:"callback_id"`
-	SubmitLabel string    `json:"submit_label"`
-	Elements    []Element `json:"elements"`
+// submitCommand handles any text that isn't a subcommand, saving a quoted
+// WIN straight away or opening the WIN dialog pre-filled with the text as who
+func submitCommand(ctx context.Context, request Request) (Response, error) {
+	if args := quotedArgs(request.Text); len(args) >= 2 {
+		if message := tooLong(args); message != "" {
+			return ephemeral(message), nil
+		}
+		err := quickWin(request, args, time.Time{})
+		logging.Printf("submitCommand - quickWin error: %+v", err)
+		if err != nil {
+			return failed(ctx, "submit", err)
+		}
+		metrics.Count("WinsSubmitted", map[string]string{"TeamID": request.TeamID})
+		return ephemeral(fmt.Sprintf(":tada: Your WIN for %s was saved!", args[0])), nil
+	}
+
+	err := dialog.Open(ctx, request.TriggerID, dialog.New(dialog.Title(request.TeamID), dialog.SubmitLabel(request.TeamID), "submit-win", dialogState(request, ""), kanowins.Win{Who: request.Text, TeamID: request.TeamID}))
+	logging.Printf("submitCommand - openDialog error: %v", err)
+	if err != nil {
+		dialogFailed(ctx, request, err)
+	} else {
+		trackDialog(request)
+	}
+
+	return gateway.JSON(200, ""), nil
 }
 
-// Element struct type ...
-type Element struct {
-	Label    string `json:"label"`
-	Type     string `json:"type"`
-	Name     string `json:"name"`
-	Value    string `json:"value"`
-	Hint     string `json:"hint"`
-	Optional bool   `json:"optional"`
+// previewCommand handles `/wins preview`, opening the WIN form with its
+// submission held for the submitter to confirm. The flag travels in the
+// form's state, so no form field can be left out to fit Slack's limits
+func previewCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	state := kanowins.DecodeDialogState(dialogState(request, ""))
+	state.Preview = true
+	who := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(request.Text), fields[0]))
+	err := dialog.Open(ctx, request.TriggerID, dialog.New(dialog.Title(request.TeamID), "Preview", "submit-win", state.Encode(), kanowins.Win{Who: who, TeamID: request.TeamID}))
+	logging.Printf("previewCommand - openDialog error: %v", err)
+	if err != nil {
+		dialogFailed(ctx, request, err)
+	} else {
+		trackDialog(request)
+	}
+	return gateway.JSON(200, ""), nil
 }
 
-// Win is the WIN struct type ...
-type Win struct {
-	UserID      string    `json:"user_id"`
-	UserName    string    `json:"user_name"`
-	Who         string    `json:"who"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	TTL         int64     `json:"ttl"`
+// helpCommand handles `/wins help`
+func helpCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	return ephemeralMessage(helpMessage(request.TeamID)), nil
 }
 
-// GetDB return DDB handle
-func GetDB() (srv *dynamodb.DynamoDB, err error) {
-	region := os.Getenv("REGION")
-	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+// purgeCommand handles `/wins purge`, which only runs for ADMIN_USERS once
+// they type `/wins purge confirm`
+func purgeCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if !adminUser(request.UserID) {
+		return ephemeral("Sorry, only admins can purge WINS."), nil
+	}
+	if len(fields) < 2 || strings.ToLower(fields[1]) != "confirm" {
+		return ephemeral(":warning: This deletes every WIN in this workspace and can't be undone. Run `/wins purge confirm` to go ahead."), nil
+	}
+	count, err := purgeWins(request)
+	logging.Printf("purgeCommand - purgeWins (%s, %d WINS) error: %+v", request.TeamID, count, err)
 	if err != nil {
-		return
+		return failed(ctx, "purge", err)
 	}
-	srv = dynamodb.New(sess)
-	return
+	return ephemeral(fmt.Sprintf("Purged %d WINS.", count)), nil
+}
+
+// setupCommand handles `/wins setup`, which only runs for ADMIN_USERS
+func setupCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if !adminUser(request.UserID) {
+		return ephemeral("Sorry, only admins can set up the WINS table."), nil
+	}
+	err := setupTable(ctx, request)
+	logging.Printf("setupCommand - setupTable error: %+v", err)
+	if err != nil {
+		return failed(ctx, "setup", err)
+	}
+	return gateway.JSON(200, ""), nil
 }
 
-// GetWins returns latest weekly WINS
-func GetWins() ([]Win, error) {
-	wins := []Win{}
-	srv, err := GetDB()
+// scheduleUsage explains `/wins schedule` when its arguments are missing
+const scheduleUsage = "Try something like `/wins schedule 2024-02-01 \"Alice\" \"Shipped the release\"`."
+
+// scheduleCommand handles `/wins schedule`, saving a quoted WIN that stays
+// out of summaries and counts until the given date
+func scheduleCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	args := quotedArgs(request.Text)
+	if len(fields) < 2 || len(args) < 2 {
+		return ephemeral("Sorry, I need a date, who and a title. " + scheduleUsage), nil
+	}
+	date, err := parseScheduleDate(fields[1], time.Now())
 	if err != nil {
-		return wins, err
+		return ephemeral(fmt.Sprintf("Sorry, %v. %s", err, scheduleUsage)), nil
 	}
-	params := &dynamodb.ScanInput{
-		TableName: aws.String(os.Getenv("TABLE_NAME")),
+	if message := tooLong(args); message != "" {
+		return ephemeral(message), nil
 	}
-	result, err := srv.Scan(params)
+	err = quickWin(request, args, date)
+	logging.Printf("scheduleCommand - quickWin (%s) error: %+v", date, err)
 	if err != nil {
-		return wins, err
+		return failed(ctx, "schedule", err)
 	}
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &wins)
-	return wins, err
+	metrics.Count("WinsSubmitted", map[string]string{"TeamID": request.TeamID})
+	return ephemeral(fmt.Sprintf(":calendar: Your WIN for %s was saved and will appear from %s.", args[0], date.Format("Mon 2 Jan 2006"))), nil
 }
 
-// Handler is our lambda handler invoked by the `lambda.Start` function call
-func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
-	log.Printf("%s.Handler - invoke: %+v", handler, r)
-	form, err := url.Parse("?" + r.Body)
+// parseScheduleDate parses a `/wins schedule` date such as `2024-02-01` as
+// the start of that day in the team's time zone, which must be after now
+func parseScheduleDate(value string, now time.Time) (time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", value, kanowins.Location())
 	if err != nil {
-		log.Printf("%s.Handler - unmarhsal error: %+v", handler, err)
+		return time.Time{}, fmt.Errorf("%q isn't a date like 2024-02-01", value)
 	}
-	query, _ := url.ParseQuery(form.RawQuery)
-	request := Request{
-		Token:       query["token"][0],
-		TeamID:      query["team_id"][0],
-		TeamDomain:  query["team_domain"][0],
-		ChannelID:   query["channel_id"][0],
-		ChannelName: query["channel_name"][0],
-		UserID:      query["user_id"][0],
-		UserName:    query["user_name"][0],
-		Text:        query["text"][0],
-		TriggerID:   query["trigger_id"][0],
-		ResponseURL: query["response_url"][0],
-	}
-	log.Printf("%s.Handler - invoke: %+v, for: %s, trigger_id: %s", handler, request, request.Text, request.TriggerID)
-	if request.Token != os.Getenv("SLACK_VERIFICATION_TOKEN") {
-		err = errors.New("invalid verification token")
-		return Response{
-			StatusCode:      400,
-			IsBase64Encoded: false,
-			Body:            fmt.Sprintf("%s submitting - error: %v", handler, err),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, err
+	if !date.After(now) {
+		return time.Time{}, fmt.Errorf("%s has already started, please pick a later date", value)
 	}
-	if strings.ToLower(request.Text) == "summary" {
-		wins, err := getSummary(request)
-		log.Printf("%s.Handler - getSummary: %+v, error: %+v", handler, wins, err)
-		if err != nil {
-			return Response{
-				StatusCode:      400,
-				IsBase64Encoded: false,
-				Body:            fmt.Sprintf("%s summary - error: %v", handler, err),
-				Headers: map[string]string{
-					"Content-Type": "application/json",
-				},
-			}, err
-		}
-	}
-
-	payload, err := json.Marshal(Payload{
-		TriggerID: request.TriggerID,
-		Dialog: Dialog{
-			Title:       "Submit a WIN",
-			CallbackID:  "submit-win",
-			SubmitLabel: "Submit",
-			Elements: []Element{
-				Element{
-					Label: "Who?",
-					Type:  "text",
-					Name:  "who",
-					Value: request.Text,
-					Hint:  "The name of the person who has this WIN",
-				},
-				Element{
-					Label: "Title",
-					Type:  "text",
-					Name:  "title",
-					Hint:  "Title of this WIN",
-				},
-				Element{
-					Label:    "Long description",
-					Type:     "textarea",
-					Name:     "description",
-					Hint:     "Long description of this WIN (if any)",
-					Optional: true,
-				},
-			},
-		},
-	})
+	return date, nil
+}
+
+// summaryCommand handles `/wins summary`
+func summaryCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if !summaryAllowed(request.UserID) {
+		return ephemeral("You don't have permission to view summaries."), nil
+	}
+	options, err := parseSummaryOptions(fields[1:], request)
 	if err != nil {
-		log.Printf("%s.Handler - error marshalling dialog request: %v", handler, err)
-	} else {
-		req, reqErr := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(payload))
-		if reqErr != nil {
-			log.Printf("%s.Handler - error sending dialog request: %v", handler, reqErr)
-			err = reqErr
-		} else {
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+os.Getenv("SLACK_ACCESS_TOKEN"))
-			client := &http.Client{}
-			response, respErr := client.Do(req)
-			if respErr != nil {
-				log.Printf("%s.Handler - error receiving dialog response: %v", handler, reqErr)
-				err = respErr
-			} else {
-				defer response.Body.Close()
-				var status struct {
-					OK    bool   `json:"ok"`
-					Error string `json:"error"`
-				}
-				err = json.NewDecoder(response.Body).Decode(&status)
-				log.Printf("%s.Handler - ok: %t, error: %s, err: %v", handler, status.OK, status.Error, err)
-			}
+		return ephemeral(fmt.Sprintf("Sorry, %v. Try something like `/wins summary 3d --sort=who`.", err)), nil
+	}
+	if options.Link {
+		return summaryLink(request)
+	}
+	wins, err := getSummary(ctx, request, options)
+	logging.Printf("summaryCommand - getSummary: %+v, error: %+v", wins, err)
+	if err != nil {
+		return failed(ctx, "summary", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// summaryLink replies with a signed link to the team's summary page, for
+// sharing with people outside Slack until it expires
+func summaryLink(request Request) (Response, error) {
+	link, err := kanowins.SummaryLink(request.TeamID, time.Now())
+	if errors.Is(err, kanowins.ErrLinksNotConfigured) {
+		return ephemeral("Summary links aren't set up yet, ask your admin to set SUMMARY_LINK_SECRET and SUMMARY_LINK_URL."), nil
+	}
+	if err != nil {
+		logging.Printf("summaryLink - SummaryLink error: %v", err)
+		return ephemeral("Sorry, I couldn't make a summary link, please try again."), nil
+	}
+	return ephemeral(fmt.Sprintf("Here's your team's WINS summary, anyone with the link can view it for the next %d hours: %s", int(kanowins.LinkTTL.Hours()), link)), nil
+}
+
+// editCommand handles `/wins edit`
+func editCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	message, err := editWin(ctx, request)
+	logging.Printf("editCommand - editWin error: %+v", err)
+	if err != nil {
+		return failed(ctx, "edit", err)
+	}
+	if message != "" {
+		return ephemeral(message), nil
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// deleteCommand handles `/wins delete`
+func deleteCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	message, err := deleteWins(request)
+	logging.Printf("deleteCommand - deleteWins error: %+v", err)
+	if err != nil {
+		return failed(ctx, "delete", err)
+	}
+	return ephemeralMessage(message), nil
+}
+
+// undoCommand handles `/wins undo`
+func undoCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	message, err := undoWin(request, time.Now())
+	logging.Printf("undoCommand - undoWin error: %+v", err)
+	if err != nil {
+		return failed(ctx, "undo", err)
+	}
+	return ephemeralMessage(message), nil
+}
+
+// countCommand handles `/wins count`
+func countCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	options, err := parseSummaryOptions(fields[1:], request)
+	if err != nil {
+		return ephemeral(fmt.Sprintf("Sorry, %v. Try something like `/wins count 3d`.", err)), nil
+	}
+	err = getCount(ctx, request, options)
+	logging.Printf("countCommand - getCount error: %+v", err)
+	if err != nil {
+		return failed(ctx, "count", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// searchCommand handles `/wins search`
+func searchCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	term := strings.TrimSpace(strings.Join(fields[1:], " "))
+	if term == "" {
+		return ephemeral("Tell me what to look for, try something like `/wins search launch`."), nil
+	}
+	err := searchWins(ctx, request, term)
+	logging.Printf("searchCommand - searchWins error: %+v", err)
+	if err != nil {
+		return failed(ctx, "search", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// mineCommand handles `/wins mine`
+func mineCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	err := getMine(ctx, request)
+	logging.Printf("mineCommand - getMine error: %+v", err)
+	if err != nil {
+		return failed(ctx, "mine", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// exportCommand handles `/wins export`
+func exportCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	var err error
+	if len(fields) > 1 && strings.ToLower(fields[1]) == "json" {
+		cursor := ""
+		if len(fields) > 2 {
+			cursor = fields[2]
 		}
+		err = exportJSON(ctx, request, cursor)
+		if err == kanowins.ErrInvalidCursor {
+			return ephemeral("That export token isn't valid, run `/wins export json` to start again."), nil
+		}
+	} else {
+		err = exportWins(ctx, request)
+	}
+	logging.Printf("exportCommand - exportWins error: %+v", err)
+	if err != nil {
+		return failed(ctx, "export", err)
 	}
+	return gateway.JSON(200, ""), nil
+}
 
-	resp := Response{
-		StatusCode:      200,
-		IsBase64Encoded: false,
-		Body:            "",
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
+// kudosCommand handles `/wins kudos`
+func kudosCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if len(fields) < 2 {
+		return ephemeral("Which WIN deserves kudos? Try `/wins kudos <win id>`."), nil
+	}
+	text := ":clap: Kudos sent!"
+	err := giveKudos(request.TeamID, fields[1])
+	logging.Printf("kudosCommand - giveKudos error: %+v", err)
+	if err == kanowins.ErrNotFound {
+		text = "Sorry, I couldn't find that WIN."
+	} else if err != nil {
+		return failed(ctx, "kudos", err)
 	}
+	return ephemeral(text), nil
+}
 
-	return resp, nil
+// reactCommand handles `/wins react`
+func reactCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if len(fields) < 3 {
+		return ephemeral("Which WIN and which emoji? Try `/wins react <win id> :tada:`."), nil
+	}
+	emoji, ok := kanowins.ReactionName(fields[2])
+	if !ok {
+		return ephemeral("Sorry, that isn't an emoji, use a shortcode like `:tada:`."), nil
+	}
+	text := fmt.Sprintf(":%s: Reaction sent!", emoji)
+	err := addReaction(request.TeamID, fields[1], emoji)
+	logging.Printf("reactCommand - addReaction error: %+v", err)
+	if err == kanowins.ErrNotFound {
+		text = "Sorry, I couldn't find that WIN."
+	} else if err != nil {
+		return failed(ctx, "react", err)
+	}
+	return ephemeral(text), nil
 }
 
-// WinSummary struct ...
-type WinSummary struct {
-	Who         string `json:"who"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	CreatedAt   string `json:"created_at"`
+// reassignCommand handles `/wins reassign`
+func reassignCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if len(fields) < 3 {
+		return ephemeral("Which WIN and who for? Try `/wins reassign <win id> <who>`."), nil
+	}
+	who := strings.Join(fields[2:], " ")
+	text := fmt.Sprintf("Your WIN is now for %s.", who)
+	err := reassignWin(request, fields[1], who)
+	logging.Printf("reassignCommand - reassignWin error: %+v", err)
+	if err == kanowins.ErrNotFound {
+		text = "Sorry, I couldn't find that WIN, or it isn't yours to reassign."
+	} else if err != nil {
+		return failed(ctx, "reassign", err)
+	}
+	return ephemeral(text), nil
 }
 
-func getSummary(request Request) (wins []Win, err error) {
-	// return a summary of collected WINS
-	wins, err = GetWins()
+// recategorizeCommand handles `/wins recategorize`
+func recategorizeCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	categories := kanowins.ConfigFor(request.TeamID).Categories
+	if len(categories) == 0 {
+		return ephemeral("WIN categories aren't set up, ask your admin to configure some."), nil
+	}
+	if len(fields) < 3 {
+		return ephemeral("Which WIN and which category? Try `/wins recategorize <win id> <category>`."), nil
+	}
+	category, ok := matchCategory(strings.Join(fields[2:], " "), categories)
+	if !ok {
+		return ephemeral(fmt.Sprintf("Sorry, that isn't a WIN category, choose one of: %s.", strings.Join(categories, ", "))), nil
+	}
+	text := fmt.Sprintf("Your WIN is now in %s.", category)
+	err := recategorizeWin(request, fields[1], category)
+	logging.Printf("recategorizeCommand - recategorizeWin error: %+v", err)
+	if err == kanowins.ErrNotFound {
+		text = "Sorry, I couldn't find that WIN, or it isn't yours to recategorize."
+	} else if err != nil {
+		return failed(ctx, "recategorize", err)
+	}
+	return ephemeral(text), nil
+}
+
+// renewCommand handles `/wins renew`
+func renewCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	if len(fields) < 2 {
+		return ephemeral("Which WIN? Try `/wins renew <win id>`."), nil
+	}
+	days := kanowins.ConfigFor(request.TeamID).TTLDays
+	text := fmt.Sprintf("Your WIN will now be kept for another %d days.", days)
+	err := renewWin(request, fields[1], days)
+	logging.Printf("renewCommand - renewWin error: %+v", err)
+	if err == kanowins.ErrNotFound {
+		text = "Sorry, I couldn't find that WIN, or it isn't yours to renew."
+	} else if err != nil {
+		return failed(ctx, "renew", err)
+	}
+	return ephemeral(text), nil
+}
+
+// randomCommand handles `/wins random`
+func randomCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	err := getRandom(ctx, request)
+	logging.Printf("randomCommand - getRandom error: %+v", err)
 	if err != nil {
-		return
+		return failed(ctx, "random", err)
 	}
-	winsSummary := []WinSummary{}
-	for _, win := range wins {
-		if time.Now().Sub(win.CreatedAt).Hours() > float64(12.0) {
-			winsSummary = append(winsSummary, WinSummary{
-				Who:         win.Who,
-				Title:       win.Title,
-				Description: win.Description,
-				CreatedAt:   win.CreatedAt.Format(time.RFC3339)[:19],
-			})
-		}
-	}
-	winsText, _ := json.MarshalIndent(winsSummary, "", "  ")
-	summaryText := []string{
-		"=============================",
-		" Summary for last 7 days (TTL)",
-		fmt.Sprintf(" WINS count: %d", len(winsSummary)),
-		"=============================",
-		"",
-		string(winsText),
-	}
-
-	summary, _ := json.Marshal(map[string]interface{}{
-		"text": strings.Join(summaryText, "\n"),
+	return gateway.JSON(200, ""), nil
+}
+
+// topCommand handles `/wins top`
+func topCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	err := getTop(ctx, request)
+	logging.Printf("topCommand - getTop error: %+v", err)
+	if err != nil {
+		return failed(ctx, "top", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// statsCommand handles `/wins stats`
+func statsCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	err := getStats(ctx, request)
+	logging.Printf("statsCommand - getStats error: %+v", err)
+	if err != nil {
+		return failed(ctx, "stats", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// streakCommand handles `/wins streak`
+func streakCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	err := getStreak(ctx, request, strings.Join(fields[1:], " "))
+	logging.Printf("streakCommand - getStreak error: %+v", err)
+	if err != nil {
+		return failed(ctx, "streak", err)
+	}
+	return gateway.JSON(200, ""), nil
+}
+
+// leaderboardCommand handles `/wins leaderboard`
+func leaderboardCommand(ctx context.Context, request Request, fields []string) (Response, error) {
+	err := getLeaderboard(ctx, request)
+	logging.Printf("leaderboardCommand - getLeaderboard error: %+v", err)
+	if err != nil {
+		return failed(ctx, "leaderboard", err)
+	}
+	return gateway.JSON(200, ""), nil
+
+}
+
+// subcommand names the invoked subcommand for metrics, keeping free text out
+// of the metric dimensions
+func subcommand(command string) string {
+	if _, ok := routes[command]; ok {
+		return command
+	}
+	return "submit"
+}
+
+// failed logs err and explains it ephemerally, Slack only shows a message
+// for a 200 response
+func failed(ctx context.Context, command string, err error) (Response, error) {
+	alert.Notify(ctx, handler, err)
+	if kanowins.IsMissingTable(err) {
+		logging.Printf("failed - %s: WINs table is missing: %v", command, err)
+		return ephemeral(storageMissing), nil
+	}
+	if errors.Is(err, slack.ErrNoToken) {
+		logging.Printf("failed - %s: no Slack token: %v", command, err)
+		return ephemeral(notConfigured), nil
+	}
+	logging.Printf("failed - %s error: %v", command, err)
+	return ephemeral(fmt.Sprintf(":warning: Sorry, something went wrong with `/wins %s`, please try again.", command)), nil
+}
+
+// ephemeral builds a slash command response only visible to the caller
+func ephemeral(text string) Response {
+	return ephemeralMessage(map[string]interface{}{
+		"text": text,
 	})
-	req, err := http.NewRequest("POST", request.ResponseURL, bytes.NewBuffer(summary))
+}
+
+// ephemeralMessage builds a slash command response with a full message
+// payload only visible to the caller
+func ephemeralMessage(message map[string]interface{}) Response {
+	message["response_type"] = "ephemeral"
+	body, _ := json.Marshal(message)
+	return gateway.JSON(200, string(body))
+}
+
+// parseWindow parses a summary window such as `48h` or `3d`, extending
+// time.ParseDuration with a `d` suffix for days
+func parseWindow(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, err
+		}
+		value = fmt.Sprintf("%dh", days*24)
+	}
+	window, err := time.ParseDuration(value)
 	if err != nil {
-		return
+		return 0, err
+	}
+	if window <= 0 {
+		return 0, errors.New("window must be positive")
+	}
+	return window, nil
+}
+
+// formatWindow renders a summary window in days when it is a whole number of
+// days, or as a plain duration otherwise
+func formatWindow(window time.Duration) string {
+	day := 24 * time.Hour
+	if window%day == 0 {
+		days := int(window / day)
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("SLACK_ACCESS_TOKEN"))
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return window.String()
+}
+
+// dialogState encodes the invoking team and channel, and the WinID being
+// edited if any, for the dialog to carry back on submit
+func dialogState(request Request, winID string) string {
+	return kanowins.DialogState{
+		WinID:       winID,
+		TeamID:      request.TeamID,
+		ChannelID:   request.ChannelID,
+		ChannelName: request.ChannelName,
+		TriggerID:   request.TriggerID,
+	}.Encode()
+}
+
+// trackDialog records the dialog just opened for request, a failure is only
+// logged
+func trackDialog(request Request) {
+	db, err := kanowins.NewClient()
+	if err == nil {
+		err = db.TrackDialog(request.TriggerID, request.TeamID)
+	}
+	if err != nil {
+		logging.Printf("trackDialog - TrackDialog (%s) error: %v", request.TriggerID, err)
+	}
+}
+
+// quotedPattern matches a straight or curly double quoted argument, Slack
+// clients often substitute curly quotes as users type
+var quotedPattern = regexp.MustCompile(`"([^"]*)"|“([^”]*)”`)
+
+// quotedArgs returns the quoted arguments of the command text, such as
+// `"Alice" "Shipped the release"`
+func quotedArgs(text string) []string {
+	args := []string{}
+	for _, match := range quotedPattern.FindAllStringSubmatch(text, -1) {
+		arg := strings.TrimSpace(match[1] + match[2])
+		if arg == "" {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// tooLong explains which quick submit argument exceeds the WIN length
+// limits, or returns "" when they all fit
+func tooLong(args []string) string {
+	if utf8.RuneCountInString(args[1]) > kanowins.MaxTitleLength {
+		return fmt.Sprintf("Sorry, please keep the title to %d characters or fewer.", kanowins.MaxTitleLength)
+	}
+	if len(args) > 2 && utf8.RuneCountInString(args[2]) > kanowins.MaxDescriptionLength {
+		return fmt.Sprintf("Sorry, please keep the description to %d characters or fewer.", kanowins.MaxDescriptionLength)
+	}
+	return ""
+}
+
+func quickWin(request Request, args []string, visibleAfter time.Time) (err error) {
+	// store a WIN straight from `/wins "who" "title" ["description"]`, one
+	// scheduled to appear later is dated then so summary windows and its TTL
+	// count from when it appears
+	win := kanowins.Win{
+		UserID:      request.UserID,
+		UserName:    request.UserName,
+		TeamID:      request.TeamID,
+		ChannelID:   request.ChannelID,
+		ChannelName: request.ChannelName,
+		Who:         args[0],
+		Title:       args[1],
+		Description: kanowins.DefaultDescription(),
+		Source:      kanowins.SourceQuick,
+	}
+	if len(args) > 2 {
+		win.Description = args[2]
+	}
+	if !visibleAfter.IsZero() {
+		win.VisibleAfter = visibleAfter
+		win.CreatedAt = visibleAfter
+	}
+	db, err := kanowins.NewClient()
 	if err != nil {
 		return
 	}
-	var respBody map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&respBody)
-	log.Printf("%s.Handler - error receiving dialog response Body: %v", handler, respBody)
-	defer resp.Body.Close()
+	err = db.Put(win)
 	return
 }
 
-func main() {
+// getWins returns teamID's stored WINS still within their TTL, leaving out
+// archived, private and scheduled WINS
+func getWins(teamID string) ([]kanowins.Win, error) {
+	return loadWins(teamID, false)
+}
+
+// loadWins returns teamID's stored WINS still within their TTL, including
+// archived WINS when includeArchived is set, never private ones or those
+// scheduled for later
+func loadWins(teamID string, includeArchived bool) ([]kanowins.Win, error) {
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	wins, err := db.Get()
+	if err != nil {
+		return nil, err
+	}
+	wins = inTeam(wins, teamID)
+	if includeArchived {
+		return shared(visible(unexpired(wins))), nil
+	}
+	return shared(visible(unarchived(unexpired(wins)))), nil
+}
+
+// getUserWins returns the unarchived WINS submitted by userID still within
+// their TTL, most recent first
+func getUserWins(userID string) ([]kanowins.Win, error) {
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	wins, err := db.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return unarchived(unexpired(wins)), nil
+}
+
+// unexpired drops WINS past their TTL which DynamoDB hasn't removed yet
+func unexpired(wins []kanowins.Win) []kanowins.Win {
+	now := time.Now()
+	current := []kanowins.Win{}
+	for _, win := range wins {
+		if !win.Expired(now) {
+			current = append(current, win)
+		}
+	}
+	return current
+}
+
+// visible drops WINS scheduled by `/wins schedule` to appear later
+func visible(wins []kanowins.Win) []kanowins.Win {
+	now := time.Now()
+	current := []kanowins.Win{}
+	for _, win := range wins {
+		if !win.Hidden(now) {
+			current = append(current, win)
+		}
+	}
+	return current
+}
+
+// shared drops private WINS, which only show in their submitter's
+// `/wins mine`
+func shared(wins []kanowins.Win) []kanowins.Win {
+	current := []kanowins.Win{}
+	for _, win := range wins {
+		if win.VisibleTo(kanowins.VisibilityTeam) {
+			current = append(current, win)
+		}
+	}
+	return current
+}
+
+// inTeam drops WINS logged in any workspace other than teamID
+func inTeam(wins []kanowins.Win, teamID string) []kanowins.Win {
+	current := []kanowins.Win{}
+	for _, win := range wins {
+		if win.TeamID == teamID {
+			current = append(current, win)
+		}
+	}
+	return current
+}
+
+// unarchived drops WINS archived by `/wins delete`
+func unarchived(wins []kanowins.Win) []kanowins.Win {
+	current := []kanowins.Win{}
+	for _, win := range wins {
+		if !win.Archived {
+			current = append(current, win)
+		}
+	}
+	return current
+}
+
+func editWin(ctx context.Context, request Request) (message string, err error) {
+	// open the edit dialog for the caller's most recent WIN
+	mine, err := getUserWins(request.UserID)
+	if err != nil {
+		return
+	}
+	if len(mine) == 0 {
+		message = "You don't have any WINS to edit yet, use `/wins` to submit one!"
+		return
+	}
+	win := mine[0]
+	if err = dialog.Open(ctx, request.TriggerID, dialog.New("Edit your WIN", dialog.SubmitLabel(request.TeamID), "edit-win", dialogState(request, win.WinID), win)); err != nil {
+		dialogFailed(ctx, request, err)
+		return
+	}
+	trackDialog(request)
+	return
+}
+
+// dialogFailed tells the caller their dialog couldn't be opened, Slack
+// otherwise shows nothing at all when dialog.open fails
+func dialogFailed(ctx context.Context, request Request, err error) {
+	text := "Couldn't open the WIN form, please try again."
+	if slackErr, ok := err.(*slack.Error); ok {
+		text = fmt.Sprintf("Couldn't open the WIN form (`%s`), please try again.", slackErr.Code)
+	}
+	if err == slack.ErrNoToken {
+		text = notConfigured
+	}
+	if postErr := postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          text,
+	}); postErr != nil {
+		logging.Printf("dialogFailed - postMessage error: %v", postErr)
+	}
+}
+
+func deleteWins(request Request) (message map[string]interface{}, err error) {
+	// list the caller's recent WINS with a delete button each
+	mine, err := getUserWins(request.UserID)
+	if err != nil {
+		return
+	}
+	if len(mine) == 0 {
+		message = map[string]interface{}{
+			"text": "You don't have any WINS to delete.",
+		}
+		return
+	}
+	if len(mine) > maxDeleteChoices {
+		mine = mine[:maxDeleteChoices]
+	}
+	attachments := []map[string]interface{}{}
+	for _, win := range mine {
+		attachments = append(attachments, map[string]interface{}{
+			"text":        fmt.Sprintf("*%s* — %s", kanowins.SanitizeMrkdwn(win.Who), kanowins.SanitizeMrkdwn(win.Title)),
+			"callback_id": "delete-win",
+			"actions": []map[string]interface{}{
+				map[string]interface{}{
+					"name":  "delete",
+					"text":  "Delete",
+					"type":  "button",
+					"style": "danger",
+					"value": win.WinID,
+				},
+			},
+		})
+	}
+	message = map[string]interface{}{
+		"text":        "Which WIN would you like to delete?",
+		"attachments": attachments,
+	}
+	return
+}
+
+// undoWin archives the caller's most recent WIN when it was logged within
+// undoWindow, an older WIN is only offered with a button to confirm
+func undoWin(request Request, now time.Time) (message map[string]interface{}, err error) {
+	mine, err := getUserWins(request.UserID)
+	if err != nil {
+		return
+	}
+	if len(mine) == 0 {
+		message = map[string]interface{}{
+			"text": "You don't have any WINS to undo.",
+		}
+		return
+	}
+	latest := mine[0]
+	described := fmt.Sprintf("*%s* — %s", kanowins.SanitizeMrkdwn(latest.Who), kanowins.SanitizeMrkdwn(latest.Title))
+	if now.Sub(latest.CreatedAt) > undoWindow {
+		message = map[string]interface{}{
+			"text": fmt.Sprintf("Your latest WIN was logged more than %d minutes ago, undo it anyway?", int(undoWindow/time.Minute)),
+			"attachments": []map[string]interface{}{
+				map[string]interface{}{
+					"text":        described,
+					"callback_id": "delete-win",
+					"actions": []map[string]interface{}{
+						map[string]interface{}{
+							"name":  "delete",
+							"text":  "Undo",
+							"type":  "button",
+							"style": "danger",
+							"value": latest.WinID,
+						},
+					},
+				},
+			},
+		}
+		return
+	}
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.Archive(latest.WinID, request.UserID)
+	if err == kanowins.ErrNotFound {
+		message, err = map[string]interface{}{"text": "That WIN no longer exists."}, nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	message = map[string]interface{}{
+		"text": "Undone your WIN " + described + ".",
+	}
+	return
+}
+
+// summaryAllowed reports whether userID may run `/wins summary`, limited to
+// the comma separated SUMMARY_ALLOWED_USERS when it is set
+func summaryAllowed(userID string) bool {
+	allowed := os.Getenv("SUMMARY_ALLOWED_USERS")
+	if strings.TrimSpace(allowed) == "" {
+		return true
+	}
+	for _, id := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(id) == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// adminUser reports whether userID is one of the comma separated
+// ADMIN_USERS, nobody is an admin when it is unset
+func adminUser(userID string) bool {
+	for _, id := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if id = strings.TrimSpace(id); id != "" && id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryOptions are the arguments accepted by `/wins summary`. From and To
+// replace Window with a date range
+type summaryOptions struct {
+	Window   time.Duration
+	From     time.Time
+	To       time.Time
+	Sort     string
+	Category string
+	Channel  string
+
+	// ExcludeUser drops the WINS this user submitted, set by `--exclude-me`
+	ExcludeUser string
+
+	// Format is `markdown` for a copy-paste report, `csv` for `--csv-inline`
+	// or empty for Block Kit
+	Format string
+
+	// GroupBy is `who` or `category` for an aggregated view, empty to list
+	// every WIN
+	GroupBy string
+
+	// Link asks for a signed link to the team's summary page instead, set
+	// by `--link`
+	Link bool
+
+	IncludeArchived bool
+}
+
+// parseDate parses a `from=`/`to=` value, either a plain `2006-01-02` date
+// starting at midnight in the team's time zone or a full RFC3339 timestamp
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, kanowins.Location()); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ranged reports whether the options select WINS by date range
+func (options summaryOptions) ranged() bool {
+	return !options.From.IsZero() || !options.To.IsZero()
+}
+
+// describe renders the period covered by the options for message text
+func (options summaryOptions) describe() string {
+	if !options.ranged() {
+		return "last " + formatWindow(options.Window)
+	}
+	from, to := "the beginning", "now"
+	if !options.From.IsZero() {
+		from = options.From.Format("2006-01-02")
+	}
+	if !options.To.IsZero() {
+		to = options.To.Format("2006-01-02")
+	}
+	return from + " to " + to
+}
+
+// channelPattern matches the `<#C123|name>` reference Slack sends for a
+// channel mentioned in command text
+var channelPattern = regexp.MustCompile(`^<#([CG][A-Z0-9]+)(?:\|[^>]*)?>$`)
+
+// parseChannel returns the channel ID of a `<#C123|name>` reference
+func parseChannel(ref string) (string, error) {
+	match := channelPattern.FindStringSubmatch(ref)
+	if match == nil {
+		return "", fmt.Errorf("I couldn't understand the channel %q, pick it from Slack's suggestions like `--channel #general`", ref)
+	}
+	return match[1], nil
+}
+
+// parseSummaryOptions parses summary arguments such as `3d --sort=who`,
+// `from=2024-01-01 to=2024-01-07` or `category=sales`. By default a summary
+// shows the invoking channel's WINS over the whole TTL period, newest first
+func parseSummaryOptions(args []string, request Request) (options summaryOptions, err error) {
+	options = summaryOptions{
+		Window:  time.Duration(kanowins.ConfigFor(request.TeamID).TTLDays) * 24 * time.Hour,
+		Sort:    "newest",
+		Channel: request.ChannelID,
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--all":
+			options.Channel = ""
+		case arg == "--channel", strings.HasPrefix(arg, "--channel="):
+			ref := strings.TrimPrefix(arg, "--channel=")
+			if arg == "--channel" {
+				if i+1 == len(args) {
+					return options, errors.New("`--channel` needs a channel, such as `--channel #general`")
+				}
+				i++
+				ref = args[i]
+			}
+			if options.Channel, err = parseChannel(ref); err != nil {
+				return options, err
+			}
+		case arg == "--include-archived":
+			options.IncludeArchived = true
+		case arg == "--exclude-me":
+			options.ExcludeUser = request.UserID
+		case arg == "--link":
+			options.Link = true
+		case strings.HasPrefix(arg, "category="):
+			options.Category = strings.TrimPrefix(arg, "category=")
+		case strings.HasPrefix(arg, "from="), strings.HasPrefix(arg, "to="):
+			parts := strings.SplitN(arg, "=", 2)
+			date, dateErr := parseDate(parts[1])
+			if dateErr != nil {
+				return options, fmt.Errorf("I couldn't understand the date %q, use a date like `2024-01-31`", parts[1])
+			}
+			if parts[0] == "from" {
+				options.From = date
+			} else {
+				options.To = date
+			}
+		case strings.HasPrefix(arg, "--sort="):
+			options.Sort = strings.ToLower(strings.TrimPrefix(arg, "--sort="))
+			if options.Sort != "newest" && options.Sort != "oldest" && options.Sort != "who" {
+				return options, fmt.Errorf("I can only sort by `newest`, `oldest` or `who`, not %q", options.Sort)
+			}
+		case strings.HasPrefix(arg, "--group-by="):
+			options.GroupBy = strings.ToLower(strings.TrimPrefix(arg, "--group-by="))
+			if options.GroupBy != "who" && options.GroupBy != "category" {
+				return options, fmt.Errorf("I can only group by `who` or `category`, not %q", options.GroupBy)
+			}
+		case arg == "--csv-inline":
+			options.Format = "csv"
+		case strings.HasPrefix(arg, "--format="):
+			options.Format = strings.ToLower(strings.TrimPrefix(arg, "--format="))
+			if options.Format != "markdown" {
+				return options, fmt.Errorf("I can only format summaries as `markdown`, not %q", options.Format)
+			}
+		default:
+			if options.Window, err = parseWindow(arg); err != nil {
+				return options, fmt.Errorf("I couldn't understand the window %q", arg)
+			}
+		}
+	}
+	if !options.From.IsZero() && !options.To.IsZero() && options.From.After(options.To) {
+		return options, errors.New("the `from` date must not be after the `to` date")
+	}
+	return
+}
+
+// sortWins orders WINS in place by `newest`, `oldest` or `who`, with WINS
+// for the same person newest first
+func sortWins(wins []kanowins.Win, by string) {
+	sort.SliceStable(wins, func(i, j int) bool {
+		switch by {
+		case "oldest":
+			return wins[i].CreatedAt.Before(wins[j].CreatedAt)
+		case "who":
+			if a, b := strings.ToLower(kanowins.WhoKey(wins[i].Who)), strings.ToLower(kanowins.WhoKey(wins[j].Who)); a != b {
+				return a < b
+			}
+		}
+		return wins[i].CreatedAt.After(wins[j].CreatedAt)
+	})
+}
+
+// inPeriod reports whether the WIN was created within the options' date
+// range or relative window, a plain `to=` date includes that whole day
+func inPeriod(win kanowins.Win, options summaryOptions, now time.Time) bool {
+	if !options.ranged() {
+		return now.Sub(win.CreatedAt) <= options.Window
+	}
+	if !options.From.IsZero() && win.CreatedAt.Before(options.From) {
+		return false
+	}
+	if options.To.IsZero() {
+		return true
+	}
+	to := options.To
+	if hour, min, sec := to.Clock(); hour == 0 && min == 0 && sec == 0 && to.Nanosecond() == 0 {
+		return win.CreatedAt.Before(to.AddDate(0, 0, 1))
+	}
+	return !win.CreatedAt.After(to)
+}
+
+// filterWins returns the WINS matching the summary period, category and
+// channel, WINS logged before channels were recorded match every channel
+func filterWins(wins []kanowins.Win, options summaryOptions, now time.Time) []kanowins.Win {
+	filtered := []kanowins.Win{}
+	for _, win := range wins {
+		if !inPeriod(win, options, now) {
+			continue
+		}
+		if options.Channel != "" && win.ChannelID != "" && win.ChannelID != options.Channel {
+			continue
+		}
+		if options.Category != "" && !strings.EqualFold(win.Category, options.Category) {
+			continue
+		}
+		if options.ExcludeUser != "" && win.UserID == options.ExcludeUser {
+			continue
+		}
+		filtered = append(filtered, win)
+	}
+	return filtered
+}
+
+func getSummary(ctx context.Context, request Request, options summaryOptions) (wins []kanowins.Win, err error) {
+	// return a summary of collected WINS
+	wins, err = loadWins(request.TeamID, options.IncludeArchived)
+	if err != nil {
+		return
+	}
+	sortWins(wins, options.Sort)
+	filtered := filterWins(wins, options, time.Now())
+	winsSummary := kanowins.CollapseRepeats(filtered, kanowins.RepeatWindow)
+	header := summaryHeader(options.describe())
+	if len(winsSummary) == 0 {
+		// an empty header and divider looks broken, invite a first WIN instead
+		err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"text": fmt.Sprintf("%s: no WINS logged yet — be the first! :tada:", header),
+		})
+		return
+	}
+	if options.Format == "markdown" {
+		err = postMarkdownSummary(ctx, request, header, winsSummary)
+		return
+	}
+	if options.Format == "csv" {
+		err = postInlineCSV(ctx, request, header, filtered)
+		return
+	}
+	if options.GroupBy != "" {
+		// one line per group stays compact however many WINS there are, so it
+		// is never threaded or uploaded
+		err = postSummary(ctx, request, map[string]interface{}{
+			"text":   fmt.Sprintf("%s by %s, WINS count: %d", header, options.GroupBy, len(winsSummary)),
+			"blocks": kanowins.BuildGroupedSummaryBlocks(fmt.Sprintf("%s by %s (%d)", header, options.GroupBy, len(winsSummary)), winsSummary, options.GroupBy),
+		})
+		return
+	}
+	if kanowins.ConfigFor(request.TeamID).Enabled("threaded_summary") {
+		channel := summaryDestination(request)
+		err = explainChannel(ctx, request, channel, postThreadedSummary(ctx, channel, options, winsSummary))
+		return
+	}
+	if len(winsSummary) > snippetThreshold() {
+		channel := summaryDestination(request)
+		err = uploadFile(ctx, channel, "wins-summary-"+time.Now().Format("2006-01-02")+".txt", "text", []byte(kanowins.SummaryText(winsSummary)), "")
+		if err != nil {
+			err = explainChannel(ctx, request, channel, err)
+			return
+		}
+		err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"text": fmt.Sprintf("%s, WINS count: %d — full summary attached.", header, len(winsSummary)),
+		})
+		return
+	}
+	heading := fmt.Sprintf("%s (%d)", header, len(winsSummary))
+	blocks := kanowins.BuildHeadedSummaryBlocks(heading, winsSummary)
+	if len(kanowins.ConfigFor(request.TeamID).Categories) > 0 {
+		blocks = kanowins.BuildCategorySummaryBlocks(heading, winsSummary)
+	}
+	err = postSummary(ctx, request, map[string]interface{}{
+		"text":   fmt.Sprintf("%s, WINS count: %d", header, len(winsSummary)),
+		"blocks": blocks,
+	})
+	return
+}
+
+// summaryChannel returns the channel teamID's summaries are posted in for
+// the whole team to see, the summary_channel of its SLACK_TEAM_CONFIG entry
+// or SUMMARY_CHANNEL for a workspace without one. Each workspace needs its
+// own, another's channel is never found with its token
+func summaryChannel(teamID string) string {
+	if config, ok := slack.Teams()[teamID]; ok {
+		return config.SummaryChannel
+	}
+	return os.Getenv("SUMMARY_CHANNEL")
+}
+
+// summaryDestination returns the channel a summary file or thread is
+// shared in, the summary channel or else the one the command was run in
+func summaryDestination(request Request) string {
+	if channel := summaryChannel(request.TeamID); channel != "" {
+		return channel
+	}
+	return request.ChannelID
+}
+
+// explainChannel tells the caller when Slack can't find channel or the app
+// isn't in it rather than failing the summary, any other err is returned as
+// is
+func explainChannel(ctx context.Context, request Request, channel string, err error) error {
+	var serr *slack.Error
+	if !errors.As(err, &serr) || (serr.Code != "channel_not_found" && serr.Code != "not_in_channel") {
+		return err
+	}
+	logging.Printf("explainChannel - %s (%s) error: %v", serr.Method, channel, err)
+	return postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          fmt.Sprintf(":warning: Couldn't post the summary to `%s`, check it is a channel ID the app has been added to.", channel),
+	})
+}
+
+// postSummary posts a summary message for the whole team to see in the
+// summaryChannel with chat.postMessage, or back through the response_url
+// when there is none
+func postSummary(ctx context.Context, request Request, message map[string]interface{}) error {
+	channel := summaryChannel(request.TeamID)
+	if channel == "" {
+		return postMessage(ctx, request.ResponseURL, message)
+	}
+	message["channel"] = channel
+	_, err := slack.PostMessage(ctx, message)
+	return explainChannel(ctx, request, channel, err)
+}
+
+// postMarkdownSummary posts the summary as Markdown in a code block, or as a
+// file past snippetThreshold
+func postMarkdownSummary(ctx context.Context, request Request, header string, winsSummary []kanowins.WinSummary) error {
+	report := kanowins.SummaryMarkdown(winsSummary)
+	if len(winsSummary) > snippetThreshold() {
+		channel := summaryDestination(request)
+		err := uploadFile(ctx, channel, "wins-summary-"+time.Now().Format("2006-01-02")+".md", "markdown", []byte(report), "")
+		if err != nil {
+			return explainChannel(ctx, request, channel, err)
+		}
+		return postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"text": fmt.Sprintf("%s, WINS count: %d — Markdown report attached.", header, len(winsSummary)),
+		})
+	}
+	return postSummary(ctx, request, map[string]interface{}{
+		"text": fmt.Sprintf("%s, WINS count: %d\n```\n%s```", header, len(winsSummary), kanowins.SanitizeMrkdwn(report)),
+	})
+}
+
+// inlineCSV renders wins as CSV within max bytes, returning how many rows
+// fit
+func inlineCSV(wins []kanowins.Win, max int) (string, int, error) {
+	header, err := kanowins.CSV(nil)
+	if err != nil {
+		return "", 0, err
+	}
+	content := string(header)
+	for i, win := range wins {
+		row, err := kanowins.CSV([]kanowins.Win{win})
+		if err != nil {
+			return "", 0, err
+		}
+		if len(content)+len(row)-len(header) > max {
+			return content, i, nil
+		}
+		content += string(row[len(header):])
+	}
+	return content, len(wins), nil
+}
+
+// postInlineCSV posts up to maxInlineCSV wins as CSV in a code block, for
+// copying into a sheet without a file upload. A triple backtick in a WIN is
+// broken up so it can't close the block early
+func postInlineCSV(ctx context.Context, request Request, header string, wins []kanowins.Win) error {
+	content, rows, err := inlineCSV(wins, maxInlineCSV)
+	if err != nil {
+		return err
+	}
+	content = strings.Replace(kanowins.SanitizeMrkdwn(content), "```", "``\u200b`", -1)
+	text := fmt.Sprintf("%s, WINS count: %d\n```\n%s```", header, len(wins), content)
+	if rows < len(wins) {
+		text += fmt.Sprintf("\nShowing %d of %d — run `/wins export` for all.", rows, len(wins))
+	}
+	return postSummary(ctx, request, map[string]interface{}{
+		"text": text,
+	})
+}
+
+// snippetThreshold returns how many WINS a summary shows inline from
+// SUMMARY_SNIPPET_THRESHOLD, defaulting to SUMMARY_MAX
+func snippetThreshold() int {
+	value := os.Getenv("SUMMARY_SNIPPET_THRESHOLD")
+	if value == "" {
+		return kanowins.SummaryMax()
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold <= 0 {
+		logging.Printf("snippetThreshold - invalid SUMMARY_SNIPPET_THRESHOLD %q, using SUMMARY_MAX", value)
+		return kanowins.SummaryMax()
+	}
+	return threshold
+}
+
+// summaryHeader renders the SUMMARY_HEADER template, `{window}` replaced by
+// the summarised period, prefixed with SUMMARY_EMOJI when it is set
+func summaryHeader(window string) string {
+	template := os.Getenv("SUMMARY_HEADER")
+	if template == "" {
+		template = defaultSummaryHeader
+	}
+	header := strings.Replace(template, "{window}", window, -1)
+	if emoji := os.Getenv("SUMMARY_EMOJI"); emoji != "" {
+		header = emoji + " " + header
+	}
+	return header
+}
+
+// postThreadedSummary posts a parent summary message to channelID then each
+// WIN, up to maxThreadReplies, as a reply in its thread, threadReplyDelay
+// apart. slack.Do still waits out a 429
+func postThreadedSummary(ctx context.Context, channelID string, options summaryOptions, winsSummary []kanowins.WinSummary) error {
+	text := fmt.Sprintf("Weekly WINS summary for %s, WINS count: %d", options.describe(), len(winsSummary))
+	max := kanowins.SummaryMax()
+	if max > maxThreadReplies {
+		max = maxThreadReplies
+	}
+	if len(winsSummary) > max {
+		text += fmt.Sprintf(", the first %d are in the thread", max)
+		winsSummary = winsSummary[:max]
+	}
+	ts, err := slack.PostMessage(ctx, map[string]interface{}{
+		"channel": channelID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+	for i, win := range winsSummary {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(threadReplyDelay):
+			}
+		}
+		_, err = slack.PostMessage(ctx, map[string]interface{}{
+			"channel":   channelID,
+			"thread_ts": ts,
+			"text":      fmt.Sprintf("%s — %s", kanowins.SanitizeMrkdwn(win.Who), kanowins.SanitizeMrkdwn(win.Title)),
+			"blocks":    kanowins.BuildWinBlocks(win),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countText renders the WIN count, broken down by category when categories
+// are configured
+func countText(wins []kanowins.Win, period string, categorised bool) string {
+	lines := []string{fmt.Sprintf("There are %d WINS logged for %s :tada:", len(wins), period)}
+	if !categorised || len(wins) == 0 {
+		return lines[0]
+	}
+	counts := map[string]int{}
+	for _, win := range wins {
+		category := win.Category
+		if category == "" {
+			category = "Uncategorised"
+		}
+		counts[category]++
+	}
+	categories := []string{}
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	for _, category := range categories {
+		lines = append(lines, fmt.Sprintf("• %s: %d", category, counts[category]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func getCount(ctx context.Context, request Request, options summaryOptions) (err error) {
+	// post just the number of WINS in the window
+	wins, err := loadWins(request.TeamID, options.IncludeArchived)
+	if err != nil {
+		return
+	}
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"text": countText(filterWins(wins, options, time.Now()), options.describe(), len(kanowins.ConfigFor(request.TeamID).Categories) > 0),
+	})
+	return
+}
+
+func giveKudos(teamID, winID string) (err error) {
+	// add one kudos to the team's WIN
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.AddKudos(teamID, winID)
+	return
+}
+
+func addReaction(teamID, winID, emoji string) (err error) {
+	// count one emoji reaction on the team's WIN
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.AddReaction(teamID, winID, emoji)
+	return
+}
+
+func reassignWin(request Request, winID, who string) (err error) {
+	// change who the caller's WIN is for
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.Reassign(winID, request.UserID, who)
+	return
+}
+
+// matchCategory returns the configured category name matches, ignoring case
+func matchCategory(name string, categories []string) (string, bool) {
+	for _, category := range categories {
+		if strings.EqualFold(category, strings.TrimSpace(name)) {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+func recategorizeWin(request Request, winID, category string) (err error) {
+	// change the category of the caller's WIN
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.Recategorize(winID, request.UserID, category)
+	return
+}
+
+func setupTable(ctx context.Context, request Request) (err error) {
+	// create the WINs table on a first deploy that is missing it, replying
+	// through the response_url since creating it outlasts Slack's 3 seconds
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	if err = db.EnsureTable(); err != nil {
+		return
+	}
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          fmt.Sprintf("The WINS table `%s` is ready.", os.Getenv("TABLE_NAME")),
+	})
+	return
+}
+
+func purgeWins(request Request) (count int, err error) {
+	// delete every WIN of the caller's workspace
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	count, err = db.Purge(request.TeamID)
+	return
+}
+
+func renewWin(request Request, winID string, days int) (err error) {
+	// extend the TTL of the caller's WIN
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.Renew(winID, request.UserID, days)
+	return
+}
+
+func getMine(ctx context.Context, request Request) (err error) {
+	// list only the caller's own WINS
+	mine, err := getUserWins(request.UserID)
+	if err != nil {
+		return
+	}
+	if len(mine) == 0 {
+		err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"response_type": "ephemeral",
+			"text":          "You haven't logged any WINS yet this week, use `/wins` to submit one!",
+		})
+		return
+	}
+	cursor := kanowins.PageCursor{List: kanowins.ListMine}
+	heading, winsSummary := kanowins.Listing(cursor, mine, time.Now())
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          fmt.Sprintf("Your WINS: %d", len(mine)),
+		"blocks":        kanowins.BuildPageBlocks(heading, winsSummary, cursor, kanowins.PageSize()),
+	})
+	return
+}
+
+func searchWins(ctx context.Context, request Request, term string) (err error) {
+	// list the current WINS mentioning term, newest first
+	wins, err := getWins(request.TeamID)
+	if err != nil {
+		return
+	}
+	matches := kanowins.MatchWins(wins, term)
+	if len(matches) == 0 {
+		err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"response_type": "ephemeral",
+			"text":          fmt.Sprintf("No WINS mention \"%s\".", kanowins.SanitizeMrkdwn(term)),
+		})
+		return
+	}
+	cursor := kanowins.PageCursor{List: kanowins.ListSearch, Term: term}
+	heading, winsSummary := kanowins.Listing(cursor, wins, time.Now())
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          fmt.Sprintf("WINS mentioning \"%s\": %d", kanowins.SanitizeMrkdwn(term), len(matches)),
+		"blocks":        kanowins.BuildPageBlocks(heading, winsSummary, cursor, kanowins.PageSize()),
+	})
+	return
+}
+
+// LeaderboardEntry is one submitter's WIN count on the leaderboard
+type LeaderboardEntry struct {
+	UserID   string
+	UserName string
+	Count    int
+}
+
+// rankLeaderboard counts WINs per submitter, most WINs first with ties in
+// alphabetical order, anonymous WINs are counted together as Anonymous
+func rankLeaderboard(wins []kanowins.Win) []LeaderboardEntry {
+	byUser := map[string]*LeaderboardEntry{}
+	for _, win := range wins {
+		userID := win.UserID
+		if win.Anonymous {
+			userID = ""
+		}
+		if entry, ok := byUser[userID]; ok {
+			entry.Count++
+			continue
+		}
+		byUser[userID] = &LeaderboardEntry{UserID: userID, UserName: win.Submitter(), Count: 1}
+	}
+	entries := []LeaderboardEntry{}
+	for _, entry := range byUser {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].UserName < entries[j].UserName
+	})
+	return entries
+}
+
+func getLeaderboard(ctx context.Context, request Request) (err error) {
+	// rank contributors of the WINS still within TTL
+	current, err := getWins(request.TeamID)
+	if err != nil {
+		return
+	}
+	medals := []string{":first_place_medal:", ":second_place_medal:", ":third_place_medal:"}
+	lines := []string{"*WINS leaderboard*"}
+	entries := rankLeaderboard(current)
+	userIDs := []string{}
+	for _, entry := range entries {
+		userIDs = append(userIDs, entry.UserID)
+	}
+	names := slack.DisplayNames(ctx, userIDs)
+	for i, entry := range entries {
+		rank := fmt.Sprintf("%d.", i+1)
+		if i < len(medals) {
+			rank = medals[i]
+		}
+		name := entry.UserName
+		if displayName, ok := names[entry.UserID]; ok {
+			name = displayName
+		}
+		lines = append(lines, fmt.Sprintf("%s %s — %d", rank, kanowins.SanitizeMrkdwn(name), entry.Count))
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "No WINS logged yet.")
+	}
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"text": strings.Join(lines, "\n"),
+	})
+	return
+}
+
+func getRandom(ctx context.Context, request Request) (err error) {
+	// spotlight one current WIN picked at random
+	current, err := getWins(request.TeamID)
+	if err != nil {
+		return
+	}
+	if len(current) == 0 {
+		err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"response_type": "ephemeral",
+			"text":          "There are no WINS to spotlight yet, use `/wins` to submit one!",
+		})
+		return
+	}
+	picker := rand.New(rand.NewSource(time.Now().UnixNano()))
+	win := kanowins.Summarize(current)[picker.Intn(len(current))]
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "in_channel",
+		"text":          fmt.Sprintf("WIN spotlight: %s — %s", kanowins.SanitizeMrkdwn(win.Who), kanowins.SanitizeMrkdwn(win.Title)),
+		"blocks":        kanowins.BuildSpotlightBlocks(":sparkles: WIN spotlight :sparkles:", win),
+	})
+	return
+}
+
+// topWin returns the WIN with the most kudos, the most recent one winning a
+// tie, and false when no WIN has any kudos
+func topWin(wins []kanowins.Win) (kanowins.Win, bool) {
+	top := kanowins.Win{}
+	for _, win := range wins {
+		if win.Kudos > top.Kudos || (win.Kudos == top.Kudos && win.CreatedAt.After(top.CreatedAt)) {
+			top = win
+		}
+	}
+	return top, top.Kudos > 0
+}
+
+func getTop(ctx context.Context, request Request) (err error) {
+	// crown the most celebrated current WIN
+	current, err := getWins(request.TeamID)
+	if err != nil {
+		return
+	}
+	win, ok := topWin(current)
+	if !ok {
+		text := "No WIN has any kudos yet, hit :clap: Kudos on a summary to celebrate one!"
+		if len(current) == 0 {
+			text = "There are no WINS yet, use `/wins` to submit one!"
+		}
+		err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"response_type": "ephemeral",
+			"text":          text,
+		})
+		return
+	}
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "in_channel",
+		"text":          fmt.Sprintf("WIN of the week: %s — %s", kanowins.SanitizeMrkdwn(win.Who), kanowins.SanitizeMrkdwn(win.Title)),
+		"blocks":        kanowins.BuildSpotlightBlocks(":trophy: WIN of the week :trophy:", kanowins.Summarize([]kanowins.Win{win})[0]),
+	})
+	return
+}
+
+// maxStatsBar is the length of the bar for the busiest day in `/wins stats`
+const maxStatsBar = 20
+
+// statsText renders a histogram of WINS per day over the last days days in
+// location, with the total and busiest day
+func statsText(wins []kanowins.Win, days int, now time.Time, location *time.Location) string {
+	today := now.In(location)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, location)
+	first := today.AddDate(0, 0, 1-days)
+	counts := make([]int, days)
+	total := 0
+	for _, win := range wins {
+		created := win.CreatedAt.In(location)
+		if created.Before(first) {
+			continue
+		}
+		// round rather than truncate, days either side of a DST change are
+		// 23 or 25 hours long
+		day := int((time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, location).Sub(first).Hours() + 12) / 24)
+		if day >= 0 && day < days {
+			counts[day]++
+			total++
+		}
+	}
+	busiest := 0
+	for day, count := range counts {
+		if count > counts[busiest] {
+			busiest = day
+		}
+	}
+	lines := []string{fmt.Sprintf("*WINS per day, last %s*", formatWindow(time.Duration(days)*24*time.Hour)), "```"}
+	for day, count := range counts {
+		bar := 0
+		if counts[busiest] > 0 {
+			bar = (count*maxStatsBar + counts[busiest] - 1) / counts[busiest]
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %d", first.AddDate(0, 0, day).Format("Mon 02 Jan"), strings.Repeat("█", bar), count))
+	}
+	lines = append(lines, "```", fmt.Sprintf("Total: %d", total))
+	if total > 0 {
+		lines = append(lines, fmt.Sprintf("Busiest day: %s (%d)", first.AddDate(0, 0, busiest).Format("Mon 2 Jan"), counts[busiest]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func getStats(ctx context.Context, request Request) (err error) {
+	// chart WINS per day over the TTL window
+	current, err := getWins(request.TeamID)
+	if err != nil {
+		return
+	}
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"text": statsText(current, kanowins.ConfigFor(request.TeamID).TTLDays, time.Now(), kanowins.Location()),
+	})
+	return
+}
+
+// streakDays counts the consecutive days up to now, as days fall in loc,
+// with at least one of wins. A streak still counts through yesterday while
+// today has no WIN yet, any other day without one ends it
+func streakDays(wins []kanowins.Win, now time.Time, loc *time.Location) int {
+	days := map[string]bool{}
+	for _, win := range wins {
+		days[win.CreatedAt.In(loc).Format("2006-01-02")] = true
+	}
+	now = now.In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if !days[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for days[day.Format("2006-01-02")] {
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+// streakText encourages a streak of days logging WINS
+func streakText(name string, streak int) string {
+	switch {
+	case streak == 0:
+		return fmt.Sprintf("%s no WIN streak going, log a WIN today to start one!", name)
+	case streak == 1:
+		return fmt.Sprintf(":seedling: %s a 1 day WIN streak, come back tomorrow to keep it going!", name)
+	default:
+		return fmt.Sprintf(":fire: %s a %d day WIN streak, keep it up!", name, streak)
+	}
+}
+
+func getStreak(ctx context.Context, request Request, who string) (err error) {
+	// count the consecutive days the caller logged WINS, their private ones
+	// included, or who had shared WINS logged for them
+	name := "You have"
+	var matches []kanowins.Win
+	if who == "" {
+		matches, err = getUserWins(request.UserID)
+		matches = visible(inTeam(matches, request.TeamID))
+	} else {
+		name = kanowins.SanitizeMrkdwn(kanowins.CleanWho(who)) + " has"
+		var current []kanowins.Win
+		current, err = getWins(request.TeamID)
+		for _, win := range current {
+			if kanowins.WhoKey(win.Who) == kanowins.WhoKey(who) {
+				matches = append(matches, win)
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	err = postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"response_type": "ephemeral",
+		"text":          streakText(name, streakDays(matches, time.Now(), kanowins.Location())),
+	})
+	return
+}
+
+func exportWins(ctx context.Context, request Request) (err error) {
+	// upload all current WINS as a CSV file to the invoking channel
+	current, err := getWins(request.TeamID)
+	if err != nil {
+		return
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].CreatedAt.After(current[j].CreatedAt)
+	})
+	content, err := kanowins.CSV(current)
+	if err != nil {
+		return
+	}
+	err = uploadFile(ctx, request.ChannelID, "wins-"+time.Now().Format("2006-01-02")+".csv", "csv", content, "")
+	return
+}
+
+// exportPage is one page of `/wins export json`, Next is the token to pass
+// for the following page, empty after the last
+type exportPage struct {
+	Wins []kanowins.Win `json:"wins"`
+	Next string         `json:"next,omitempty"`
+}
+
+func exportJSON(ctx context.Context, request Request, cursor string) (err error) {
+	// upload one page of the team's current, shared WINS with every field as
+	// a JSON file
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	wins, next, err := db.GetPage(cursor, exportPageSize)
+	if err != nil {
+		return
+	}
+	page := exportPage{Wins: shared(visible(unarchived(unexpired(inTeam(wins, request.TeamID))))), Next: next}
+	sortWins(page.Wins, "newest")
+	for i, win := range page.Wins {
+		if win.Anonymous {
+			page.Wins[i].UserID = ""
+			page.Wins[i].UserName = win.Submitter()
+		}
+	}
+	content, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return
+	}
+	comment := ""
+	if next != "" {
+		comment = "There are more WINS, run `/wins export json " + next + "` for the next page."
+	}
+	err = uploadFile(ctx, request.ChannelID, "wins-"+time.Now().Format("2006-01-02")+".json", "json", content, comment)
+	return
+}
+
+// uploadFile shares content as a file in the given channel, with comment
+// posted alongside it when it is set
+func uploadFile(ctx context.Context, channelID, filename, filetype string, content []byte, comment string) error {
+	form := url.Values{
+		"channels": {channelID},
+		"filename": {filename},
+		"filetype": {filetype},
+		"content":  {string(content)},
+	}
+	if comment != "" {
+		form.Set("initial_comment", comment)
+	}
+	return slack.CallForm(ctx, "files.upload", form)
+}
+
+// postMessage sends a message back to Slack via the given response URL
+func postMessage(ctx context.Context, responseURL string, message map[string]interface{}) (err error) {
+	body, _ := json.Marshal(message)
+	resp, err := slack.Do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", responseURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token := slack.Token(ctx); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var respBody map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	logging.Printf("postMessage - response Body: %v", respBody)
+	return
+}
+
+// requiredEnv are the environment variables KanowinsCommand can't run
+// without. SLACK_ACCESS_TOKEN may come from the team's config instead
+var requiredEnv = []string{"REGION", "TABLE_NAME", "SLACK_SIGNING_SECRET"}
+
+func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
+	if os.Getenv("SLACK_ACCESS_TOKEN") == "" {
+		logging.Printf("main - SLACK_ACCESS_TOKEN is unset, Slack calls for workspaces without a SLACK_TEAM_CONFIG token will fail")
+	}
 	lambda.Start(Handler)
 }
@@ -0,0 +1,1621 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/dialog"
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+// fakeDynamo points the DynamoDB client at a server answering every call
+// with respond, `{}` when it is nil. A reply with a `__type` is an error
+func fakeDynamo(t *testing.T, respond func(target, body string) string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := new(strings.Builder)
+		if _, err := io.Copy(body, r.Body); err != nil {
+			t.Errorf("reading DynamoDB request: %v", err)
+		}
+		reply := "{}"
+		if respond != nil {
+			reply = respond(strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."), body.String())
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if strings.Contains(reply, `"__type"`) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DYNAMODB_ENDPOINT", server.URL)
+	t.Setenv("REGION", "us-west-1")
+	t.Setenv("TABLE_NAME", "wins")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+}
+
+// itemsReply is a Scan or Query response body holding wins
+func itemsReply(t *testing.T, wins ...kanowins.Win) string {
+	t.Helper()
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, win := range wins {
+		item, err := dynamodbattribute.MarshalMap(win)
+		if err != nil {
+			t.Fatalf("MarshalMap error: %v", err)
+		}
+		items = append(items, item)
+	}
+	body, err := jsonutil.BuildJSON(&dynamodb.ScanOutput{Items: items})
+	if err != nil {
+		t.Fatalf("BuildJSON error: %v", err)
+	}
+	return string(body)
+}
+
+// responses stands in for a response_url, returning its URL and the
+// messages posted to it
+func responses(t *testing.T) (string, *[]map[string]interface{}) {
+	t.Helper()
+	posted := &[]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decoding posted message: %v", err)
+		}
+		*posted = append(*posted, message)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok": true}`)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, posted
+}
+
+// signed returns a slash command request posting form, signed with secret
+func signed(secret string, form url.Values) ProxyRequest {
+	return signedBody(secret, form.Encode())
+}
+
+// signedBody returns a request posting body as is, signed with secret
+func signedBody(secret, body string) ProxyRequest {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	return ProxyRequest{
+		HTTPMethod: "POST",
+		Headers: map[string]string{
+			"X-Slack-Request-Timestamp": ts,
+			"X-Slack-Signature":         "v0=" + hex.EncodeToString(mac.Sum(nil)),
+		},
+		Body: body,
+	}
+}
+
+// command returns the form Slack posts for `/wins text`
+func command(text string) url.Values {
+	return url.Values{
+		"command":   {"/wins"},
+		"team_id":   {"T1"},
+		"user_id":   {"U1"},
+		"user_name": {"alice"},
+		"text":      {text},
+	}
+}
+
+func TestHandlerHelp(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name       string
+		request    ProxyRequest
+		wantStatus int
+		wantUsage  bool
+	}{
+		{"signed", signed("secret", command("help")), 200, true},
+		{"wrong secret", signed("other", command("help")), 401, false},
+		{"unsigned", ProxyRequest{Body: command("help").Encode()}, 401, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := Handler(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := strings.Contains(resp.Body, "/wins summary"); got != tt.wantUsage {
+				t.Errorf("usage in body = %t, want %t: %s", got, tt.wantUsage, resp.Body)
+			}
+		})
+	}
+}
+
+func TestHelpListsRoutes(t *testing.T) {
+	t.Setenv("DISABLED_COMMANDS", "")
+	helped := map[string]bool{}
+	for _, c := range subcommands {
+		helped[c.Name] = true
+		if _, ok := routes[c.Name]; !ok {
+			t.Errorf("help lists %q, which isn't routed", c.Name)
+		}
+	}
+	message := helpMessage("T1")
+	blocks, _ := json.Marshal(message["blocks"])
+	for name := range routes {
+		if !helped[name] {
+			t.Errorf("routed %q is missing from subcommands", name)
+		}
+		if !strings.Contains(string(blocks), "`/wins "+name) {
+			t.Errorf("help blocks don't list %q", name)
+		}
+	}
+}
+
+func TestHelpLeavesOutDisabled(t *testing.T) {
+	t.Setenv("DISABLED_COMMANDS", "export,help")
+	message, _ := json.Marshal(helpMessage("T1"))
+	if strings.Contains(string(message), "/wins export") {
+		t.Errorf("help %s lists the disabled export", message)
+	}
+	if !strings.Contains(string(message), "/wins help") {
+		t.Errorf("help %s leaves out help, which can't be disabled", message)
+	}
+}
+
+func TestInPeriodTeamTimezone(t *testing.T) {
+	t.Setenv("TEAM_TIMEZONE", "America/New_York")
+	from, err := parseDate("2024-02-01")
+	if err != nil {
+		t.Fatalf("parseDate error: %v", err)
+	}
+	to, err := parseDate("2024-02-01")
+	if err != nil {
+		t.Fatalf("parseDate error: %v", err)
+	}
+	options := summaryOptions{From: from, To: to}
+	tests := []struct {
+		name    string
+		created time.Time
+		want    bool
+	}{
+		{"late that evening in New York", time.Date(2024, 2, 2, 3, 0, 0, 0, time.UTC), true},
+		{"the evening before in New York", time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC), false},
+		{"the next morning in New York", time.Date(2024, 2, 2, 6, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inPeriod(kanowins.Win{CreatedAt: tt.created}, options, time.Now()); got != tt.want {
+				t.Errorf("inPeriod(%s) = %t, want %t", tt.created, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreakDays(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation error: %v", err)
+	}
+	// 14:00 on 10 February in New York
+	now := time.Date(2024, 2, 10, 19, 0, 0, 0, time.UTC)
+	at := func(day, hour int) kanowins.Win {
+		return kanowins.Win{CreatedAt: time.Date(2024, 2, day, hour, 0, 0, 0, loc)}
+	}
+	tests := []struct {
+		name string
+		wins []kanowins.Win
+		want int
+	}{
+		{"none", nil, 0},
+		{"today", []kanowins.Win{at(10, 9)}, 1},
+		{"through yesterday", []kanowins.Win{at(9, 9), at(8, 9)}, 2},
+		{"three days", []kanowins.Win{at(10, 9), at(9, 23), at(8, 0)}, 3},
+		{"gap resets", []kanowins.Win{at(10, 9), at(8, 9)}, 1},
+		{"ended two days ago", []kanowins.Win{at(8, 9), at(7, 9)}, 0},
+		// 23:30 on the 9th in New York is already the 10th in UTC
+		{"late evening counts for its own day", []kanowins.Win{at(10, 9), {CreatedAt: time.Date(2024, 2, 10, 4, 30, 0, 0, time.UTC)}}, 2},
+		{"several on one day", []kanowins.Win{at(10, 9), at(10, 10)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streakDays(tt.wins, now, loc); got != tt.want {
+				t.Errorf("streakDays = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStreak(t *testing.T) {
+	now := time.Now()
+	mine := []kanowins.Win{
+		{WinID: "1", TeamID: "T1", UserID: "U1", Who: "Bob", CreatedAt: now},
+		{WinID: "2", TeamID: "T1", UserID: "U1", Who: "Bob", Visibility: kanowins.VisibilityPrivate, CreatedAt: now.AddDate(0, 0, -1)},
+		{WinID: "3", TeamID: "T2", UserID: "U1", Who: "Bob", CreatedAt: now.AddDate(0, 0, -2)},
+	}
+	others := []kanowins.Win{
+		{WinID: "4", TeamID: "T1", UserID: "U2", Who: "Carol", CreatedAt: now},
+		{WinID: "5", TeamID: "T2", UserID: "U3", Who: "Carol", CreatedAt: now.AddDate(0, 0, -1)},
+		{WinID: "6", TeamID: "T1", UserID: "U2", Who: "Carol", Visibility: kanowins.VisibilityPrivate, CreatedAt: now.AddDate(0, 0, -1)},
+	}
+	fakeDynamo(t, func(target, body string) string {
+		switch target {
+		case "Query":
+			return itemsReply(t, mine...)
+		case "Scan":
+			return itemsReply(t, append(append([]kanowins.Win{}, mine...), others...)...)
+		}
+		return "{}"
+	})
+	tests := []struct {
+		name string
+		who  string
+		want string
+	}{
+		{"own WINS including private ones, in this team only", "", "a 2 day"},
+		{"shared WINS for who, in this team only", "Carol", "a 1 day"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, posted := responses(t)
+			request := Request{TeamID: "T1", UserID: "U1", ResponseURL: url}
+			if err := getStreak(context.Background(), request, tt.who); err != nil {
+				t.Fatalf("getStreak error: %v", err)
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(*posted))
+			}
+			if text, _ := (*posted)[0]["text"].(string); !strings.Contains(text, tt.want) {
+				t.Errorf("streak text = %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
+
+// fakeSlack points the Slack Web API at a server answering each method
+// with respond, returning the methods called
+func fakeSlack(t *testing.T, respond func(method, body string) string) *[]string {
+	t.Helper()
+	called := &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := new(strings.Builder)
+		if _, err := io.Copy(body, r.Body); err != nil {
+			t.Errorf("reading Slack request: %v", err)
+		}
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		*called = append(*called, method)
+		reply := `{"ok": true}`
+		if respond != nil {
+			reply = respond(method, body.String())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+	return called
+}
+
+func TestHandlerPartialForm(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	called := fakeSlack(t, func(method, body string) string {
+		if strings.Contains(body, `"trigger_id":""`) {
+			return `{"ok": false, "error": "invalid_trigger"}`
+		}
+		return `{"ok": true}`
+	})
+	tests := []struct {
+		name    string
+		missing string
+		notice  bool
+	}{
+		{"missing text", "text", false},
+		{"missing trigger_id", "trigger_id", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*called = nil
+			url, posted := responses(t)
+			form := command("Alice")
+			form.Set("trigger_id", "1.2.3")
+			form.Set("response_url", url)
+			form.Del(tt.missing)
+			resp, err := Handler(context.Background(), signed("secret", form))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if resp.StatusCode != 200 {
+				t.Errorf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+			}
+			if len(*called) != 1 || (*called)[0] != "dialog.open" {
+				t.Errorf("Slack methods called = %v, want [dialog.open]", *called)
+			}
+			if got := len(*posted) == 1 && strings.Contains((*posted)[0]["text"].(string), "invalid_trigger"); got != tt.notice {
+				t.Errorf("posted %v, want a notice: %t", *posted, tt.notice)
+			}
+		})
+	}
+}
+
+func TestGetSummaryRecentWins(t *testing.T) {
+	now := time.Now()
+	wins := []kanowins.Win{
+		{WinID: "1", TeamID: "T1", ChannelID: "C1", Who: "Alice", Title: "Logged an hour ago", CreatedAt: now.Add(-time.Hour)},
+		{WinID: "2", TeamID: "T1", ChannelID: "C1", Who: "Bob", Title: "Logged yesterday", CreatedAt: now.Add(-13 * time.Hour)},
+		{WinID: "3", TeamID: "T1", ChannelID: "C1", Who: "Carol", Title: "Logged last month", CreatedAt: now.AddDate(0, -1, 0)},
+	}
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return itemsReply(t, wins...)
+		}
+		return "{}"
+	})
+	url, posted := responses(t)
+	request := Request{TeamID: "T1", ChannelID: "C1", UserID: "U1", ResponseURL: url}
+	options, err := parseSummaryOptions(nil, request)
+	if err != nil {
+		t.Fatalf("parseSummaryOptions error: %v", err)
+	}
+	if _, err := getSummary(context.Background(), request, options); err != nil {
+		t.Fatalf("getSummary error: %v", err)
+	}
+	if len(*posted) != 1 {
+		t.Fatalf("posted %d messages, want 1", len(*posted))
+	}
+	message, _ := json.Marshal((*posted)[0])
+	for _, win := range wins {
+		if got, want := strings.Contains(string(message), win.Title), win.WinID != "3"; got != want {
+			t.Errorf("summary shows %q = %t, want %t", win.Title, got, want)
+		}
+	}
+}
+
+func TestHandlerDialogOpenFailed(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"expired trigger", `{"ok": false, "error": "expired_trigger_id"}`, "Couldn't open the WIN form (`expired_trigger_id`), please try again."},
+		{"opened", `{"ok": true}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeSlack(t, func(method, body string) string { return tt.reply })
+			url, posted := responses(t)
+			form := command("Alice")
+			form.Set("trigger_id", "1.2.3")
+			form.Set("response_url", url)
+			resp, err := Handler(context.Background(), signed("secret", form))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if resp.StatusCode != 200 {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			got := ""
+			if len(*posted) > 0 {
+				got, _ = (*posted)[0]["text"].(string)
+			}
+			if got != tt.want {
+				t.Errorf("posted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerMissingTable(t *testing.T) {
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return `{"__type": "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException", "message": "Requested resource not found"}`
+		}
+		return "{}"
+	})
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	resp, err := Handler(context.Background(), signed("secret", command("summary")))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if !strings.Contains(resp.Body, "storage isn't set up yet") {
+		t.Errorf("body = %s, want the storage missing notice", resp.Body)
+	}
+}
+
+func TestQuotedArgs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{`"Alice" "Shipped the release"`, []string{"Alice", "Shipped the release"}},
+		{`“Alice” “Shipped the release” “On time”`, []string{"Alice", "Shipped the release", "On time"}},
+		{`"Alice" "" " Shipped "`, []string{"Alice", "Shipped"}},
+		{`Alice shipped the release`, []string{}},
+	}
+	for _, tt := range tests {
+		if got := quotedArgs(tt.text); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("quotedArgs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerQuickWin(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name       string
+		text       string
+		wantStored bool
+		wantDialog bool
+	}{
+		{"quoted", `"Bob" "Shipped the release"`, true, false},
+		{"one quoted argument", `"Bob"`, false, true},
+		{"unquoted", `Bob`, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := []string{}
+			fakeDynamo(t, func(target, body string) string {
+				if target == "PutItem" && !strings.Contains(body, "request#") {
+					stored = append(stored, body)
+				}
+				return "{}"
+			})
+			called := fakeSlack(t, nil)
+			form := command(tt.text)
+			form.Set("trigger_id", "1.2.3")
+			resp, err := Handler(context.Background(), signed("secret", form))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if got := len(stored) == 1 && strings.Contains(stored[0], "Shipped the release"); got != tt.wantStored {
+				t.Errorf("stored %q, want the WIN stored: %t", stored, tt.wantStored)
+			}
+			if tt.wantStored && !strings.Contains(stored[0], `"source":{"S":"quick"}`) {
+				t.Errorf("stored %s, want the quick source", stored[0])
+			}
+			if got := strings.Contains(resp.Body, "Your WIN for Bob was saved"); got != tt.wantStored {
+				t.Errorf("body = %s, want a confirmation: %t", resp.Body, tt.wantStored)
+			}
+			if got := len(*called) == 1 && (*called)[0] == "dialog.open"; got != tt.wantDialog {
+				t.Errorf("Slack methods called = %v, want the dialog opened: %t", *called, tt.wantDialog)
+			}
+		})
+	}
+}
+
+func TestHandlerEncodedBodies(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	stored := []string{}
+	fakeDynamo(t, func(target, body string) string {
+		if target == "PutItem" && !strings.Contains(body, "request#") {
+			stored = append(stored, body)
+		}
+		return "{}"
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		request := signed("secret", command("help"))
+		request.Body = base64.StdEncoding.EncodeToString([]byte(request.Body))
+		request.IsBase64Encoded = true
+		resp, err := Handler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if resp.StatusCode != 200 || !strings.Contains(resp.Body, "/wins summary") {
+			t.Errorf("Handler = %d %s, want the usage", resp.StatusCode, resp.Body)
+		}
+	})
+
+	t.Run("hash in the text", func(t *testing.T) {
+		resp, err := Handler(context.Background(), signed("secret", command(`"Bob" "Closed #123 & more"`)))
+		if err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if !strings.Contains(resp.Body, "Your WIN for Bob was saved") {
+			t.Errorf("body = %s, want a confirmation", resp.Body)
+		}
+		if len(stored) != 1 || !strings.Contains(stored[0], "Closed #123 \\u0026 more") && !strings.Contains(stored[0], "Closed #123 & more") {
+			t.Errorf("stored %q, want the title with its # intact", stored)
+		}
+	})
+}
+
+func TestSubmitDialogState(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	var opened dialog.Payload
+	fakeSlack(t, func(method, body string) string {
+		if err := json.Unmarshal([]byte(body), &opened); err != nil {
+			t.Errorf("decoding %s: %v", method, err)
+		}
+		return `{"ok": true}`
+	})
+	form := command("Bob")
+	form.Set("channel_id", "C1")
+	form.Set("channel_name", "general")
+	form.Set("trigger_id", "1.2.3")
+	if _, err := Handler(context.Background(), signed("secret", form)); err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	want := kanowins.DialogState{TeamID: "T1", ChannelID: "C1", ChannelName: "general", TriggerID: "1.2.3"}
+	if got := kanowins.DecodeDialogState(opened.Dialog.State); got != want {
+		t.Errorf("dialog state = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetSummaryArchived(t *testing.T) {
+	now := time.Now()
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return itemsReply(t,
+				kanowins.Win{WinID: "1", TeamID: "T1", Who: "Alice", Title: "Still standing", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "2", TeamID: "T1", Who: "Bob", Title: "Archived one", Archived: true, CreatedAt: now.Add(-time.Hour)},
+			)
+		}
+		return "{}"
+	})
+	tests := []struct {
+		name         string
+		args         []string
+		wantArchived bool
+	}{
+		{"default", nil, false},
+		{"include archived", []string{"--include-archived"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, posted := responses(t)
+			request := Request{TeamID: "T1", UserID: "U1", ResponseURL: url}
+			options, err := parseSummaryOptions(tt.args, request)
+			if err != nil {
+				t.Fatalf("parseSummaryOptions error: %v", err)
+			}
+			if _, err := getSummary(context.Background(), request, options); err != nil {
+				t.Fatalf("getSummary error: %v", err)
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(*posted))
+			}
+			message, _ := json.Marshal((*posted)[0])
+			if !strings.Contains(string(message), "Still standing") {
+				t.Errorf("summary %s is missing the unarchived WIN", message)
+			}
+			if got := strings.Contains(string(message), "Archived one"); got != tt.wantArchived {
+				t.Errorf("summary shows the archived WIN = %t, want %t", got, tt.wantArchived)
+			}
+		})
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	routes["boom"] = func(context.Context, Request, []string) (Response, error) {
+		var fields []string
+		return ephemeral(fields[1]), nil
+	}
+	defer delete(routes, "boom")
+	resp, err := Handler(context.Background(), signed("secret", command("boom")))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(resp.Body, "Sorry, something went wrong") {
+		t.Errorf("Handler = %d %s, want an ephemeral apology", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestHandlerSummaryAllowedUsers(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name       string
+		allowed    string
+		userID     string
+		wantDenied bool
+	}{
+		{"unset", "", "U1", false},
+		{"allowed", "U2, U1", "U1", false},
+		{"denied", "U2,U3", "U1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUMMARY_ALLOWED_USERS", tt.allowed)
+			url, _ := responses(t)
+			form := command("summary")
+			form.Set("user_id", tt.userID)
+			form.Set("response_url", url)
+			resp, err := Handler(context.Background(), signed("secret", form))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if got := strings.Contains(resp.Body, "You don't have permission to view summaries."); got != tt.wantDenied {
+				t.Errorf("body = %s, want denied: %t", resp.Body, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestHandlerPreviewCommand(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	var opened dialog.Payload
+	fakeSlack(t, func(method, body string) string {
+		if err := json.Unmarshal([]byte(body), &opened); err != nil {
+			t.Errorf("decoding %s: %v", method, err)
+		}
+		return `{"ok": true}`
+	})
+	form := command("preview Bob")
+	form.Set("trigger_id", "1.2.3")
+	if _, err := Handler(context.Background(), signed("secret", form)); err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if !kanowins.DecodeDialogState(opened.Dialog.State).Preview || opened.Dialog.SubmitLabel != "Preview" {
+		t.Errorf("opened dialog %+v, want a Preview form with the flag in its state", opened.Dialog)
+	}
+	for _, element := range opened.Dialog.Elements {
+		if element.Name == "who" && element.Value != "Bob" {
+			t.Errorf("who = %q, want Bob", element.Value)
+		}
+	}
+}
+
+func TestUndoWin(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		mine        []kanowins.Win
+		wantText    string
+		wantArchive bool
+		wantButton  bool
+	}{
+		{"recent", []kanowins.Win{{WinID: "1", UserID: "U1", Who: "Bob", Title: "Shipped", CreatedAt: now.Add(-5 * time.Minute)}}, "Undone your WIN *Bob* — Shipped.", true, false},
+		{"older than the window", []kanowins.Win{{WinID: "1", UserID: "U1", Who: "Bob", Title: "Shipped", CreatedAt: now.Add(-undoWindow - time.Minute)}}, "Your latest WIN was logged more than 10 minutes ago, undo it anyway?", false, true},
+		{"none", nil, "You don't have any WINS to undo.", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archived := false
+			fakeDynamo(t, func(target, body string) string {
+				switch target {
+				case "Query":
+					return itemsReply(t, tt.mine...)
+				case "UpdateItem":
+					archived = strings.Contains(body, `"S":"1"`) && strings.Contains(body, "archived")
+				}
+				return "{}"
+			})
+			message, err := undoWin(Request{TeamID: "T1", UserID: "U1"}, now)
+			if err != nil {
+				t.Fatalf("undoWin error: %v", err)
+			}
+			if message["text"] != tt.wantText {
+				t.Errorf("text = %q, want %q", message["text"], tt.wantText)
+			}
+			if archived != tt.wantArchive {
+				t.Errorf("archived = %t, want %t", archived, tt.wantArchive)
+			}
+			if _, ok := message["attachments"]; ok != tt.wantButton {
+				t.Errorf("confirmation button = %t, want %t", ok, tt.wantButton)
+			}
+		})
+	}
+}
+
+func TestSummaryHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		emoji    string
+		want     string
+	}{
+		{"default", "", "", "Summary for last 7 days"},
+		{"template", "WINS from the {window}!", "", "WINS from the last 7 days!"},
+		{"repeated placeholder", "{window}: {window}", "", "last 7 days: last 7 days"},
+		{"no placeholder", "Our WINS", "", "Our WINS"},
+		{"emoji", "", ":trophy:", ":trophy: Summary for last 7 days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUMMARY_HEADER", tt.template)
+			t.Setenv("SUMMARY_EMOJI", tt.emoji)
+			if got := summaryHeader(summaryOptions{Window: 7 * 24 * time.Hour}.describe()); got != tt.want {
+				t.Errorf("summaryHeader = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerSearchUsage(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	for _, text := range []string{"search", "search   "} {
+		resp, err := Handler(context.Background(), signed("secret", command(text)))
+		if err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if !strings.Contains(resp.Body, "Tell me what to look for") {
+			t.Errorf("%q: body = %s, want the usage hint", text, resp.Body)
+		}
+	}
+}
+
+func TestHandlerRecategorize(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("WIN_CATEGORIES", "Shipping, Customer")
+	tests := []struct {
+		name        string
+		text        string
+		owner       string
+		wantUpdated bool
+		want        string
+	}{
+		{"valid category", "recategorize w1 customer", "U1", true, "Your WIN is now in Customer."},
+		{"invalid category", "recategorize w1 Sales", "U1", false, "that isn't a WIN category, choose one of: Shipping, Customer"},
+		{"someone else's WIN", "recategorize w1 Customer", "U2", false, "it isn't yours to recategorize"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			fakeDynamo(t, func(target, body string) string {
+				switch target {
+				case "GetItem":
+					return `{"Item":{"win_id":{"S":"w1"},"user_id":{"S":"` + tt.owner + `"},"category":{"S":"Shipping"}}}`
+				case "UpdateItem":
+					if tt.owner != "U1" {
+						return `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`
+					}
+					updated = strings.Contains(body, `"Customer"`)
+				}
+				return "{}"
+			})
+			resp, err := Handler(context.Background(), signed("secret", command(tt.text)))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if updated != tt.wantUpdated {
+				t.Errorf("updated = %t, want %t", updated, tt.wantUpdated)
+			}
+			if !strings.Contains(resp.Body, tt.want) {
+				t.Errorf("body = %s, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSummarySnippet(t *testing.T) {
+	now := time.Now()
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return itemsReply(t,
+				kanowins.Win{WinID: "1", TeamID: "T1", Who: "Alice", Title: "First", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "2", TeamID: "T1", Who: "Bob", Title: "Second", CreatedAt: now.Add(-2 * time.Hour)},
+				kanowins.Win{WinID: "3", TeamID: "T1", Who: "Carol", Title: "Third", CreatedAt: now.Add(-3 * time.Hour)},
+			)
+		}
+		return "{}"
+	})
+	tests := []struct {
+		name       string
+		threshold  string
+		wantUpload bool
+	}{
+		{"at the threshold", "3", false},
+		{"above the threshold", "2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUMMARY_SNIPPET_THRESHOLD", tt.threshold)
+			uploaded := ""
+			called := fakeSlack(t, func(method, body string) string {
+				if method == "files.upload" {
+					uploaded = body
+				}
+				return `{"ok": true}`
+			})
+			responseURL, posted := responses(t)
+			request := Request{TeamID: "T1", ChannelID: "C1", UserID: "U1", ResponseURL: responseURL}
+			options, err := parseSummaryOptions(nil, request)
+			if err != nil {
+				t.Fatalf("parseSummaryOptions error: %v", err)
+			}
+			if _, err := getSummary(context.Background(), request, options); err != nil {
+				t.Fatalf("getSummary error: %v", err)
+			}
+			if got := len(*called) == 1 && (*called)[0] == "files.upload"; got != tt.wantUpload {
+				t.Errorf("Slack methods called = %v, want files.upload: %t", *called, tt.wantUpload)
+			}
+			if tt.wantUpload {
+				form, _ := url.ParseQuery(uploaded)
+				if form.Get("filetype") != "text" || form.Get("channels") != "C1" || !strings.Contains(form.Get("content"), "Third") {
+					t.Errorf("files.upload form = %v, want the whole summary as a text snippet in C1", form)
+				}
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(*posted))
+			}
+			message, _ := json.Marshal((*posted)[0])
+			if got := strings.Contains(string(message), "full summary attached"); got != tt.wantUpload {
+				t.Errorf("message %s says the summary is attached = %t, want %t", message, got, tt.wantUpload)
+			}
+			if got := strings.Contains(string(message), "Third"); got == tt.wantUpload {
+				t.Errorf("message %s lists the WINS inline = %t, want %t", message, got, !tt.wantUpload)
+			}
+		})
+	}
+}
+
+func TestGetSummaryExcludeMe(t *testing.T) {
+	now := time.Now()
+	t.Setenv("WIN_CATEGORIES", "")
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return itemsReply(t,
+				kanowins.Win{WinID: "1", TeamID: "T1", UserID: "U1", Who: "Bob", Title: "Mine this week", Category: "Shipping", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "2", TeamID: "T1", UserID: "U2", Who: "Carol", Title: "Theirs this week", Category: "Shipping", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "3", TeamID: "T1", UserID: "U2", Who: "Dan", Title: "Theirs other category", Category: "Customer", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "4", TeamID: "T1", UserID: "U2", Who: "Erin", Title: "Theirs last month", Category: "Shipping", CreatedAt: now.AddDate(0, -1, 0)},
+			)
+		}
+		return "{}"
+	})
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"default", nil, []string{"Mine this week", "Theirs this week", "Theirs other category"}},
+		{"exclude me", []string{"--exclude-me"}, []string{"Theirs this week", "Theirs other category"}},
+		{"exclude me with category", []string{"--exclude-me", "category=shipping"}, []string{"Theirs this week"}},
+		{"exclude me with window", []string{"60d", "--exclude-me"}, []string{"Theirs this week", "Theirs other category", "Theirs last month"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responseURL, posted := responses(t)
+			request := Request{TeamID: "T1", UserID: "U1", ResponseURL: responseURL}
+			options, err := parseSummaryOptions(tt.args, request)
+			if err != nil {
+				t.Fatalf("parseSummaryOptions error: %v", err)
+			}
+			if _, err := getSummary(context.Background(), request, options); err != nil {
+				t.Fatalf("getSummary error: %v", err)
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(*posted))
+			}
+			message, _ := json.Marshal((*posted)[0])
+			for _, title := range []string{"Mine this week", "Theirs this week", "Theirs other category", "Theirs last month"} {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == title
+				}
+				if got := strings.Contains(string(message), title); got != want {
+					t.Errorf("summary shows %q = %t, want %t", title, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerRoutes(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("DISABLED_COMMANDS", "")
+	var routed []string
+	routes["probe"] = func(ctx context.Context, request Request, fields []string) (Response, error) {
+		routed = fields
+		return ephemeral("probed"), nil
+	}
+	defer delete(routes, "probe")
+	tests := []struct {
+		name       string
+		text       string
+		wantRouted string
+		wantDialog bool
+	}{
+		{"dispatch", "probe one  two", "probe,one,two", false},
+		{"dispatch ignores case", "PROBE one", "PROBE,one", false},
+		{"unknown command", "frobnicate now", "", true},
+		{"default", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routed = nil
+			called := fakeSlack(t, nil)
+			form := command(tt.text)
+			form.Set("trigger_id", "1.2.3")
+			resp, err := Handler(context.Background(), signed("secret", form))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if got := strings.Join(routed, ","); got != tt.wantRouted {
+				t.Errorf("routed fields = %q, want %q", got, tt.wantRouted)
+			}
+			if got := strings.Contains(resp.Body, "probed"); got != (tt.wantRouted != "") {
+				t.Errorf("body = %s, want the route's reply: %t", resp.Body, tt.wantRouted != "")
+			}
+			if got := len(*called) == 1 && (*called)[0] == "dialog.open"; got != tt.wantDialog {
+				t.Errorf("Slack methods called = %v, want the dialog opened: %t", *called, tt.wantDialog)
+			}
+		})
+	}
+	if got := subcommand("probe"); got != "probe" {
+		t.Errorf("subcommand(probe) = %q, want probe", got)
+	}
+	if got := subcommand("frobnicate"); got != "submit" {
+		t.Errorf("subcommand(frobnicate) = %q, want submit", got)
+	}
+}
+
+func TestGetSummaryEmpty(t *testing.T) {
+	now := time.Now()
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return itemsReply(t, kanowins.Win{WinID: "1", TeamID: "T1", Who: "Alice", Title: "Logged last month", CreatedAt: now.AddDate(0, -1, 0)})
+		}
+		return "{}"
+	})
+	for _, threaded := range []string{"", "true"} {
+		t.Setenv("THREADED_SUMMARY", threaded)
+		called := fakeSlack(t, nil)
+		responseURL, posted := responses(t)
+		request := Request{TeamID: "T1", ChannelID: "C1", UserID: "U1", ResponseURL: responseURL}
+		options, err := parseSummaryOptions(nil, request)
+		if err != nil {
+			t.Fatalf("parseSummaryOptions error: %v", err)
+		}
+		if _, err := getSummary(context.Background(), request, options); err != nil {
+			t.Fatalf("getSummary error: %v", err)
+		}
+		if len(*posted) != 1 || len(*called) != 0 {
+			t.Fatalf("posted %d messages and called %v, want just the one message", len(*posted), *called)
+		}
+		message := (*posted)[0]
+		if text, _ := message["text"].(string); !strings.HasSuffix(text, "no WINS logged yet — be the first! :tada:") {
+			t.Errorf("THREADED_SUMMARY=%q: text = %q, want the friendly empty message", threaded, text)
+		}
+		if _, ok := message["blocks"]; ok {
+			t.Errorf("THREADED_SUMMARY=%q: empty summary has blocks %v", threaded, message["blocks"])
+		}
+	}
+}
+
+func TestHandlerPurge(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("ADMIN_USERS", "U9, U1")
+	tests := []struct {
+		name       string
+		userID     string
+		text       string
+		wantPurged bool
+		want       string
+	}{
+		{"not an admin", "U2", "purge confirm", false, "only admins can purge WINS"},
+		{"unconfirmed", "U1", "purge", false, "Run `/wins purge confirm` to go ahead"},
+		{"mistyped confirmation", "U1", "purge yes", false, "Run `/wins purge confirm` to go ahead"},
+		{"confirmed", "U1", "purge Confirm", true, "Purged 2 WINS."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := []string{}
+			fakeDynamo(t, func(target, body string) string {
+				switch target {
+				case "Scan":
+					return itemsReply(t,
+						kanowins.Win{WinID: "w1", TeamID: "T1"},
+						kanowins.Win{WinID: "w2", TeamID: "T1"},
+						kanowins.Win{WinID: "w3", TeamID: "T2"},
+					)
+				case "BatchWriteItem":
+					deleted = append(deleted, body)
+				}
+				return "{}"
+			})
+			form := command(tt.text)
+			form.Set("user_id", tt.userID)
+			resp, err := Handler(context.Background(), signed("secret", form))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if !strings.Contains(resp.Body, tt.want) {
+				t.Errorf("body = %s, want %q", resp.Body, tt.want)
+			}
+			if got := len(deleted) > 0; got != tt.wantPurged {
+				t.Fatalf("BatchWriteItem called = %t, want %t", got, tt.wantPurged)
+			}
+			if tt.wantPurged {
+				batch := strings.Join(deleted, "\n")
+				if !strings.Contains(batch, `"w1"`) || !strings.Contains(batch, `"w2"`) || strings.Contains(batch, `"w3"`) {
+					t.Errorf("deleted %s, want only team T1's WINS", batch)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerOversizedBody(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("MAX_BODY_BYTES", "1024")
+	called := false
+	fakeDynamo(t, func(target, body string) string {
+		called = true
+		return "{}"
+	})
+	resp, err := Handler(context.Background(), signed("secret", command(`"Bob" "`+strings.Repeat("t", 2048)+`"`)))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 413 || called {
+		t.Errorf("Handler = %d, DynamoDB called %t, want 413 before anything is read", resp.StatusCode, called)
+	}
+}
+
+func TestInlineCSV(t *testing.T) {
+	wins := []kanowins.Win{
+		{WinID: "1", Who: "Bob, Jr.", Title: `Shipped "v2", finally`},
+		{WinID: "2", Who: "Carol", Title: "Fixed the build"},
+	}
+	content, rows, err := inlineCSV(wins, maxInlineCSV)
+	if err != nil || rows != 2 {
+		t.Fatalf("inlineCSV = %d rows, %v, want 2", rows, err)
+	}
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatalf("inline CSV %q isn't well formed: %v", content, err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("inline CSV has %d records, want a header and 2 rows", len(records))
+	}
+	if !strings.Contains(content, `"Bob, Jr."`) || !strings.Contains(content, `"Shipped ""v2"", finally"`) {
+		t.Errorf("inline CSV %q doesn't quote the fields with commas", content)
+	}
+	fields := strings.Join(records[1], "|")
+	if !strings.Contains(fields, "Bob, Jr.|") || !strings.Contains(fields, `Shipped "v2", finally`) {
+		t.Errorf("first row = %q, want the fields read back whole", records[1])
+	}
+
+	first, _ := kanowins.CSV(wins[:1])
+	capped, rows, err := inlineCSV(wins, len(first))
+	if err != nil || rows != 1 {
+		t.Errorf("capped inlineCSV = %d rows, %v, want 1", rows, err)
+	}
+	if _, err := csv.NewReader(strings.NewReader(capped)).ReadAll(); err != nil {
+		t.Errorf("capped inline CSV %q isn't well formed: %v", capped, err)
+	}
+}
+
+func TestPostInlineCSV(t *testing.T) {
+	responseURL, posted := responses(t)
+	wins := []kanowins.Win{}
+	for i := 0; i < 200; i++ {
+		wins = append(wins, kanowins.Win{WinID: strconv.Itoa(i), Who: "Bob", Title: "Shipped ```the``` release"})
+	}
+	if err := postInlineCSV(context.Background(), Request{ResponseURL: responseURL}, "Summary", wins); err != nil {
+		t.Fatalf("postInlineCSV error: %v", err)
+	}
+	text, _ := (*posted)[0]["text"].(string)
+	if strings.Count(text, "```") != 2 {
+		t.Errorf("message has %d code fences, want the WINS' backticks broken up", strings.Count(text, "```"))
+	}
+	if !strings.Contains(text, "of 200 — run `/wins export` for all.") {
+		t.Errorf("message %q doesn't note the truncation", text[len(text)-100:])
+	}
+	if len(text) > 4000 {
+		t.Errorf("message is %d characters, want it under Slack's limit", len(text))
+	}
+}
+
+func TestParseScheduleDate(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-02-01", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2024-01-16", time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), false},
+		{"2024-01-15", time.Time{}, true},
+		{"2023-12-31", time.Time{}, true},
+		{"next week", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseScheduleDate(tt.value, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseScheduleDate(%q) = %v, %v, want %v with error %t", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHandlerSchedule(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	stored := []string{}
+	fakeDynamo(t, func(target, body string) string {
+		if target == "PutItem" && !strings.Contains(body, "request#") {
+			stored = append(stored, body)
+		}
+		return "{}"
+	})
+	date := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+	tests := []struct {
+		name       string
+		text       string
+		wantStored bool
+	}{
+		{"future date", `schedule ` + date + ` "Bob" "Shipped the release"`, true},
+		{"past date", `schedule 2020-01-01 "Bob" "Shipped the release"`, false},
+		{"no title", `schedule ` + date + ` "Bob"`, false},
+	}
+	for _, tt := range tests {
+		stored = stored[:0]
+		resp, err := Handler(context.Background(), signed("secret", command(tt.text)))
+		if err != nil {
+			t.Fatalf("%s: Handler error: %v", tt.name, err)
+		}
+		if got := len(stored) == 1; got != tt.wantStored {
+			t.Errorf("%s: stored %q, want the WIN stored: %t", tt.name, stored, tt.wantStored)
+		}
+		if tt.wantStored && !strings.Contains(stored[0], `"visible_after":{"S":"`+date+`T00:00:00`) {
+			t.Errorf("%s: stored %s, want it visible after %s", tt.name, stored[0], date)
+		}
+		if got := strings.Contains(resp.Body, "will appear from"); got != tt.wantStored {
+			t.Errorf("%s: body = %s, want a confirmation: %t", tt.name, resp.Body, tt.wantStored)
+		}
+	}
+}
+
+func TestGetWinsHidesScheduled(t *testing.T) {
+	now := time.Now()
+	fakeDynamo(t, func(target, body string) string {
+		return itemsReply(t,
+			kanowins.Win{WinID: "1", TeamID: "T1", Who: "Alice", Title: "Shipped", CreatedAt: now},
+			kanowins.Win{WinID: "2", TeamID: "T1", Who: "Bob", Title: "Drafted", CreatedAt: now.Add(24 * time.Hour), VisibleAfter: now.Add(24 * time.Hour)},
+			kanowins.Win{WinID: "3", TeamID: "T1", Who: "Carol", Title: "Due", CreatedAt: now.Add(-time.Hour), VisibleAfter: now.Add(-time.Hour)},
+		)
+	})
+	wins, err := getWins("T1")
+	if err != nil {
+		t.Fatalf("getWins error: %v", err)
+	}
+	ids := []string{}
+	for _, win := range wins {
+		ids = append(ids, win.WinID)
+	}
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != "1,3" {
+		t.Errorf("getWins = %v, want the scheduled WIN hidden until its time", ids)
+	}
+}
+
+func TestPostSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		channel    string
+		reply      string
+		wantSlack  []string
+		wantPosted string
+	}{
+		{"response_url", "", "", nil, "WINS summary"},
+		{"summary channel", "C9", `{"ok": true, "channel": "C9", "ts": "1.2"}`, []string{"chat.postMessage"}, ""},
+		{"channel not found", "C9", `{"ok": false, "error": "channel_not_found"}`, []string{"chat.postMessage"}, "Couldn't post the summary to `C9`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUMMARY_CHANNEL", tt.channel)
+			var sentChannel string
+			called := fakeSlack(t, func(method, body string) string {
+				var message struct{ Channel string }
+				json.Unmarshal([]byte(body), &message)
+				sentChannel = message.Channel
+				return tt.reply
+			})
+			responseURL, posted := responses(t)
+			request := Request{TeamID: "T1", ChannelID: "C1", UserID: "U1", ResponseURL: responseURL}
+			if err := postSummary(context.Background(), request, map[string]interface{}{"text": "WINS summary"}); err != nil {
+				t.Fatalf("postSummary error: %v", err)
+			}
+			if strings.Join(*called, ",") != strings.Join(tt.wantSlack, ",") {
+				t.Errorf("Slack methods called = %v, want %v", *called, tt.wantSlack)
+			}
+			if tt.channel != "" && sentChannel != tt.channel {
+				t.Errorf("chat.postMessage channel = %q, want %q", sentChannel, tt.channel)
+			}
+			if tt.wantPosted == "" {
+				if len(*posted) != 0 {
+					t.Errorf("posted %v to the response_url, want nothing", *posted)
+				}
+				return
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages to the response_url, want 1", len(*posted))
+			}
+			if text, _ := (*posted)[0]["text"].(string); !strings.Contains(text, tt.wantPosted) {
+				t.Errorf("response_url text = %q, want %q", text, tt.wantPosted)
+			}
+		})
+	}
+}
+
+func TestPostThreadedSummary(t *testing.T) {
+	delay := threadReplyDelay
+	t.Cleanup(func() { threadReplyDelay = delay })
+	wins := []kanowins.WinSummary{{WinID: "1", Who: "Alice", Title: "One"}, {WinID: "2", Who: "Bob", Title: "Two"}, {WinID: "3", Who: "Carol", Title: "Three"}}
+	options := summaryOptions{Window: 7 * 24 * time.Hour}
+
+	t.Run("replies in the thread", func(t *testing.T) {
+		threadReplyDelay = 0
+		var threads []string
+		called := fakeSlack(t, func(method, body string) string {
+			var message struct {
+				ThreadTS string `json:"thread_ts"`
+			}
+			json.Unmarshal([]byte(body), &message)
+			threads = append(threads, message.ThreadTS)
+			return `{"ok": true, "ts": "1.2"}`
+		})
+		if err := postThreadedSummary(context.Background(), "C9", options, wins); err != nil {
+			t.Fatalf("postThreadedSummary error: %v", err)
+		}
+		if len(*called) != 4 {
+			t.Fatalf("Slack methods called = %v, want the parent and 3 replies", *called)
+		}
+		if want := []string{"", "1.2", "1.2", "1.2"}; strings.Join(threads, ",") != strings.Join(want, ",") {
+			t.Errorf("thread_ts = %v, want %v", threads, want)
+		}
+	})
+
+	t.Run("cancelled between replies", func(t *testing.T) {
+		threadReplyDelay = time.Hour
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		called := fakeSlack(t, func(method, body string) string {
+			cancel()
+			return `{"ok": true, "ts": "1.2"}`
+		})
+		err := postThreadedSummary(ctx, "C9", options, wins)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("postThreadedSummary error = %v, want context.Canceled", err)
+		}
+		if len(*called) != 1 {
+			t.Errorf("Slack methods called = %v, want only the parent", *called)
+		}
+	})
+}
+
+func TestSummaryChannelPerTeam(t *testing.T) {
+	t.Setenv("SUMMARY_CHANNEL", "C9")
+	t.Setenv("SLACK_TEAM_CONFIG", `{"T1": {"access_token": "xoxb-1", "summary_channel": "CA"}, "T2": {"access_token": "xoxb-2", "summary_channel": "CB"}}`)
+	tests := []struct {
+		team            string
+		wantChannel     string
+		wantDestination string
+	}{
+		{"T1", "CA", "CA"},
+		{"T2", "CB", "CB"},
+		{"T3", "C9", "C9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.team, func(t *testing.T) {
+			var sentChannel string
+			fakeSlack(t, func(method, body string) string {
+				var message struct{ Channel string }
+				json.Unmarshal([]byte(body), &message)
+				sentChannel = message.Channel
+				return `{"ok": true, "ts": "1.2"}`
+			})
+			responseURL, posted := responses(t)
+			request := Request{TeamID: tt.team, ChannelID: "C1", UserID: "U1", ResponseURL: responseURL}
+			if err := postSummary(context.Background(), request, map[string]interface{}{"text": "WINS summary"}); err != nil {
+				t.Fatalf("postSummary error: %v", err)
+			}
+			if sentChannel != tt.wantChannel {
+				t.Errorf("chat.postMessage channel = %q, want %q", sentChannel, tt.wantChannel)
+			}
+			if len(*posted) != 0 {
+				t.Errorf("posted %v to the response_url, want nothing", *posted)
+			}
+			if got := summaryDestination(request); got != tt.wantDestination {
+				t.Errorf("summaryDestination = %q, want %q", got, tt.wantDestination)
+			}
+		})
+	}
+	t.Run("no summary channel", func(t *testing.T) {
+		t.Setenv("SLACK_TEAM_CONFIG", `{"T1": {"access_token": "xoxb-1"}}`)
+		if got := summaryDestination(Request{TeamID: "T1", ChannelID: "C1"}); got != "C1" {
+			t.Errorf("summaryDestination = %q, want the command's channel C1", got)
+		}
+	})
+}
+
+func TestHandlerNoToken(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	called := fakeSlack(t, nil)
+	t.Setenv("SLACK_ACCESS_TOKEN", "")
+	responseURL, posted := responses(t)
+	form := command("Alice")
+	form.Set("trigger_id", "1.2.3")
+	form.Set("response_url", responseURL)
+	if _, err := Handler(context.Background(), signed("secret", form)); err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if len(*called) != 0 {
+		t.Errorf("Slack methods called = %v without a token, want none", *called)
+	}
+	if len(*posted) != 1 || (*posted)[0]["text"] != notConfigured {
+		t.Errorf("posted %v, want %q", *posted, notConfigured)
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"<#C123|general>", "C123", false},
+		{"<#G9ABC>", "G9ABC", false},
+		{"#general", "", true},
+		{"<#general>", "", true},
+		{"<@U123>", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseChannel(tt.ref)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseChannel(%q) = %q, %v, want %q with error %t", tt.ref, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetSummaryChannel(t *testing.T) {
+	now := time.Now()
+	t.Setenv("WIN_CATEGORIES", "")
+	fakeDynamo(t, func(target, body string) string {
+		if target == "Scan" {
+			return itemsReply(t,
+				kanowins.Win{WinID: "1", TeamID: "T1", ChannelID: "C1", Who: "Bob", Title: "Logged here", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "2", TeamID: "T1", ChannelID: "C2", Who: "Carol", Title: "Logged in sales", CreatedAt: now.Add(-time.Hour)},
+				kanowins.Win{WinID: "3", TeamID: "T1", Who: "Dan", Title: "Logged before channels", CreatedAt: now.Add(-time.Hour)},
+			)
+		}
+		return "{}"
+	})
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{"invoking channel", nil, []string{"Logged here", "Logged before channels"}, false},
+		{"channel reference", []string{"--channel", "<#C2|sales>"}, []string{"Logged in sales", "Logged before channels"}, false},
+		{"channel= form", []string{"--channel=<#C2|sales>"}, []string{"Logged in sales", "Logged before channels"}, false},
+		{"plain name", []string{"--channel", "#sales"}, nil, true},
+		{"missing reference", []string{"--channel"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responseURL, posted := responses(t)
+			request := Request{TeamID: "T1", ChannelID: "C1", UserID: "U1", ResponseURL: responseURL}
+			options, err := parseSummaryOptions(tt.args, request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSummaryOptions error = %v, want an error: %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), "--channel #general") {
+					t.Errorf("error = %q, want a usage hint", err)
+				}
+				return
+			}
+			if _, err := getSummary(context.Background(), request, options); err != nil {
+				t.Fatalf("getSummary error: %v", err)
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages, want 1", len(*posted))
+			}
+			message, _ := json.Marshal((*posted)[0])
+			for _, title := range []string{"Logged here", "Logged in sales", "Logged before channels"} {
+				want := false
+				for _, w := range tt.want {
+					want = want || w == title
+				}
+				if got := strings.Contains(string(message), title); got != want {
+					t.Errorf("summary shows %q = %t, want %t", title, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerSlackPings(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	called := []string{}
+	fakeDynamo(t, func(target, body string) string {
+		called = append(called, target)
+		return "{}"
+	})
+	tests := []struct {
+		name    string
+		request ProxyRequest
+		want    string
+	}{
+		{"unsigned ssl_check", ProxyRequest{HTTPMethod: "POST", Body: "ssl_check=1&token=legacy"}, ""},
+		{"url_verification", signedBody("secret", `{"token": "legacy", "challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P", "type": "url_verification"}`), `{"challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`},
+	}
+	for _, tt := range tests {
+		resp, err := Handler(context.Background(), tt.request)
+		if err != nil {
+			t.Fatalf("%s: Handler error: %v", tt.name, err)
+		}
+		if resp.StatusCode != 200 || resp.Body != tt.want {
+			t.Errorf("%s: response %d %q, want 200 %q", tt.name, resp.StatusCode, resp.Body, tt.want)
+		}
+	}
+	if len(called) != 0 {
+		t.Errorf("DynamoDB called %v for a ping, want nothing", called)
+	}
+	unsigned := ProxyRequest{HTTPMethod: "POST", Body: `{"challenge": "c", "type": "url_verification"}`}
+	if resp, _ := Handler(context.Background(), unsigned); resp.StatusCode == 200 {
+		t.Errorf("unsigned url_verification answered %d %q, want it rejected", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestParseSummaryOptionsGroupBy(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{"--group-by=who", "who", false},
+		{"--group-by=Category", "category", false},
+		{"--group-by=channel", "", true},
+	}
+	for _, tt := range tests {
+		options, err := parseSummaryOptions([]string{tt.arg}, Request{TeamID: "T1"})
+		if (err != nil) != tt.wantErr || (!tt.wantErr && options.GroupBy != tt.want) {
+			t.Errorf("parseSummaryOptions(%s) GroupBy = %q, error %v, want %q with error %t", tt.arg, options.GroupBy, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHandlerSlackHTMLError(t *testing.T) {
+	fakeDynamo(t, nil)
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "<html><body>Slack is down</body></html>")
+	}))
+	defer server.Close()
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+	responseURL, posted := responses(t)
+	form := command("Alice")
+	form.Set("trigger_id", "1.2.3")
+	form.Set("response_url", responseURL)
+	resp, err := Handler(context.Background(), signed("secret", form))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(*posted) != 1 || (*posted)[0]["text"] != "Couldn't open the WIN form, please try again." {
+		t.Errorf("posted %v, want the WIN form failure", *posted)
+	}
+}
+
+func TestHandlerDisabledCommand(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("DISABLED_COMMANDS", "export, Search")
+	called := []string{}
+	fakeDynamo(t, func(target, body string) string {
+		called = append(called, target)
+		return "{}"
+	})
+	for _, text := range []string{"export", "search release"} {
+		resp, err := Handler(context.Background(), signed("secret", command(text)))
+		if err != nil {
+			t.Fatalf("/wins %s: Handler error: %v", text, err)
+		}
+		if !strings.Contains(resp.Body, "That command is disabled for your team.") {
+			t.Errorf("/wins %s: body = %s, want it rejected", text, resp.Body)
+		}
+	}
+	for _, target := range called {
+		if target == "Scan" || target == "Query" {
+			t.Errorf("DynamoDB %s called for a disabled command", target)
+		}
+	}
+	resp, err := Handler(context.Background(), signed("secret", command("help")))
+	if err != nil {
+		t.Fatalf("/wins help: Handler error: %v", err)
+	}
+	if strings.Contains(resp.Body, "disabled for your team") {
+		t.Errorf("/wins help body = %s, want help to stay enabled", resp.Body)
+	}
+}
+
+func TestSummaryLinkCommand(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	fakeDynamo(t, nil)
+	t.Setenv("SUMMARY_LINK_SECRET", "")
+	resp, err := Handler(context.Background(), signed("secret", command("summary --link")))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if !strings.Contains(resp.Body, "Summary links aren't set up yet") {
+		t.Errorf("body = %s, want links reported as not set up", resp.Body)
+	}
+	t.Setenv("SUMMARY_LINK_SECRET", "secret")
+	t.Setenv("SUMMARY_LINK_URL", "https://api.example.com/wins")
+	resp, err = Handler(context.Background(), signed("secret", command("summary --link")))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if !strings.Contains(resp.Body, "https://api.example.com/wins?token=T1.") {
+		t.Errorf("body = %s, want a link to the team's summary", resp.Body)
+	}
+}
+
+func TestGetSummaryPrivate(t *testing.T) {
+	now := time.Now()
+	t.Setenv("WIN_CATEGORIES", "")
+	fakeDynamo(t, func(target, body string) string {
+		return itemsReply(t,
+			kanowins.Win{WinID: "1", TeamID: "T1", UserID: "U1", Who: "Bob", Title: "Shared with the team", CreatedAt: now.Add(-time.Hour)},
+			kanowins.Win{WinID: "2", TeamID: "T1", UserID: "U1", Who: "Bob", Title: "Kept private", Visibility: kanowins.VisibilityPrivate, CreatedAt: now.Add(-time.Hour)},
+			kanowins.Win{WinID: "3", TeamID: "T1", UserID: "U1", Who: "Bob", Title: "Shared publicly", Visibility: kanowins.VisibilityPublic, CreatedAt: now.Add(-time.Hour)},
+		)
+	})
+	responseURL, posted := responses(t)
+	request := Request{TeamID: "T1", UserID: "U1", ResponseURL: responseURL}
+	options, err := parseSummaryOptions([]string{"--all"}, request)
+	if err != nil {
+		t.Fatalf("parseSummaryOptions error: %v", err)
+	}
+	if _, err := getSummary(context.Background(), request, options); err != nil {
+		t.Fatalf("getSummary error: %v", err)
+	}
+	if len(*posted) != 1 {
+		t.Fatalf("posted %d messages, want 1", len(*posted))
+	}
+	message, _ := json.Marshal((*posted)[0])
+	for title, want := range map[string]bool{"Shared with the team": true, "Kept private": false, "Shared publicly": true} {
+		if got := strings.Contains(string(message), title); got != want {
+			t.Errorf("summary shows %q = %t, want %t", title, got, want)
+		}
+	}
+	mine, err := getUserWins("U1")
+	if err != nil {
+		t.Fatalf("getUserWins error: %v", err)
+	}
+	if len(mine) != 3 {
+		t.Errorf("getUserWins = %d WINS, want the private WIN in /wins mine too", len(mine))
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+const (
+	handler = "KanowinsDigest"
+
+	// digestWindow is the period covered by each weekly digest
+	digestWindow = 7 * 24 * time.Hour
+
+	// maxEmailAttempts bounds how many times the digest email is sent before
+	// giving up, emailBackoff is the wait before the first retry
+	maxEmailAttempts = 3
+	emailBackoff     = time.Second
+)
+
+// weeklyWins returns the current public WINS created within the digest
+// window, newest first
+func weeklyWins(wins []kanowins.Win, now time.Time) []kanowins.Win {
+	weekly := []kanowins.Win{}
+	for _, win := range wins {
+		if !win.Expired(now) && !win.Archived && !win.Hidden(now) && win.VisibleTo(kanowins.VisibilityPublic) && now.Sub(win.CreatedAt) <= digestWindow {
+			weekly = append(weekly, win)
+		}
+	}
+	sort.Slice(weekly, func(i, j int) bool {
+		return weekly[i].CreatedAt.After(weekly[j].CreatedAt)
+	})
+	return weekly
+}
+
+// digestMention returns the `<!channel>` or `<!here>` DIGEST_MENTION asks
+// for, or none for any other value
+func digestMention() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("DIGEST_MENTION"))); value {
+	case "", "none":
+		return ""
+	case "channel", "here":
+		return "<!" + value + ">"
+	default:
+		logging.Printf("digestMention - invalid DIGEST_MENTION %q, expected channel, here or none", value)
+		return ""
+	}
+}
+
+// digestMessage builds the digest message for the given WINS, led by the
+// DIGEST_MENTION broadcast in both the notification text and the blocks
+func digestMessage(wins []kanowins.Win) map[string]interface{} {
+	text := fmt.Sprintf("Weekly WINS digest, WINS count: %d", len(wins))
+	blocks := kanowins.BuildSummaryBlocks(kanowins.Summarize(wins))
+	if mention := digestMention(); mention != "" {
+		text = mention + " " + text
+		blocks = append([]map[string]interface{}{
+			{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": mention},
+			},
+		}, blocks...)
+	}
+	return map[string]interface{}{
+		"text":   text,
+		"blocks": blocks,
+	}
+}
+
+// splitByTeam groups the WINS by the workspaces with an entry in
+// SLACK_TEAM_CONFIG. The rest, legacy WINS without a team and those of the
+// default workspace using SLACK_ACCESS_TOKEN, go to the default webhook
+func splitByTeam(wins []kanowins.Win, teams map[string]slack.TeamConfig) (byTeam map[string][]kanowins.Win, rest []kanowins.Win) {
+	byTeam = map[string][]kanowins.Win{}
+	rest = []kanowins.Win{}
+	for _, win := range wins {
+		if _, ok := teams[win.TeamID]; !ok || win.TeamID == "" {
+			rest = append(rest, win)
+			continue
+		}
+		byTeam[win.TeamID] = append(byTeam[win.TeamID], win)
+	}
+	return
+}
+
+// postDigest sends the digest message to the SLACK_DIGEST_WEBHOOK_URL
+// incoming webhook
+func postDigest(ctx context.Context, message map[string]interface{}) error {
+	return slack.PostWebhook(ctx, os.Getenv("SLACK_DIGEST_WEBHOOK_URL"), message)
+}
+
+// emailRecipients returns the comma separated DIGEST_EMAIL_RECIPIENTS
+func emailRecipients() []string {
+	recipients := []string{}
+	for _, address := range strings.Split(os.Getenv("DIGEST_EMAIL_RECIPIENTS"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	return recipients
+}
+
+// digestEmail builds the raw MIME message sending the weekly WINS to
+// recipients with the CSV attached
+func digestEmail(from string, recipients []string, wins []kanowins.Win, now time.Time) ([]byte, error) {
+	content, err := kanowins.CSV(wins)
+	if err != nil {
+		return nil, err
+	}
+	boundary := fmt.Sprintf("kanowins-%d", now.UnixNano())
+	filename := "wins-" + now.Format("2006-01-02") + ".csv"
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: Weekly WINS digest, WINS count: %d\r\n", len(wins))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "This week's %d WINS are attached as %s.\r\n\r\n", len(wins), filename)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/csv; charset=utf-8\r\n", boundary)
+	fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n", filename)
+	fmt.Fprintf(&buf, "Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// sendRawEmail sends an email through SES, SES_REGION picks the region,
+// since SES isn't offered in every region, falling back to REGION. Tests
+// replace it
+var sendRawEmail = func(ctx context.Context, input *ses.SendRawEmailInput) error {
+	region := os.Getenv("SES_REGION")
+	if region == "" {
+		region = os.Getenv("REGION")
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return err
+	}
+	_, err = ses.New(sess).SendRawEmailWithContext(ctx, input)
+	return err
+}
+
+// emailDigest emails the weekly WINS as CSV to recipients from
+// DIGEST_EMAIL_FROM, nothing is sent when there are no recipients or WINS
+func emailDigest(ctx context.Context, recipients []string, wins []kanowins.Win) error {
+	if len(recipients) == 0 || len(wins) == 0 {
+		return nil
+	}
+	from := os.Getenv("DIGEST_EMAIL_FROM")
+	if from == "" {
+		return errors.New("DIGEST_EMAIL_FROM is required to email the digest")
+	}
+	raw, err := digestEmail(from, recipients, wins, time.Now())
+	if err != nil {
+		return err
+	}
+	input := &ses.SendRawEmailInput{
+		Source:       aws.String(from),
+		Destinations: aws.StringSlice(recipients),
+		RawMessage:   &ses.RawMessage{Data: raw},
+	}
+	backoff := emailBackoff
+	for attempt := 1; ; attempt++ {
+		err = sendRawEmail(ctx, input)
+		if err == nil || attempt == maxEmailAttempts {
+			return err
+		}
+		logging.Printf("emailDigest - SendRawEmail attempt %d error: %v, retrying", attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// emailDigests emails each workspace's WINS to its own email_recipients
+// only, and the rest to DIGEST_EMAIL_RECIPIENTS, so no workspace's WINS
+// reach another's stakeholders
+func emailDigests(ctx context.Context, byTeam map[string][]kanowins.Win, rest []kanowins.Win, teams map[string]slack.TeamConfig) {
+	for teamID, wins := range byTeam {
+		if err := emailDigest(ctx, teams[teamID].EmailRecipients, wins); err != nil {
+			logging.Printf("emailDigests - emailDigest (%s, %d WINS) - error: %v", teamID, len(wins), err)
+			alert.Notify(ctx, handler, err)
+		}
+	}
+	if err := emailDigest(ctx, emailRecipients(), rest); err != nil {
+		logging.Printf("emailDigests - emailDigest (%d WINS) - error: %v", len(rest), err)
+		alert.Notify(ctx, handler, err)
+	}
+}
+
+// Handler is our lambda handler invoked weekly by a CloudWatch schedule
+func Handler(ctx context.Context, e events.CloudWatchEvent) (err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		err = errors.New("panic, see log for stack trace")
+	})
+	logging.Printf("Handler - invoke: %+v", e)
+	db, err := kanowins.NewClient()
+	if err != nil {
+		logging.Printf("Handler - NewClient error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return err
+	}
+	wins, err := db.Get()
+	if err != nil {
+		logging.Printf("Handler - Get error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return err
+	}
+	weekly := weeklyWins(wins, time.Now())
+	if len(weekly) == 0 {
+		logging.Printf("Handler - no WINS this week, skipping digest")
+		return nil
+	}
+	teams := slack.Teams()
+	byTeam, rest := splitByTeam(weekly, teams)
+	for teamID, wins := range byTeam {
+		if teams[teamID].Channel == "" {
+			logging.Printf("Handler - no digest channel for %s, skipping %d WINS", teamID, len(wins))
+			continue
+		}
+		message := digestMessage(wins)
+		message["channel"] = teams[teamID].Channel
+		err := slack.Call(slack.WithTeam(ctx, teamID), "chat.postMessage", message)
+		logging.Printf("Handler - chat.postMessage (%s, %d WINS) - error: %v", teamID, len(wins), err)
+		alert.Notify(ctx, handler, err)
+	}
+	emailDigests(ctx, byTeam, rest, teams)
+	if len(rest) == 0 {
+		return nil
+	}
+	err = postDigest(ctx, digestMessage(rest))
+	logging.Printf("Handler - postDigest (%d WINS) - error: %v", len(rest), err)
+	alert.Notify(ctx, handler, err)
+	return err
+}
+
+// requiredEnv are the environment variables KanowinsDigest can't run without
+var requiredEnv = []string{"REGION", "TABLE_NAME", "SLACK_DIGEST_WEBHOOK_URL"}
+
+func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
+	lambda.Start(Handler)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+func TestDigestMessageMention(t *testing.T) {
+	wins := []kanowins.Win{{WinID: "1", Who: "Bob", Title: "Shipped the release"}}
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{"", ""},
+		{"none", ""},
+		{"channel", "<!channel>"},
+		{" Here ", "<!here>"},
+		{"everyone", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.setting, func(t *testing.T) {
+			t.Setenv("DIGEST_MENTION", tt.setting)
+			message := digestMessage(wins)
+			text, _ := message["text"].(string)
+			blocks := message["blocks"].([]map[string]interface{})
+			first := fmt.Sprint(blocks[0])
+			if tt.want == "" {
+				if strings.Contains(text, "<!") || strings.Contains(first, "<!") {
+					t.Errorf("digest %q / %s mentions, want no broadcast", text, first)
+				}
+				return
+			}
+			if !strings.HasPrefix(text, tt.want+" Weekly WINS digest") {
+				t.Errorf("text = %q, want it to start with %s", text, tt.want)
+			}
+			if section, _ := blocks[0]["text"].(map[string]interface{}); section["text"] != tt.want {
+				t.Errorf("first block = %s, want a %s section", first, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeeklyWinsVisibility(t *testing.T) {
+	now := time.Now()
+	wins := []kanowins.Win{
+		{WinID: "1", Visibility: kanowins.VisibilityPublic, CreatedAt: now.Add(-time.Hour)},
+		{WinID: "2", Visibility: kanowins.VisibilityTeam, CreatedAt: now.Add(-time.Hour)},
+		{WinID: "3", Visibility: kanowins.VisibilityPrivate, CreatedAt: now.Add(-time.Hour)},
+		{WinID: "4", CreatedAt: now.Add(-time.Hour)},
+	}
+	got := weeklyWins(wins, now)
+	if len(got) != 1 || got[0].WinID != "1" {
+		t.Errorf("weeklyWins = %+v, want only the public WIN", got)
+	}
+}
+
+func TestEmailDigestsPerTeam(t *testing.T) {
+	t.Setenv("DIGEST_EMAIL_FROM", "wins@example.com")
+	t.Setenv("DIGEST_EMAIL_RECIPIENTS", "everyone@example.com")
+	sent := map[string]string{}
+	send := sendRawEmail
+	t.Cleanup(func() { sendRawEmail = send })
+	sendRawEmail = func(ctx context.Context, input *ses.SendRawEmailInput) error {
+		raw := string(input.RawMessage.Data)
+		start := strings.Index(raw, "base64\r\n\r\n") + len("base64\r\n\r\n")
+		encoded := strings.Replace(raw[start:strings.LastIndex(raw, "\r\n--")], "\r\n", "", -1)
+		csv, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Errorf("decoding attachment: %v", err)
+		}
+		sent[strings.Join(aws.StringValueSlice(input.Destinations), ",")] = string(csv)
+		return nil
+	}
+	teams := map[string]slack.TeamConfig{
+		"T1": {EmailRecipients: []string{"a@example.com"}},
+		"T2": {EmailRecipients: []string{"b1@example.com", "b2@example.com"}},
+	}
+	now := time.Now()
+	wins := []kanowins.Win{
+		{WinID: "1", TeamID: "T1", Who: "Alice", Title: "Team one WIN", CreatedAt: now},
+		{WinID: "2", TeamID: "T2", Who: "Bob", Title: "Team two WIN", CreatedAt: now},
+		{WinID: "3", Who: "Carol", Title: "Legacy WIN", CreatedAt: now},
+	}
+	byTeam, rest := splitByTeam(wins, teams)
+	emailDigests(context.Background(), byTeam, rest, teams)
+	want := map[string]string{
+		"a@example.com":                 "Team one WIN",
+		"b1@example.com,b2@example.com": "Team two WIN",
+		"everyone@example.com":          "Legacy WIN",
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("emailed %d recipient lists, want %d: %v", len(sent), len(want), sent)
+	}
+	for recipients, title := range want {
+		for _, win := range wins {
+			if got := strings.Contains(sent[recipients], win.Title); got != (win.Title == title) {
+				t.Errorf("email to %s has %q = %t, want only %q", recipients, win.Title, got, title)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+const (
+	handler = "KanowinsExpiryWarning"
+
+	defaultWarningHours = 24
+)
+
+// warningWindow returns how long before TTL expiry a WIN gets a warning,
+// read from EXPIRY_WARNING_HOURS and defaulting to 24 hours
+func warningWindow() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("EXPIRY_WARNING_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultWarningHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// nearingExpiry reports whether the WIN's TTL falls within the given duration
+// from now, permanent WINs (TTL of 0) never expire and never warn
+func nearingExpiry(win kanowins.Win, now time.Time, within time.Duration) bool {
+	if win.TTL == 0 {
+		return false
+	}
+	expiry := time.Unix(win.TTL, 0)
+	return expiry.After(now) && !expiry.After(now.Add(within))
+}
+
+// warned reports whether the WIN's submitter is warned about its expiry,
+// archived WINs are not. A WIN scheduled for later is, since its submitter
+// can renew it before it appears
+func warned(win kanowins.Win, now time.Time, within time.Duration) bool {
+	return !win.Archived && nearingExpiry(win, now, within)
+}
+
+// notify sends a direct message to the WIN submitter about the upcoming expiry
+func notify(ctx context.Context, win kanowins.Win) error {
+	return slack.Call(ctx, "chat.postMessage", map[string]interface{}{
+		"channel": win.UserID,
+		"text":    warningText(win),
+	})
+}
+
+// warningText tells the submitter when the WIN expires and how to renew it
+func warningText(win kanowins.Win) string {
+	return fmt.Sprintf(
+		":hourglass: Your WIN for %s \"%s\" will expire %s — run `/wins renew %s` to keep it!",
+		kanowins.SanitizeMrkdwn(win.Who),
+		kanowins.SanitizeMrkdwn(win.Title),
+		time.Unix(win.TTL, 0).UTC().Format(time.RFC1123),
+		win.WinID,
+	)
+}
+
+// Handler is our lambda handler invoked daily by a CloudWatch schedule
+func Handler(ctx context.Context, e events.CloudWatchEvent) (err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		err = errors.New("panic, see log for stack trace")
+	})
+	logging.Printf("Handler - invoke: %+v", e)
+	db, err := kanowins.NewClient()
+	if err != nil {
+		logging.Printf("Handler - NewClient error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return err
+	}
+	wins, err := db.Get()
+	if err != nil {
+		logging.Printf("Handler - Get error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return err
+	}
+	now := time.Now()
+	within := warningWindow()
+	for _, win := range wins {
+		if !warned(win, now, within) {
+			continue
+		}
+		err := notify(slack.WithTeam(ctx, win.TeamID), win)
+		logging.Printf("Handler - notify (%s/%s/%s) - error: %v", win.UserID, win.Who, win.Title, err)
+		alert.Notify(ctx, handler, err)
+	}
+	return nil
+}
+
+// requiredEnv are the environment variables KanowinsExpiryWarning can't run without
+var requiredEnv = []string{"REGION", "TABLE_NAME", "SLACK_ACCESS_TOKEN"}
+
+func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
+	lambda.Start(Handler)
+}
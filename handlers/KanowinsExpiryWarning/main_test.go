@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+func TestNearingExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	within := 24 * time.Hour
+	tests := []struct {
+		name string
+		ttl  int64
+		want bool
+	}{
+		{"no TTL", 0, false},
+		{"inside the window", now.Add(time.Hour).Unix(), true},
+		{"exactly at the window's end", now.Add(within).Unix(), true},
+		{"past the window", now.Add(within + time.Second).Unix(), false},
+		{"expiring now", now.Unix(), false},
+		{"already past", now.Add(-time.Hour).Unix(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nearingExpiry(kanowins.Win{TTL: tt.ttl}, now, within); got != tt.want {
+				t.Errorf("nearingExpiry = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarned(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ttl := now.Add(time.Hour).Unix()
+	tests := []struct {
+		name string
+		win  kanowins.Win
+		want bool
+	}{
+		{"current", kanowins.Win{TTL: ttl}, true},
+		{"archived", kanowins.Win{TTL: ttl, Archived: true}, false},
+		{"scheduled", kanowins.Win{TTL: ttl, VisibleAfter: now.Add(time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := warned(tt.win, now, 24*time.Hour); got != tt.want {
+				t.Errorf("warned = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarningText(t *testing.T) {
+	text := warningText(kanowins.Win{WinID: "w1", Who: "Alice", Title: "Shipped", TTL: 1700000000})
+	if !strings.Contains(text, "`/wins renew w1`") {
+		t.Errorf("warningText = %q, want the renew command", text)
+	}
+}
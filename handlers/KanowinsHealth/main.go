@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanowins/internal/gateway"
+)
+
+// Response is of type APIGatewayProxyResponse since we're leveraging the
+// AWS Lambda Proxy Request functionality (default behavior)
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+type Response events.APIGatewayProxyResponse
+
+// ProxyRequest is the API Gateway request, decoded from either REST API or
+// HTTP API payloads
+type ProxyRequest = gateway.Request
+
+// Handler answers `GET /health` for deployment smoke tests, it is
+// unauthenticated so it touches neither Slack nor DynamoDB
+func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
+	version := os.Getenv("BUILD_VERSION")
+	if version == "" {
+		version = "dev"
+	}
+	body, _ := json.Marshal(map[string]string{
+		"status":  "ok",
+		"version": version,
+	})
+	return Response{
+		StatusCode: 200,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
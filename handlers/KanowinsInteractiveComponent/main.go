@@ -1,36 +1,58 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/apperror"
+	"github.com/anzellai/kanowins/internal/dialog"
+	"github.com/anzellai/kanowins/internal/gateway"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/metrics"
+	"github.com/anzellai/kanowins/internal/slack"
 )
 
 const (
-	handler     = "KanowinsInteractiveComponent"
-	apiEndpoint = "https://slack.com/api/dialog.open"
+	handler = "KanowinsInteractiveComponent"
+
+	defaultSubmitConfirmation = ":tada: Your WIN for {who} was saved!"
+
+	// defaultRateLimit and defaultRateWindow allow 10 WINS per user every 5
+	// minutes when RATE_LIMIT_WINS and RATE_LIMIT_WINDOW are unset
+	defaultRateLimit  = 10
+	defaultRateWindow = 5 * time.Minute
 )
 
+// notConfigured is shown when there is no Slack token to call the Web API
+// with
+const notConfigured = "Bot is not configured — contact your admin."
+
+// storageMissing is shown when the WINs table hasn't been provisioned
+const storageMissing = "WINS storage isn't set up yet — contact your admin, who can run `/wins setup`."
+
 // Response is of type APIGatewayProxyResponse since we're leveraging the
 // AWS Lambda Proxy Request functionality (default behavior)
 //
 // https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
-type Response events.APIGatewayProxyResponse
+type Response = events.APIGatewayProxyResponse
 
-// ProxyRequest event type ...
-type ProxyRequest events.APIGatewayProxyRequest
+// ProxyRequest is the API Gateway request, decoded from either REST API or
+// HTTP API payloads
+type ProxyRequest = gateway.Request
 
 // Request is the proxy request from lambda
 type Request struct {
@@ -38,15 +60,29 @@ type Request struct {
 	Submission  submission `json:"submission"`
 	CallbackID  string     `json:"callback_id"`
 	User        user       `json:"user"`
+	Team        team       `json:"team"`
+	Channel     channel    `json:"channel"`
 	ActionTS    string     `json:"action_ts"`
 	Token       string     `json:"token"`
 	ResponseURL string     `json:"response_url"`
+	State       string     `json:"state"`
+	Actions     []action   `json:"actions"`
+	View        view       `json:"view"`
+	TriggerID   string     `json:"trigger_id"`
+	Message     message    `json:"message"`
 }
 
 type submission struct {
 	Who         string `json:"who"`
+	WhoUser     string `json:"who_user"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	Category    string `json:"category"`
+	Anonymous   string `json:"anonymous"`
+	More        string `json:"more"`
+	URL         string `json:"url"`
+	Impact      string `json:"impact"`
+	Visibility  string `json:"visibility"`
 }
 
 type user struct {
@@ -54,112 +90,837 @@ type user struct {
 	Name string `json:"name"`
 }
 
-// Win is the WIN struct type ...
-type Win struct {
-	UserID      string    `json:"user_id"`
-	UserName    string    `json:"user_name"`
-	Who         string    `json:"who"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	TTL         int64     `json:"ttl"`
+type team struct {
+	ID     string `json:"id"`
+	Domain string `json:"domain"`
 }
 
-// GetDB return DDB handle
-func GetDB() (srv *dynamodb.DynamoDB, err error) {
-	region := os.Getenv("REGION")
-	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
-	if err != nil {
-		return
+type channel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// message is the message a `message_action` shortcut was run on
+type message struct {
+	TS   string `json:"ts"`
+	Text string `json:"text"`
+}
+
+// view is the modal submitted in a view_submission payload, its state holds
+// the input values keyed by block_id then action_id, both the field name
+//
+// https://api.slack.com/reference/interaction-payloads/views#view_submission
+type view struct {
+	CallbackID      string `json:"callback_id"`
+	PrivateMetadata string `json:"private_metadata"`
+	State           struct {
+		Values map[string]map[string]viewValue `json:"values"`
+	} `json:"state"`
+}
+
+type viewValue struct {
+	Value          string `json:"value"`
+	SelectedUser   string `json:"selected_user"`
+	SelectedOption *struct {
+		Value string `json:"value"`
+	} `json:"selected_option"`
+}
+
+// value returns the submitted value of the named modal input
+func (v view) value(name string) string {
+	input := v.State.Values[name][name]
+	if input.SelectedOption != nil {
+		return input.SelectedOption.Value
 	}
-	srv = dynamodb.New(sess)
-	return
+	if input.SelectedUser != "" {
+		return input.SelectedUser
+	}
+	return input.Value
+}
+
+// fromView copies a modal submission into the dialog submission fields, so
+// the rest of the handler treats modals and dialogs alike
+func (request *Request) fromView() {
+	request.CallbackID = request.View.CallbackID
+	request.State = request.View.PrivateMetadata
+	request.Submission = submission{
+		Who:         request.View.value("who"),
+		WhoUser:     request.View.value("who_user"),
+		Title:       request.View.value("title"),
+		Description: request.View.value("description"),
+		Category:    request.View.value("category"),
+		Anonymous:   request.View.value("anonymous"),
+		More:        request.View.value("more"),
+		URL:         request.View.value("url"),
+		Impact:      request.View.value("impact"),
+		Visibility:  request.View.value("visibility"),
+	}
+}
+
+// action is a clicked button from either a legacy interactive message (name)
+// or a Block Kit message (action_id)
+type action struct {
+	Name     string `json:"name"`
+	ActionID string `json:"action_id"`
+	Value    string `json:"value"`
+}
+
+// fieldError is an inline dialog error, returning them keeps the dialog open
+//
+// https://api.slack.com/dialogs#input_validation
+type fieldError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// validate checks the required dialog fields are filled in and the text
+// fits the WIN length limits, ignoring surrounding whitespace
+func (request Request) validate() []fieldError {
+	errs := []fieldError{}
+	if strings.TrimSpace(request.Submission.Who) == "" {
+		name := "who"
+		if !kanowins.WhoFreeText(request.Team.ID) {
+			name = "who_user"
+		}
+		errs = append(errs, fieldError{Name: name, Error: "Please tell us who has this WIN"})
+	}
+	title := strings.TrimSpace(request.Submission.Title)
+	switch {
+	case title == "":
+		errs = append(errs, fieldError{Name: "title", Error: "Please give this WIN a title"})
+	case utf8.RuneCountInString(title) > kanowins.MaxTitleLength:
+		errs = append(errs, fieldError{Name: "title", Error: fmt.Sprintf("Please keep the title to %d characters or fewer", kanowins.MaxTitleLength)})
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(request.Submission.Description)) > kanowins.MaxDescriptionLength {
+		errs = append(errs, fieldError{Name: "description", Error: fmt.Sprintf("Please keep the description to %d characters or fewer", kanowins.MaxDescriptionLength)})
+	}
+	if link := strings.TrimSpace(request.Submission.URL); link != "" && !validURL(link) {
+		errs = append(errs, fieldError{Name: "url", Error: "Please enter a full link, such as https://example.com/pr/1"})
+	}
+	if _, _, err := kanowins.ParseImpact(request.Submission.Impact); err != nil {
+		errs = append(errs, fieldError{Name: "impact", Error: "Please enter the impact as a number, such as 12500, $12,500 or 40 hours"})
+	}
+	more := request.moreTitles()
+	for _, line := range more {
+		if utf8.RuneCountInString(line) > kanowins.MaxTitleLength {
+			errs = append(errs, fieldError{Name: "more", Error: fmt.Sprintf("Please keep each WIN to %d characters or fewer", kanowins.MaxTitleLength)})
+			break
+		}
+	}
+	return errs
+}
+
+// validURL reports whether link is an absolute http(s) URL that can be
+// embedded in a Slack `<url|text>` link
+func validURL(link string) bool {
+	parsed, err := url.ParseRequestURI(link)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	return !strings.ContainsAny(link, "<>| ")
+}
+
+// moreTitles returns the non-empty lines of the "More WINS" field, trimmed
+func (request Request) moreTitles() []string {
+	titles := []string{}
+	for _, line := range strings.Split(request.Submission.More, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			titles = append(titles, line)
+		}
+	}
+	return titles
+}
+
+// moreWins builds a WIN for each of the "More WINS" titles, the impact stays
+// on the first WIN alone
+func (request Request) moreWins() []kanowins.Win {
+	base := request.Win()
+	wins := []kanowins.Win{}
+	for _, title := range request.moreTitles() {
+		win := base
+		win.Title = title
+		win.Description = kanowins.DefaultDescription()
+		win.Impact, win.ImpactUnit = 0, ""
+		wins = append(wins, win)
+	}
+	return wins
+}
+
+// validationErrors renders field errors in the shape Slack expects, a list
+// for dialogs or a response_action keyed by block_id for modals. Slack keeps
+// the form open with the user's input either way
+//
+// Slack rejects a modal outright for an error on a block it doesn't have,
+// so such errors are shown on the title, which every WIN form has
+func validationErrors(request Request, errs []fieldError) []byte {
+	if request.Type != "view_submission" {
+		body, _ := json.Marshal(map[string]interface{}{
+			"errors": errs,
+		})
+		return body
+	}
+	byBlock := map[string]string{}
+	for _, e := range errs {
+		name := e.Name
+		if _, ok := request.View.State.Values[name]; !ok {
+			name = "title"
+		}
+		if byBlock[name] != "" {
+			byBlock[name] += ". "
+		}
+		byBlock[name] += e.Error
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"response_action": "errors",
+		"errors":          byBlock,
+	})
+	return body
 }
 
-// PutItem upsert WIN instance to db
-func (request Request) PutItem() (err error) {
-	description := request.Submission.Description
+// Win builds the WIN described by the dialog submission, with its team and
+// channel from the dialog state
+func (request Request) Win() kanowins.Win {
+	state := kanowins.DecodeDialogState(request.State)
+	impact, unit, _ := kanowins.ParseImpact(request.Submission.Impact)
+	description := strings.TrimSpace(request.Submission.Description)
 	if len(description) == 0 {
-		description = "Big WIN!"
+		description = kanowins.DefaultDescription()
 	}
-	now := time.Now()
-	win := Win{
+	return kanowins.Win{
 		UserID:      request.User.ID,
 		UserName:    request.User.Name,
-		Who:         request.Submission.Who,
-		Title:       request.Submission.Title,
+		TeamID:      firstNonEmpty(state.TeamID, request.Team.ID),
+		ChannelID:   firstNonEmpty(state.ChannelID, request.Channel.ID),
+		ChannelName: firstNonEmpty(state.ChannelName, request.Channel.Name),
+		Permalink:   state.Permalink,
+		URL:         strings.TrimSpace(request.Submission.URL),
+		Who:         strings.TrimSpace(request.Submission.Who),
+		WhoUserID:   strings.TrimSpace(request.Submission.WhoUser),
+		Title:       strings.TrimSpace(request.Submission.Title),
 		Description: description,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
-	defer log.Printf(
-		"%s.PutItem (%s/%s/%s/%s) - error: %v",
-		handler,
-		win.UserID,
-		win.UserName,
-		win.Who,
-		win.Title,
-		err,
+		Category:    request.Submission.Category,
+		Impact:      impact,
+		ImpactUnit:  unit,
+		Anonymous:   request.Submission.Anonymous == "yes",
+		Visibility:  kanowins.ParseVisibility(request.Submission.Visibility),
+		Source:      kanowins.SourceDialog,
+	}
+}
+
+// PutItem upsert WIN instance to db, returning the WIN as stored, any
+// "More WINS" are batch written alongside it
+func (request Request) PutItem() (win kanowins.Win, err error) {
+	win = request.Win()
+	defer func() {
+		logging.Printf(
+			"PutItem (%s/%s/%s/%s) - error: %v",
+			win.UserID,
+			win.UserName,
+			win.Who,
+			win.Title,
+			err,
+		)
+	}()
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	win, err = db.Store(win)
+	if more := request.moreWins(); err == nil && len(more) > 0 {
+		err = db.BatchPut(more)
+	}
+	return
+}
+
+// UpdateItem amends the caller's existing WIN whose WinID is held in the
+// dialog state, keeping the original CreatedAt
+func (request Request) UpdateItem() (err error) {
+	win := request.Win()
+	defer func() {
+		logging.Printf(
+			"UpdateItem (%s/%s/%s/%s) - error: %v",
+			win.UserID,
+			win.UserName,
+			win.Who,
+			win.Title,
+			err,
+		)
+	}()
+	win.WinID = kanowins.DecodeDialogState(request.State).WinID
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.Update(win)
+	return
+}
+
+// DeleteItem archives the caller's WIN with winID rather than removing it
+func (request Request) DeleteItem(winID string) (err error) {
+	defer func() {
+		logging.Printf("DeleteItem (%s/%s) - error: %v", request.User.ID, winID, err)
+	}()
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.Archive(winID, request.User.ID)
+	return
+}
+
+// GiveKudos adds one kudos to the team's WIN with winID
+func (request Request) GiveKudos(winID string) (err error) {
+	defer func() {
+		logging.Printf("GiveKudos (%s/%s) - error: %v", request.User.ID, winID, err)
+	}()
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	err = db.AddKudos(request.Team.ID, winID)
+	return
+}
+
+// forSelf reports whether the submitter logged the WIN for themselves
+func (request Request) forSelf() bool {
+	if request.Submission.WhoUser != "" {
+		return request.Submission.WhoUser == request.User.ID
+	}
+	who := strings.TrimPrefix(kanowins.CleanWho(request.Submission.Who), "@")
+	return strings.EqualFold(who, request.User.Name) || strings.EqualFold(who, "me")
+}
+
+// resolveWho names the Slack user picked for the WIN when no name was typed,
+// falling back to their user ID
+func (request *Request) resolveWho(ctx context.Context) {
+	id := request.Submission.WhoUser
+	if id == "" || strings.TrimSpace(request.Submission.Who) != "" {
+		return
+	}
+	request.Submission.Who = id
+	if name := slack.DisplayNames(ctx, []string{id})[id]; name != "" {
+		request.Submission.Who = name
+	}
+}
+
+// subjectNotice returns how NOTIFY_SUBJECT tells someone about a WIN logged
+// for them, `dm` or `channel`, or "" for none
+func subjectNotice() string {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv("NOTIFY_SUBJECT"))); value {
+	case "", "none":
+		return ""
+	case "dm", "channel":
+		return value
+	default:
+		logging.Printf("subjectNotice - invalid NOTIFY_SUBJECT %q, expected dm, channel or none", value)
+		return ""
+	}
+}
+
+// notifySubject tells the Slack user the stored wins are for about them,
+// as NOTIFY_SUBJECT asks. Private WINS and WINS people log for themselves
+// send nothing
+func (request Request) notifySubject(ctx context.Context, wins []kanowins.Win) {
+	notice := subjectNotice()
+	if notice == "" || len(wins) == 0 || wins[0].WhoUserID == "" || wins[0].WhoUserID == wins[0].UserID || !wins[0].VisibleTo(kanowins.VisibilityTeam) {
+		return
+	}
+	win := wins[0]
+	from := "<@" + win.UserID + ">"
+	if win.Anonymous {
+		from = "a teammate"
+	}
+	text := fmt.Sprintf("You got a WIN from %s: %s", from, kanowins.SanitizeMrkdwn(win.Title))
+	if len(wins) > 1 {
+		text += fmt.Sprintf(" (and %d more)", len(wins)-1)
+	}
+	channel := win.WhoUserID
+	if notice == "channel" && win.ChannelID != "" {
+		channel = win.ChannelID
+		text = "<@" + win.WhoUserID + "> " + text
+	}
+	if err := slack.Call(ctx, "chat.postMessage", map[string]interface{}{
+		"channel": channel,
+		"text":    ":trophy: " + text,
+	}); err != nil {
+		logging.Printf("notifySubject - chat.postMessage (%s) error: %v", win.WhoUserID, err)
+	}
+}
+
+// hold keeps the submitted WINS and asks the submitter to confirm or discard
+// the first
+func (request Request) hold(ctx context.Context, prompt, text string) (err error) {
+	wins := append([]kanowins.Win{request.Win()}, request.moreWins()...)
+	db, err := kanowins.NewClient()
+	if err != nil {
+		return
+	}
+	id, err := db.PutPreview(wins)
+	if err != nil {
+		return
+	}
+	win := wins[0]
+	win.CreatedAt = time.Now()
+	request.reply(ctx, map[string]interface{}{
+		"text":   text,
+		"blocks": kanowins.BuildPreviewBlocks(prompt, kanowins.Summarize([]kanowins.Win{win})[0], id),
+	})
+	return
+}
+
+// preview holds the submitted WIN to show the submitter how it will look
+func (request Request) preview(ctx context.Context) error {
+	who := kanowins.SanitizeMrkdwn(strings.TrimSpace(request.Submission.Who))
+	return request.hold(ctx,
+		"Here's how your WIN will look, it isn't saved until you confirm:",
+		"Preview of your WIN for "+who,
 	)
-	srv, err := GetDB()
+}
+
+// confirmOther holds WINS logged for someone other than the submitter until
+// they confirm who the WINS are for, so a WIN isn't misattributed by accident
+func (request Request) confirmOther(ctx context.Context) error {
+	who := kanowins.SanitizeMrkdwn(strings.TrimSpace(request.Submission.Who))
+	return request.hold(ctx,
+		fmt.Sprintf("This WIN is for *%s* rather than you, confirm to save it for them:", who),
+		"Confirm your WIN for "+who,
+	)
+}
+
+// resolvePreview stores or discards the caller's WINS held as previewID,
+// replacing the confirmation message with the outcome
+func (request Request) resolvePreview(ctx context.Context, confirmed bool, previewID string) (err error) {
+	defer func() {
+		logging.Printf("resolvePreview (%s/%s/%t) - error: %v", request.User.ID, previewID, confirmed, err)
+	}()
+	db, err := kanowins.NewClient()
+	var wins []kanowins.Win
+	if err == nil {
+		wins, err = db.TakePreview(previewID, request.User.ID)
+	}
+	if err == nil && confirmed {
+		if err = db.BatchPut(wins); err == nil {
+			for range wins {
+				metrics.Count("WinsSubmitted", map[string]string{"TeamID": request.Team.ID})
+			}
+			request.notifySubject(ctx, wins)
+		}
+	}
+	text := "Your WIN was discarded, nothing was saved."
+	switch {
+	case err == kanowins.ErrNotFound:
+		text = "That WIN has expired, please submit it again."
+	case kanowins.IsMissingTable(err):
+		text = storageMissing
+	case err != nil:
+		text = "Sorry, your WIN couldn't be saved, please try again."
+	case confirmed:
+		text = strings.Replace(submitConfirmation(), "{who}", wins[0].Who, -1)
+		if len(wins) > 1 {
+			text += fmt.Sprintf(" (%d WINS saved)", len(wins))
+		}
+	}
+	if postErr := postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"replace_original": true,
+		"text":             text,
+	}); postErr != nil {
+		logging.Printf("resolvePreview - postMessage error: %v", postErr)
+	}
+	return
+}
+
+// turnPage re-renders a `/wins mine` or `/wins search` listing at the page
+// the clicked Prev or Next button's cursor points to. The caller's own WINS
+// are looked up afresh so a cursor can't list anyone else's, and a search
+// only lists the caller's workspace
+func (request Request) turnPage(ctx context.Context, value string) (err error) {
+	defer func() {
+		logging.Printf("turnPage (%s/%s) - error: %v", request.User.ID, value, err)
+	}()
+	cursor, err := kanowins.DecodePageCursor(value)
 	if err != nil {
 		return
 	}
-	win.TTL = win.UpdatedAt.AddDate(0, 0, 7).Unix()
-	item, err := dynamodbattribute.MarshalMap(win)
+	db, err := kanowins.NewClient()
 	if err != nil {
 		return
 	}
-	input := &dynamodb.PutItemInput{
-		Item:      item,
-		TableName: aws.String(os.Getenv("TABLE_NAME")),
+	var wins []kanowins.Win
+	if cursor.List == kanowins.ListSearch {
+		wins, err = db.Get()
+	} else {
+		wins, err = db.GetByUser(request.User.ID)
+	}
+	if err != nil {
+		return
+	}
+	if cursor.List == kanowins.ListSearch {
+		team := []kanowins.Win{}
+		for _, win := range wins {
+			if win.TeamID == request.Team.ID {
+				team = append(team, win)
+			}
+		}
+		wins = team
+	}
+	heading, winsSummary := kanowins.Listing(cursor, wins, time.Now())
+	return postMessage(ctx, request.ResponseURL, map[string]interface{}{
+		"replace_original": true,
+		"text":             heading,
+		"blocks":           kanowins.BuildPageBlocks(heading, winsSummary, cursor, kanowins.PageSize()),
+	})
+}
+
+// completeDialog marks the form KanowinsCommand tracked as submitted. Forms
+// it didn't open, like message shortcuts, are skipped
+func (request Request) completeDialog() {
+	triggerID := kanowins.DecodeDialogState(request.State).TriggerID
+	if triggerID == "" {
+		return
+	}
+	db, err := kanowins.NewClient()
+	if err == nil {
+		err = db.CompleteDialog(triggerID)
+	}
+	if err != nil {
+		logging.Printf("completeDialog - CompleteDialog (%s) error: %v", triggerID, err)
+	}
+}
+
+// handleActions runs the clicked message buttons and reports back to Slack
+func (request Request) handleActions(ctx context.Context) (err error) {
+	for _, a := range request.Actions {
+		if a.Name == "kudos" || a.ActionID == "kudos" {
+			// kudos are acknowledged by the next summary showing the new count
+			err = request.GiveKudos(a.Value)
+			continue
+		}
+		if a.ActionID == "confirm-win" || a.ActionID == "discard-win" {
+			err = request.resolvePreview(ctx, a.ActionID == "confirm-win", a.Value)
+			continue
+		}
+		if a.ActionID == "page-prev" || a.ActionID == "page-next" {
+			err = request.turnPage(ctx, a.Value)
+			continue
+		}
+		if a.Name != "delete" && a.ActionID != "delete" {
+			continue
+		}
+		text := "Your WIN was deleted."
+		err = request.DeleteItem(a.Value)
+		if err == kanowins.ErrNotFound {
+			text = "That WIN no longer exists, or isn't yours to delete."
+		} else if kanowins.IsMissingTable(err) {
+			text = storageMissing
+		} else if err != nil {
+			text = "Sorry, your WIN couldn't be deleted, please try again."
+		}
+		if postErr := postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"replace_original": true,
+			"text":             text,
+		}); postErr != nil {
+			logging.Printf("handleActions - postMessage error: %v", postErr)
+		}
+	}
+	return
+}
+
+// openFromMessage opens the WIN form pre-filled from a message shortcut,
+// keeping the message's permalink
+func (request Request) openFromMessage(ctx context.Context) error {
+	permalink, err := slack.Permalink(ctx, request.Channel.ID, request.Message.TS)
+	if err != nil {
+		logging.Printf("openFromMessage - Permalink error: %v", err)
+	}
+	text := strings.TrimSpace(request.Message.Text)
+	state := kanowins.DialogState{
+		TeamID:      request.Team.ID,
+		ChannelID:   request.Channel.ID,
+		ChannelName: request.Channel.Name,
+		Permalink:   permalink,
+	}.Encode()
+	win := kanowins.Win{
+		TeamID:      request.Team.ID,
+		Title:       clip(strings.SplitN(text, "\n", 2)[0], kanowins.MaxTitleLength),
+		Description: clip(text, kanowins.MaxDescriptionLength),
+	}
+	err = dialog.Open(ctx, request.TriggerID, dialog.New(
+		dialog.Title(request.Team.ID),
+		dialog.SubmitLabel(request.Team.ID),
+		"submit-win",
+		state,
+		win,
+	))
+	if err != nil && request.ResponseURL != "" {
+		text := "Couldn't open the WIN form, please try again."
+		if err == slack.ErrNoToken {
+			text = notConfigured
+		}
+		if postErr := postMessage(ctx, request.ResponseURL, map[string]interface{}{
+			"response_type": "ephemeral",
+			"text":          text,
+		}); postErr != nil {
+			logging.Printf("openFromMessage - postMessage error: %v", postErr)
+		}
+	}
+	return err
+}
+
+// clip shortens text to at most max characters
+func clip(text string, max int) string {
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max])
+	}
+	return text
+}
+
+// submitConfirmation returns the SUBMIT_CONFIRMATION message template sent
+// after a WIN is saved, `{who}` is replaced with the WIN's subject
+func submitConfirmation() string {
+	if template := os.Getenv("SUBMIT_CONFIRMATION"); template != "" {
+		return template
+	}
+	return defaultSubmitConfirmation
+}
+
+// confirm lets the submitter know whether their WIN was stored
+func (request Request) confirm(ctx context.Context, template string, err error) {
+	text := strings.Replace(template, "{who}", request.Submission.Who, -1)
+	switch {
+	case kanowins.IsMissingTable(err):
+		text = storageMissing
+	case err != nil:
+		text = fmt.Sprintf(":warning: Sorry, your WIN for %s couldn't be saved, please try again.", request.Submission.Who)
+	}
+	request.reply(ctx, map[string]interface{}{"text": text})
+}
+
+// reply sends message to the submitter only, ephemerally through the
+// response_url or by direct message for modal submissions, which carry none
+func (request Request) reply(ctx context.Context, message map[string]interface{}) {
+	if request.ResponseURL == "" {
+		message["channel"] = request.User.ID
+		if postErr := slack.Call(ctx, "chat.postMessage", message); postErr != nil {
+			logging.Printf("reply - chat.postMessage error: %v", postErr)
+		}
+		return
+	}
+	message["response_type"] = "ephemeral"
+	if postErr := postMessage(ctx, request.ResponseURL, message); postErr != nil {
+		logging.Printf("reply - postMessage error: %v", postErr)
 	}
-	_, err = srv.PutItem(input)
+}
+
+// postMessage sends a message back to Slack via the given response URL
+func postMessage(ctx context.Context, responseURL string, message map[string]interface{}) (err error) {
+	body, _ := json.Marshal(message)
+	resp, err := slack.Do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", responseURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var respBody map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	logging.Printf("postMessage - response Body: %v", respBody)
 	return
 }
 
+// firstNonEmpty returns the first of values that isn't ""
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// rateLimit returns the RATE_LIMIT_WINS per RATE_LIMIT_WINDOW a user may
+// log, or the defaults
+func rateLimit() (int, time.Duration) {
+	limit, window := defaultRateLimit, defaultRateWindow
+	if value := os.Getenv("RATE_LIMIT_WINS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			limit = n
+		} else {
+			logging.Printf("rateLimit - invalid RATE_LIMIT_WINS %q, using %d", value, limit)
+		}
+	}
+	if value := os.Getenv("RATE_LIMIT_WINDOW"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			window = d
+		} else {
+			logging.Printf("rateLimit - invalid RATE_LIMIT_WINDOW %q, using %s", value, window)
+		}
+	}
+	return limit, window
+}
+
+// throttled reports whether the submitter is over their rate limit with
+// this submission's WINS, a failure to count lets the submission through
+// rather than dropping it
+func (request Request) throttled() bool {
+	limit, window := rateLimit()
+	db, err := kanowins.NewClient()
+	allowed := true
+	if err == nil {
+		allowed, err = db.Throttle(request.User.ID, 1+len(request.moreTitles()), limit, window)
+	}
+	if err != nil {
+		logging.Printf("throttled - Throttle error: %v", err)
+	}
+	return !allowed
+}
+
+// requestKey identifies the request for deduplication. A form submission is
+// keyed by the trigger_id in its state, so a retry is caught even when it
+// doesn't match byte for byte. Anything else is keyed by its action
+// timestamp, or by its body when it has none
+func (request Request) requestKey(body string) string {
+	submitted := request.Type == "dialog_submission" || request.Type == "view_submission"
+	if triggerID := kanowins.DecodeDialogState(request.State).TriggerID; submitted && triggerID != "" {
+		return request.Team.ID + ":trigger:" + triggerID
+	}
+	if request.ActionTS != "" {
+		return request.Team.ID + ":" + request.ActionTS
+	}
+	return kanowins.Fingerprint(body)
+}
+
 // Handler is our lambda handler invoked by the `lambda.Start` function call
-func Handler(ctx context.Context, r ProxyRequest) (Response, error) {
-	log.Printf("%s.Handler - submitted: %+v", handler, r)
-	form, err := url.Parse("?" + r.Body)
+func Handler(ctx context.Context, r ProxyRequest) (resp Response, err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		alert.Notify(ctx, handler, apperror.ErrInternal)
+		// an empty 200 closes a dialog without Slack reporting a failure
+		resp, err = gateway.JSON(200, ""), nil
+	})
+	defer metrics.Flush(ctx)
+	if kanowins.BodyTooLarge(r.Body, r.IsBase64Encoded) {
+		logging.Printf("Handler - rejected %d byte body", len(r.Body))
+		return gateway.Error(apperror.ErrTooLarge), nil
+	}
+	logging.Printf("Handler - submitted: %+v", r)
+	if err := r.DecodeBody(); err != nil {
+		logging.Printf("Handler - decode body error: %v", err)
+		return gateway.Error(apperror.Wrap(apperror.ErrBadRequest, err)), nil
+	}
+	if slack.SSLCheck(r.Body) {
+		logging.Printf("Handler - ssl_check ping")
+		return gateway.JSON(200, ""), nil
+	}
+	if err := slack.VerifyRequest(r); err != nil {
+		logging.Printf("Handler - signature error: %v", err)
+		return gateway.Error(apperror.Wrap(apperror.ErrInvalidToken, err)), nil
+	}
+	if challenge, ok := slack.URLVerification(r.Body); ok {
+		logging.Printf("Handler - url_verification challenge")
+		body, _ := json.Marshal(map[string]string{"challenge": challenge})
+		return gateway.JSON(200, string(body)), nil
+	}
+	query, err := url.ParseQuery(r.Body)
 	if err != nil {
-		log.Printf("%s.Handler - unmarhsal body error: %+v", handler, err)
+		logging.Printf("Handler - unmarhsal body error: %+v", err)
+		return gateway.Error(apperror.Wrap(apperror.ErrBadRequest, err)), nil
 	}
-	query, _ := url.ParseQuery(form.RawQuery)
-	payload := query["payload"][0]
+	payload := query.Get("payload")
 	request := Request{}
 	err = json.Unmarshal([]byte(payload), &request)
 	if err != nil {
-		log.Printf("%s.Handler - unmarhsal payload error: %+v", handler, err)
+		// without a payload there's no response_url to explain the error to
+		// and nothing safe to store
+		logging.Printf("Handler - unmarhsal payload error: %+v", err)
+		return gateway.Error(apperror.Wrap(apperror.ErrBadRequest, err)), nil
 	}
-	if request.Token != os.Getenv("SLACK_VERIFICATION_TOKEN") {
-		err = errors.New("invalid verification token")
-		return Response{
-			StatusCode:      400,
-			IsBase64Encoded: false,
-			Body:            fmt.Sprintf("%s submitting - error: %v", handler, err),
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-		}, err
+	logging.SetUser(request.User.ID, request.Team.ID)
+	ctx = slack.WithTeam(ctx, request.Team.ID)
+
+	if request.Type == "view_submission" {
+		request.fromView()
 	}
 
-	err = request.PutItem()
-	log.Printf("%s.Handler - submitted: %+v, error: %v", handler, request, err)
+	if request.Type == "dialog_submission" || request.Type == "view_submission" {
+		request.resolveWho(ctx)
+		if errs := request.validate(); len(errs) > 0 {
+			logging.Printf("Handler - invalid submission: %+v", errs)
+			return gateway.JSON(200, string(validationErrors(request, errs))), nil
+		}
+	}
 
-	resp := Response{
-		StatusCode:      200,
-		IsBase64Encoded: false,
-		Body:            "",
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
+	if kanowins.Duplicate(request.requestKey(r.Body)) {
+		logging.Printf("Handler - duplicate request ignored: %s", request.ActionTS)
+		return gateway.JSON(200, ""), nil
 	}
 
-	return resp, nil
+	submitted := request.Type == "dialog_submission" || request.Type == "view_submission"
+	if submitted {
+		request.completeDialog()
+	}
+	if submitted && request.CallbackID != "edit-win" && request.throttled() {
+		logging.Printf("Handler - rate limited: %s", request.User.ID)
+		request.reply(ctx, map[string]interface{}{
+			"text": ":turtle: Slow down! You've logged a lot of WINS just now, please try again in a few minutes.",
+		})
+		return gateway.JSON(200, ""), nil
+	}
+
+	switch {
+	case request.Type == "interactive_message" || request.Type == "block_actions":
+		err = request.handleActions(ctx)
+	case request.Type == "message_action":
+		err = request.openFromMessage(ctx)
+	case request.CallbackID == "edit-win":
+		err = request.UpdateItem()
+		request.confirm(ctx, "Your WIN for {who} was updated!", err)
+	case kanowins.DecodeDialogState(request.State).Preview:
+		if err = request.preview(ctx); err != nil {
+			request.confirm(ctx, submitConfirmation(), err)
+		}
+	case !request.forSelf():
+		if err = request.confirmOther(ctx); err != nil {
+			request.confirm(ctx, submitConfirmation(), err)
+		}
+	default:
+		var win kanowins.Win
+		win, err = request.PutItem()
+		stored := 1 + len(request.moreTitles())
+		if err == nil {
+			for i := 0; i < stored; i++ {
+				metrics.Count("WinsSubmitted", map[string]string{"TeamID": request.Team.ID})
+			}
+			request.notifySubject(ctx, append([]kanowins.Win{win}, request.moreWins()...))
+		}
+		template := submitConfirmation()
+		if stored > 1 {
+			template += fmt.Sprintf(" (%d WINS saved)", stored)
+		}
+		request.confirm(ctx, template, err)
+		if err == nil && r.QueryStringParameters["return"] == "win" {
+			// Slack needs an empty body to close the form, so the stored WIN is
+			// only returned to clients asking for it with `?return=win`
+			body, _ := json.Marshal(win)
+			return gateway.JSON(200, string(body)), nil
+		}
+	}
+	logging.Printf("Handler - submitted: %+v, error: %v", request, err)
+	alert.Notify(ctx, handler, err)
+
+	return gateway.JSON(200, ""), nil
 }
 
+// requiredEnv are the environment variables KanowinsInteractiveComponent can't run without
+var requiredEnv = []string{"REGION", "TABLE_NAME", "SLACK_SIGNING_SECRET"}
+
 func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
 	lambda.Start(Handler)
 }
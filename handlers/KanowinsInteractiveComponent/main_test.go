@@ -0,0 +1,982 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+// fakeDynamo points the DynamoDB client at a server answering every call
+// with respond, `{}` when it is nil, returning the operations called. A
+// reply with a `__type` is an error
+func fakeDynamo(t *testing.T, respond func(target, body string) string) *[]string {
+	t.Helper()
+	called := &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := new(strings.Builder)
+		if _, err := io.Copy(body, r.Body); err != nil {
+			t.Errorf("reading DynamoDB request: %v", err)
+		}
+		target := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+		*called = append(*called, target)
+		reply := "{}"
+		if respond != nil {
+			reply = respond(target, body.String())
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if strings.Contains(reply, `"__type"`) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DYNAMODB_ENDPOINT", server.URL)
+	t.Setenv("REGION", "us-west-1")
+	t.Setenv("TABLE_NAME", "wins")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+	return called
+}
+
+// signed returns an interactive request posting payload, signed with secret
+func signed(t *testing.T, secret string, payload interface{}) ProxyRequest {
+	t.Helper()
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshalling payload: %v", err)
+	}
+	return signedForm(secret, url.Values{"payload": {string(encoded)}})
+}
+
+// signedForm returns an interactive request posting form, signed with secret
+func signedForm(secret string, form url.Values) ProxyRequest {
+	body := form.Encode()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	return ProxyRequest{
+		HTTPMethod: "POST",
+		Headers: map[string]string{
+			"X-Slack-Request-Timestamp": ts,
+			"X-Slack-Signature":         "v0=" + hex.EncodeToString(mac.Sum(nil)),
+		},
+		Body: body,
+	}
+}
+
+// submitted returns a dialog submission for a WIN alice logs for Bob
+func submitted(s submission) Request {
+	if s == (submission{}) {
+		s = submission{Who: "Bob", Title: "Shipped the release"}
+	}
+	return Request{
+		Type:       "dialog_submission",
+		CallbackID: "submit-win",
+		ActionTS:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		User:       user{ID: "U1", Name: "alice"},
+		Team:       team{ID: "T1"},
+		Submission: s,
+	}
+}
+
+func TestHandlerRequiredFields(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name       string
+		submission submission
+		wantField  string
+	}{
+		{"missing who", submission{Title: "Shipped the release"}, "who_user"},
+		{"blank who", submission{Who: "  ", Title: "Shipped the release"}, "who_user"},
+		{"missing title", submission{Who: "Bob"}, "title"},
+		{"blank title", submission{Who: "Bob", Title: " \n"}, "title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := fakeDynamo(t, nil)
+			resp, err := Handler(context.Background(), signed(t, "secret", submitted(tt.submission)))
+			if err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if resp.StatusCode != 200 {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+			var body struct {
+				Errors []fieldError `json:"errors"`
+			}
+			if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+				t.Fatalf("decoding %q: %v", resp.Body, err)
+			}
+			if len(body.Errors) != 1 || body.Errors[0].Name != tt.wantField || body.Errors[0].Error == "" {
+				t.Errorf("errors = %+v, want one on %s", body.Errors, tt.wantField)
+			}
+			for _, target := range *called {
+				if target == "PutItem" {
+					t.Errorf("an invalid submission was stored")
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	errs := []fieldError{
+		{Name: "title", Error: "Please give this WIN a title"},
+		{Name: "url", Error: "Please enter a full link"},
+		{Name: "impact", Error: "Please enter the impact as a number"},
+	}
+
+	dialog := validationErrors(submitted(submission{}), errs)
+	var listed struct {
+		Errors []fieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(dialog, &listed); err != nil || len(listed.Errors) != 3 || listed.Errors[1].Name != "url" {
+		t.Errorf("dialog errors = %s, want the field errors listed by name", dialog)
+	}
+
+	modal := Request{Type: "view_submission"}
+	modal.View.State.Values = map[string]map[string]viewValue{
+		"title":  {"title": {Value: ""}},
+		"impact": {"impact": {Value: "lots"}},
+	}
+	var keyed struct {
+		ResponseAction string            `json:"response_action"`
+		Errors         map[string]string `json:"errors"`
+	}
+	body := validationErrors(modal, errs)
+	if err := json.Unmarshal(body, &keyed); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	want := map[string]string{
+		"title":  "Please give this WIN a title. Please enter a full link",
+		"impact": "Please enter the impact as a number",
+	}
+	if keyed.ResponseAction != "errors" || fmt.Sprint(keyed.Errors) != fmt.Sprint(want) {
+		t.Errorf("modal errors = %s, want %v keyed by block with the missing url block's on the title", body, want)
+	}
+}
+
+// conditionFailed is DynamoDB's reply to a put whose condition failed
+const conditionFailed = `{"__type": "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException", "message": "The conditional request failed"}`
+
+// putWinID returns the win_id of a PutItem request body
+func putWinID(t *testing.T, body string) string {
+	t.Helper()
+	var put struct {
+		Item struct {
+			WinID struct {
+				S string `json:"S"`
+			} `json:"win_id"`
+		} `json:"Item"`
+	}
+	if err := json.Unmarshal([]byte(body), &put); err != nil {
+		t.Fatalf("decoding PutItem %s: %v", body, err)
+	}
+	return put.Item.WinID.S
+}
+
+func TestHandlerDuplicateActionTS(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	claimed := map[string]bool{}
+	stored := 0
+	fakeDynamo(t, func(target, body string) string {
+		if target != "PutItem" {
+			return "{}"
+		}
+		id := putWinID(t, body)
+		if !strings.HasPrefix(id, "request#") {
+			stored++
+			return "{}"
+		}
+		if claimed[id] {
+			return conditionFailed
+		}
+		claimed[id] = true
+		return "{}"
+	})
+	request := submitted(submission{Who: "alice", Title: "Shipped the release"})
+	for i := 0; i < 2; i++ {
+		resp, err := Handler(context.Background(), signed(t, "secret", request))
+		if err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if resp.StatusCode != 200 || resp.Body != "" {
+			t.Errorf("submission %d = %d %q, want an empty 200", i+1, resp.StatusCode, resp.Body)
+		}
+	}
+	if stored != 1 {
+		t.Errorf("stored %d WINS for the same action_ts, want 1", stored)
+	}
+}
+
+func TestHandlerDuplicateTrigger(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	claimed := map[string]bool{}
+	stored := 0
+	fakeDynamo(t, func(target, body string) string {
+		if target != "PutItem" {
+			return "{}"
+		}
+		id := putWinID(t, body)
+		if !strings.HasPrefix(id, "request#") {
+			stored++
+			return "{}"
+		}
+		if claimed[id] {
+			return conditionFailed
+		}
+		claimed[id] = true
+		return "{}"
+	})
+	state := kanowins.DialogState{TeamID: "T1", TriggerID: "1.2.3"}.Encode()
+	for i, title := range []string{"Shipped the release", "Shipped the release  "} {
+		// each delivery has its own action_ts and body, only the trigger matches
+		request := submitted(submission{Who: "alice", Title: title})
+		request.State = state
+		resp, err := Handler(context.Background(), signed(t, "secret", request))
+		if err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if resp.StatusCode != 200 || resp.Body != "" {
+			t.Errorf("submission %d = %d %q, want an empty 200", i+1, resp.StatusCode, resp.Body)
+		}
+	}
+	if stored != 1 {
+		t.Errorf("stored %d WINS for the same trigger_id, want 1", stored)
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	withTrigger := submitted(submission{})
+	withTrigger.State = kanowins.DialogState{TriggerID: "1.2.3"}.Encode()
+	click := Request{Type: "block_actions", Team: team{ID: "T1"}, ActionTS: "99.1", State: withTrigger.State}
+	bare := Request{Type: "block_actions", Team: team{ID: "T1"}}
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{"submission with a trigger", withTrigger, "T1:trigger:1.2.3"},
+		{"button click", click, "T1:99.1"},
+		{"neither", bare, kanowins.Fingerprint("body")},
+	}
+	for _, tt := range tests {
+		if got := tt.request.requestKey("body"); got != tt.want {
+			t.Errorf("%s: requestKey = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLengths(t *testing.T) {
+	title := strings.Repeat("t", kanowins.MaxTitleLength)
+	description := strings.Repeat("d", kanowins.MaxDescriptionLength)
+	tests := []struct {
+		name       string
+		submission submission
+		want       []string
+	}{
+		{"at the limits", submission{Who: "Bob", Title: title, Description: description}, nil},
+		{"at the limits once trimmed", submission{Who: " Bob ", Title: "  " + title + "\n", Description: description + "  "}, nil},
+		{"multibyte title at the limit", submission{Who: "Bob", Title: strings.Repeat("é", kanowins.MaxTitleLength)}, nil},
+		{"title over", submission{Who: "Bob", Title: title + "t"}, []string{"title"}},
+		{"description over", submission{Who: "Bob", Title: "Shipped", Description: description + "d"}, []string{"description"}},
+		{"who empty once trimmed", submission{Who: " \t", Title: "Shipped"}, []string{"who_user"}},
+		{"more WIN over", submission{Who: "Bob", Title: "Shipped", More: "ok\n" + title + "t"}, []string{"more"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, e := range submitted(tt.submission).validate() {
+				got = append(got, e.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("validate errors on %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{"https://github.com/anzellai/kanowins/pull/1", true},
+		{"http://example.com/doc?id=1#top", true},
+		{"example.com/pr/1", false},
+		{"/pr/1", false},
+		{"ftp://example.com/file", false},
+		{"https://", false},
+		{"not a url", false},
+		{"https://example.com/a|b", false},
+		{"https://example.com/<b>", false},
+	}
+	for _, tt := range tests {
+		if got := validURL(tt.link); got != tt.want {
+			t.Errorf("validURL(%q) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for name, link := range map[string]string{"invalid": "example.com/pr/1", "valid": " https://example.com/pr/1 "} {
+		t.Run(name, func(t *testing.T) {
+			request := submitted(submission{Who: "Bob", Title: "Shipped", URL: link})
+			errs := request.validate()
+			if name == "invalid" {
+				if len(errs) != 1 || errs[0].Name != "url" {
+					t.Errorf("validate errors = %+v, want one on url", errs)
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Errorf("validate errors = %+v, want none", errs)
+			}
+			if win := request.Win(); win.URL != "https://example.com/pr/1" {
+				t.Errorf("WIN URL = %q, want it trimmed", win.URL)
+			}
+		})
+	}
+}
+
+func TestWinFromDialogState(t *testing.T) {
+	state := kanowins.DialogState{TeamID: "T1", ChannelID: "C1", ChannelName: "general"}.Encode()
+	dialogRequest := submitted(submission{})
+	dialogRequest.State = state
+	modalRequest := Request{
+		Type: "view_submission",
+		User: user{ID: "U1", Name: "alice"},
+		View: view{CallbackID: "submit-win", PrivateMetadata: state},
+	}
+	modalRequest.fromView()
+	for name, request := range map[string]Request{"dialog": dialogRequest, "modal": modalRequest} {
+		win := request.Win()
+		if win.TeamID != "T1" || win.ChannelID != "C1" || win.ChannelName != "general" {
+			t.Errorf("%s WIN in %s/%s/%s, want T1/C1/general", name, win.TeamID, win.ChannelID, win.ChannelName)
+		}
+	}
+}
+
+func TestHandlerMalformedPayload(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	for _, payload := range []string{"{not json", ""} {
+		called := fakeDynamo(t, nil)
+		resp, err := Handler(context.Background(), signedForm("secret", url.Values{"payload": {payload}}))
+		if err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if resp.StatusCode != 400 {
+			t.Errorf("payload %q: status = %d, want 400", payload, resp.StatusCode)
+		}
+		for _, target := range *called {
+			if target == "PutItem" {
+				t.Errorf("payload %q: a malformed payload was stored", payload)
+			}
+		}
+	}
+}
+
+func TestWinDefaultDescription(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   bool
+		value string
+		want  string
+	}{
+		{"unset", false, "", "Big WIN!"},
+		{"custom", true, "Nice one!", "Nice one!"},
+		{"empty", true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_DESCRIPTION", tt.value)
+			if !tt.set {
+				os.Unsetenv("DEFAULT_DESCRIPTION")
+			}
+			request := submitted(submission{Who: "Bob", Title: "Shipped", Description: "  "})
+			if got := request.Win().Description; got != tt.want {
+				t.Errorf("Description = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// responses stands in for a response_url, returning its URL and the
+// messages posted to it
+func responses(t *testing.T) (string, *[]map[string]interface{}) {
+	t.Helper()
+	posted := &[]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("decoding posted message: %v", err)
+		}
+		*posted = append(*posted, message)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok": true}`)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, posted
+}
+
+// previewTable fakes the WINs table for a preview round trip, holding the
+// previews PutItem stores so DeleteItem can hand them back, and returning
+// the WINS batch written
+func previewTable(t *testing.T) (held map[string]string, stored *[]string) {
+	t.Helper()
+	held = map[string]string{}
+	stored = &[]string{}
+	fakeDynamo(t, func(target, body string) string {
+		switch target {
+		case "PutItem":
+			var put struct {
+				Item json.RawMessage `json:"Item"`
+			}
+			json.Unmarshal([]byte(body), &put)
+			if id := putWinID(t, body); strings.HasPrefix(id, "request#preview#") {
+				held[strings.TrimPrefix(id, "request#preview#")] = string(put.Item)
+			} else if !strings.HasPrefix(id, "request#") {
+				*stored = append(*stored, body)
+			}
+		case "DeleteItem":
+			for id, item := range held {
+				if strings.Contains(body, "request#preview#"+id) {
+					delete(held, id)
+					return `{"Attributes": ` + item + `}`
+				}
+			}
+		case "BatchWriteItem":
+			*stored = append(*stored, body)
+		}
+		return "{}"
+	})
+	return held, stored
+}
+
+func TestHandlerPreviewRoundTrip(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name       string
+		action     string
+		wantStored bool
+		wantText   string
+	}{
+		{"confirm", "confirm-win", true, "Your WIN for Bob"},
+		{"discard", "discard-win", false, "Your WIN was discarded, nothing was saved."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			held, stored := previewTable(t)
+			url, posted := responses(t)
+
+			submission := submitted(submission{Who: "Bob", Title: "Shipped the release"})
+			submission.State = kanowins.DialogState{TeamID: "T1", Preview: true}.Encode()
+			submission.ResponseURL = url
+			if _, err := Handler(context.Background(), signed(t, "secret", submission)); err != nil {
+				t.Fatalf("submit Handler error: %v", err)
+			}
+			if len(held) != 1 || len(*stored) != 0 {
+				t.Fatalf("held %d previews and stored %d WINS, want 1 held and none stored", len(held), len(*stored))
+			}
+			previewID := ""
+			for id := range held {
+				previewID = id
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %d messages, want the preview", len(*posted))
+			}
+			preview, _ := json.Marshal((*posted)[0])
+			if !strings.Contains(string(preview), "Shipped the release") || !strings.Contains(string(preview), previewID) {
+				t.Errorf("preview %s doesn't render the WIN with its buttons", preview)
+			}
+
+			click := Request{
+				Type:        "block_actions",
+				User:        user{ID: "U1", Name: "alice"},
+				Team:        team{ID: "T1"},
+				ActionTS:    strconv.FormatInt(time.Now().UnixNano(), 10),
+				ResponseURL: url,
+				Actions:     []action{{ActionID: tt.action, Value: previewID}},
+			}
+			if _, err := Handler(context.Background(), signed(t, "secret", click)); err != nil {
+				t.Fatalf("%s Handler error: %v", tt.action, err)
+			}
+			if got := len(*stored) == 1 && strings.Contains((*stored)[0], "Shipped the release"); got != tt.wantStored {
+				t.Errorf("stored %q, want the WIN stored: %t", *stored, tt.wantStored)
+			}
+			if len(held) != 0 {
+				t.Errorf("preview still held after %s", tt.action)
+			}
+			if text, _ := (*posted)[len(*posted)-1]["text"].(string); !strings.Contains(text, tt.wantText) {
+				t.Errorf("outcome = %q, want %q", text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestHandlerReturnsStoredWin(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	fakeDynamo(t, nil)
+	request := signed(t, "secret", submitted(submission{Who: "alice", Title: "Shipped the release"}))
+	request.QueryStringParameters = map[string]string{"return": "win"}
+	resp, err := Handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	var win kanowins.Win
+	if err := json.Unmarshal([]byte(resp.Body), &win); err != nil {
+		t.Fatalf("decoding %q: %v", resp.Body, err)
+	}
+	if win.WinID == "" || win.CreatedAt.IsZero() || win.UpdatedAt.IsZero() || win.TTL == 0 {
+		t.Errorf("returned WIN %+v is missing its generated fields", win)
+	}
+	if win.Who != "alice" || win.Title != "Shipped the release" || win.UserID != "U1" || win.TeamID != "T1" {
+		t.Errorf("returned WIN %+v, want alice's submission", win)
+	}
+
+	resp, err = Handler(context.Background(), signed(t, "secret", submitted(submission{Who: "alice", Title: "Shipped again"})))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.Body != "" {
+		t.Errorf("body = %q without ?return=win, want empty so Slack closes the form", resp.Body)
+	}
+}
+
+func TestMoreWins(t *testing.T) {
+	tests := []struct {
+		name string
+		more string
+		want []string
+	}{
+		{"none", "", []string{}},
+		{"single line", "Fixed the build", []string{"Fixed the build"}},
+		{"empty lines", "Fixed the build\n\n\nWrote the docs\n", []string{"Fixed the build", "Wrote the docs"}},
+		{"trailing whitespace", "  Fixed the build  \r\n\t\nWrote the docs \t", []string{"Fixed the build", "Wrote the docs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := submitted(submission{Who: "Bob", Title: "Shipped", Impact: "$100", More: tt.more})
+			got := []string{}
+			for _, win := range request.moreWins() {
+				got = append(got, win.Title)
+				if win.Who != "Bob" || win.Impact != 0 {
+					t.Errorf("more WIN %+v, want Bob's without the impact", win)
+				}
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("moreWins titles = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerMoreWinsReported(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	batched := 0
+	fakeDynamo(t, func(target, body string) string {
+		if target == "BatchWriteItem" {
+			batched += strings.Count(body, "PutRequest")
+		}
+		return "{}"
+	})
+	url, posted := responses(t)
+	request := submitted(submission{Who: "alice", Title: "Shipped", More: "Fixed the build\n\nWrote the docs  "})
+	request.ResponseURL = url
+	if _, err := Handler(context.Background(), signed(t, "secret", request)); err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if batched != 2 {
+		t.Errorf("batch wrote %d WINS, want 2", batched)
+	}
+	if len(*posted) != 1 || !strings.Contains((*posted)[0]["text"].(string), "(3 WINS saved)") {
+		t.Errorf("posted %v, want a confirmation counting 3 WINS", *posted)
+	}
+}
+
+func TestForSelf(t *testing.T) {
+	tests := []struct {
+		name       string
+		submission submission
+		want       bool
+	}{
+		{"own name", submission{Who: "alice"}, true},
+		{"own name, mention and case", submission{Who: " @Alice "}, true},
+		{"me", submission{Who: "Me"}, true},
+		{"picked self", submission{WhoUser: "U1", Who: "Alice Smith"}, true},
+		{"someone else", submission{Who: "Bob"}, false},
+		{"picked someone else", submission{WhoUser: "U2", Who: "alice"}, false},
+	}
+	for _, tt := range tests {
+		if got := submitted(tt.submission).forSelf(); got != tt.want {
+			t.Errorf("%s: forSelf = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerConfirmOther(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	tests := []struct {
+		name     string
+		who      string
+		wantHeld bool
+	}{
+		{"self", "alice", false},
+		{"other person", "Bob", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			held, stored := previewTable(t)
+			url, posted := responses(t)
+			request := submitted(submission{Who: tt.who, Title: "Shipped the release"})
+			request.ResponseURL = url
+			if _, err := Handler(context.Background(), signed(t, "secret", request)); err != nil {
+				t.Fatalf("submit Handler error: %v", err)
+			}
+			if got := len(held) == 1 && len(*stored) == 0; got != tt.wantHeld {
+				t.Fatalf("held %d and stored %d WINS, want the WIN held for confirmation: %t", len(held), len(*stored), tt.wantHeld)
+			}
+			if !tt.wantHeld {
+				if len(*stored) != 1 {
+					t.Errorf("stored %d WINS, want 1", len(*stored))
+				}
+				return
+			}
+			message, _ := json.Marshal((*posted)[0])
+			if !strings.Contains(string(message), "rather than you") {
+				t.Errorf("message %s doesn't ask to confirm who the WIN is for", message)
+			}
+			for id := range held {
+				click := Request{
+					Type:        "block_actions",
+					User:        user{ID: "U1", Name: "alice"},
+					Team:        team{ID: "T1"},
+					ActionTS:    strconv.FormatInt(time.Now().UnixNano(), 10),
+					ResponseURL: url,
+					Actions:     []action{{ActionID: "confirm-win", Value: id}},
+				}
+				if _, err := Handler(context.Background(), signed(t, "secret", click)); err != nil {
+					t.Fatalf("confirm Handler error: %v", err)
+				}
+			}
+			if len(*stored) != 1 || !strings.Contains((*stored)[0], "Bob") {
+				t.Errorf("stored %q after confirming, want the WIN for Bob", *stored)
+			}
+		})
+	}
+}
+
+func TestHandlerRateLimited(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("RATE_LIMIT_WINS", "2")
+	t.Setenv("RATE_LIMIT_WINDOW", "1h")
+	hits := 0
+	stored := 0
+	fakeDynamo(t, func(target, body string) string {
+		switch {
+		case target == "UpdateItem" && strings.Contains(body, "request#rate#U1#"):
+			hits++
+			return fmt.Sprintf(`{"Attributes": {"hits": {"N": "%d"}}}`, hits)
+		case target == "PutItem" && !strings.Contains(body, "request#"):
+			stored++
+		}
+		return "{}"
+	})
+	url, posted := responses(t)
+	for i, wantStored := range []int{1, 2, 2} {
+		request := submitted(submission{Who: "alice", Title: "Shipped the release"})
+		request.ResponseURL = url
+		if _, err := Handler(context.Background(), signed(t, "secret", request)); err != nil {
+			t.Fatalf("Handler error: %v", err)
+		}
+		if stored != wantStored {
+			t.Errorf("submission %d: stored %d WINS, want %d", i+1, stored, wantStored)
+		}
+	}
+	last, _ := (*posted)[len(*posted)-1]["text"].(string)
+	if !strings.Contains(last, "Slow down!") {
+		t.Errorf("over the limit replied %q, want the slow down message", last)
+	}
+	for _, message := range (*posted)[:len(*posted)-1] {
+		if text, _ := message["text"].(string); strings.Contains(text, "Slow down!") {
+			t.Errorf("under the limit replied %q", text)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		wins, window string
+		wantLimit    int
+		wantWindow   time.Duration
+	}{
+		{"", "", defaultRateLimit, defaultRateWindow},
+		{"3", "1m", 3, time.Minute},
+		{"zero", "-1m", defaultRateLimit, defaultRateWindow},
+	}
+	for _, tt := range tests {
+		t.Setenv("RATE_LIMIT_WINS", tt.wins)
+		t.Setenv("RATE_LIMIT_WINDOW", tt.window)
+		if limit, window := rateLimit(); limit != tt.wantLimit || window != tt.wantWindow {
+			t.Errorf("rateLimit with %q per %q = %d per %s, want %d per %s", tt.wins, tt.window, limit, window, tt.wantLimit, tt.wantWindow)
+		}
+	}
+}
+
+func TestHandlerSource(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	stored := []string{}
+	fakeDynamo(t, func(target, body string) string {
+		if (target == "PutItem" && !strings.Contains(body, "request#")) || target == "BatchWriteItem" {
+			stored = append(stored, body)
+		}
+		return "{}"
+	})
+	request := submitted(submission{Who: "alice", Title: "Shipped the release", More: "Fixed the build"})
+	if _, err := Handler(context.Background(), signed(t, "secret", request)); err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	all := strings.Join(stored, "\n")
+	if got := strings.Count(all, `"source":{"S":"dialog"}`); got != 2 {
+		t.Errorf("stored %s, want both WINS with the dialog source", all)
+	}
+}
+
+func TestHandlerCompletesDialog(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	for name, triggerID := range map[string]string{"tracked": "1.2.3", "untracked form": ""} {
+		t.Run(name, func(t *testing.T) {
+			completed := []string{}
+			fakeDynamo(t, func(target, body string) string {
+				if target == "UpdateItem" && strings.Contains(body, "request#dialog#") {
+					completed = append(completed, body)
+				}
+				return "{}"
+			})
+			request := submitted(submission{Who: "alice", Title: "Shipped the release"})
+			request.State = kanowins.DialogState{TeamID: "T1", TriggerID: triggerID}.Encode()
+			if _, err := Handler(context.Background(), signed(t, "secret", request)); err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if triggerID == "" {
+				if len(completed) != 0 {
+					t.Errorf("completed %q for a form without a trigger_id", completed)
+				}
+				return
+			}
+			if len(completed) != 1 || !strings.Contains(completed[0], "request#dialog#"+triggerID) {
+				t.Errorf("completed %q, want the dialog for %s", completed, triggerID)
+			}
+		})
+	}
+}
+
+func TestHandlerOversizedBody(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("MAX_BODY_BYTES", "1024")
+	called := fakeDynamo(t, nil)
+	request := submitted(submission{Who: "alice", Title: "Shipped the release", Description: strings.Repeat("d", 2048)})
+	resp, err := Handler(context.Background(), signed(t, "secret", request))
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 413 {
+		t.Errorf("status = %d, want 413", resp.StatusCode)
+	}
+	if len(*called) != 0 {
+		t.Errorf("DynamoDB called %v for an oversized body", *called)
+	}
+}
+
+// fakeSlack points the Slack client at a server answering every method with
+// respond, `{"ok": true}` when it is nil, returning the method and body of
+// each call
+func fakeSlack(t *testing.T, respond func(method, body string) string) *[]string {
+	t.Helper()
+	called := &[]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := new(strings.Builder)
+		if _, err := io.Copy(body, r.Body); err != nil {
+			t.Errorf("reading Slack request: %v", err)
+		}
+		method := strings.TrimPrefix(r.URL.Path, "/")
+		*called = append(*called, method+" "+body.String())
+		reply := `{"ok": true}`
+		if respond != nil {
+			reply = respond(method, body.String())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+	return called
+}
+
+func TestNotifySubject(t *testing.T) {
+	win := kanowins.Win{UserID: "U1", WhoUserID: "U2", ChannelID: "C1", Title: "Shipped the release"}
+	private := win
+	private.Visibility = kanowins.VisibilityPrivate
+	self := win
+	self.WhoUserID = "U1"
+	anonymous := win
+	anonymous.Anonymous = true
+	tests := []struct {
+		name   string
+		notice string
+		wins   []kanowins.Win
+		want   string
+	}{
+		{"dm", "dm", []kanowins.Win{win}, "U2 :trophy: You got a WIN from <@U1>: Shipped the release"},
+		{"channel", "channel", []kanowins.Win{win, win}, "C1 :trophy: <@U2> You got a WIN from <@U1>: Shipped the release (and 1 more)"},
+		{"anonymous", "dm", []kanowins.Win{anonymous}, "U2 :trophy: You got a WIN from a teammate: Shipped the release"},
+		{"off", "", []kanowins.Win{win}, ""},
+		{"invalid setting", "email", []kanowins.Win{win}, ""},
+		{"no Slack user", "dm", []kanowins.Win{{UserID: "U1", Title: "Shipped"}}, ""},
+		{"for themselves", "dm", []kanowins.Win{self}, ""},
+		{"private", "dm", []kanowins.Win{private}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTIFY_SUBJECT", tt.notice)
+			called := fakeSlack(t, nil)
+			submitted(submission{}).notifySubject(context.Background(), tt.wins)
+			if tt.want == "" {
+				if len(*called) != 0 {
+					t.Errorf("Slack called %q, want no notice", *called)
+				}
+				return
+			}
+			if len(*called) != 1 || !strings.HasPrefix((*called)[0], "chat.postMessage ") {
+				t.Fatalf("Slack called %q, want chat.postMessage", *called)
+			}
+			var message struct{ Channel, Text string }
+			json.Unmarshal([]byte(strings.TrimPrefix((*called)[0], "chat.postMessage ")), &message)
+			if got := message.Channel + " " + message.Text; got != tt.want {
+				t.Errorf("posted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWho(t *testing.T) {
+	fakeSlack(t, func(method, body string) string {
+		return `{"ok": true, "user": {"name": "bob", "profile": {"display_name": "Bob Builder"}}}`
+	})
+	tests := []struct {
+		name       string
+		submission submission
+		want       string
+	}{
+		{"picked user", submission{WhoUser: "UBOB"}, "Bob Builder"},
+		{"typed name wins", submission{WhoUser: "UBOB", Who: "Robert"}, "Robert"},
+		{"typed only", submission{Who: "Carol"}, "Carol"},
+	}
+	for _, tt := range tests {
+		request := submitted(tt.submission)
+		request.resolveWho(context.Background())
+		if request.Submission.Who != tt.want {
+			t.Errorf("%s: Who = %q, want %q", tt.name, request.Submission.Who, tt.want)
+		}
+		if win := request.Win(); win.WhoUserID != tt.submission.WhoUser {
+			t.Errorf("%s: WhoUserID = %q, want %q", tt.name, win.WhoUserID, tt.submission.WhoUser)
+		}
+	}
+}
+
+func TestUserSelectSubmission(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"dialog", `{"type": "dialog_submission", "submission": {"who_user": "U2", "title": "Shipped the release"}}`},
+		{"modal", `{"type": "view_submission", "view": {"callback_id": "submit-win", "state": {"values": {
+			"who_user": {"who_user": {"type": "users_select", "selected_user": "U2"}},
+			"title": {"title": {"type": "plain_text_input", "value": "Shipped the release"}}
+		}}}}`},
+	}
+	for _, tt := range tests {
+		var request Request
+		if err := json.Unmarshal([]byte(tt.payload), &request); err != nil {
+			t.Fatalf("%s: decoding payload: %v", tt.name, err)
+		}
+		if request.Type == "view_submission" {
+			request.fromView()
+		}
+		if request.Submission.WhoUser != "U2" || request.Submission.Title != "Shipped the release" {
+			t.Errorf("%s: submission = %+v, want who_user U2", tt.name, request.Submission)
+		}
+	}
+}
+
+func TestValidateWhoFreeText(t *testing.T) {
+	tests := []struct {
+		freeText string
+		want     string
+	}{
+		{"", "who_user"},
+		{"true", "who"},
+	}
+	for _, tt := range tests {
+		t.Setenv("WHO_FREE_TEXT", tt.freeText)
+		errs := submitted(submission{Title: "Shipped the release"}).validate()
+		if len(errs) != 1 || errs[0].Name != tt.want {
+			t.Errorf("WHO_FREE_TEXT %q: validate = %+v, want an error on %s", tt.freeText, errs, tt.want)
+		}
+	}
+}
+
+func TestHandlerSSLCheck(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	called := fakeDynamo(t, nil)
+	resp, err := Handler(context.Background(), ProxyRequest{HTTPMethod: "POST", Body: "ssl_check=1&token=legacy"})
+	if err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Body != "" || len(*called) != 0 {
+		t.Errorf("ssl_check answered %d %q calling %v, want an empty 200 and nothing else", resp.StatusCode, resp.Body, *called)
+	}
+}
+
+func TestValidateImpact(t *testing.T) {
+	tests := []struct {
+		impact  string
+		wantErr bool
+		amount  float64
+		unit    string
+	}{
+		{"", false, 0, ""},
+		{"$12,500", false, 12500, "$"},
+		{"40 hours", false, 40, "hours"},
+		{"a lot", true, 0, ""},
+	}
+	for _, tt := range tests {
+		request := submitted(submission{Who: "Bob", Title: "Shipped the release", Impact: tt.impact})
+		errs := request.validate()
+		if got := len(errs) == 1 && errs[0].Name == "impact"; got != tt.wantErr {
+			t.Errorf("impact %q: validate = %+v, want an impact error: %t", tt.impact, errs, tt.wantErr)
+		}
+		if win := request.Win(); win.Impact != tt.amount || win.ImpactUnit != tt.unit {
+			t.Errorf("impact %q: Win impact = %v %q, want %v %q", tt.impact, win.Impact, win.ImpactUnit, tt.amount, tt.unit)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+const (
+	handler = "KanowinsReminder"
+
+	// defaultReminderDays is how many quiet days trigger a reminder when
+	// REMINDER_DAYS is unset
+	defaultReminderDays = 7
+
+	defaultReminderMessage = ":wave: No WINS logged this week — share one with `/wins`!"
+)
+
+// reminderDays returns the quiet period in days from REMINDER_DAYS, falling
+// back to defaultReminderDays when it is unset or invalid
+func reminderDays() int {
+	value := os.Getenv("REMINDER_DAYS")
+	if value == "" {
+		return defaultReminderDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		logging.Printf("reminderDays - invalid REMINDER_DAYS %q, using %d", value, defaultReminderDays)
+		return defaultReminderDays
+	}
+	return days
+}
+
+// reminderMessage returns the REMINDER_MESSAGE nudge, or the default
+func reminderMessage() string {
+	if message := os.Getenv("REMINDER_MESSAGE"); message != "" {
+		return message
+	}
+	return defaultReminderMessage
+}
+
+// quietTeams reports which workspaces logged no WINS since `since`, the
+// teams on the default webhook are checked together
+func quietTeams(wins []kanowins.Win, teams map[string]slack.TeamConfig, since time.Time) (quiet []string, restQuiet bool) {
+	active := map[string]bool{}
+	restQuiet = true
+	for _, win := range wins {
+		if win.Archived || win.CreatedAt.Before(since) || win.Hidden(time.Now()) {
+			continue
+		}
+		if teams[win.TeamID].Channel != "" {
+			active[win.TeamID] = true
+			continue
+		}
+		restQuiet = false
+	}
+	for teamID, config := range teams {
+		if config.Channel != "" && !active[teamID] {
+			quiet = append(quiet, teamID)
+		}
+	}
+	return
+}
+
+// Handler is our lambda handler invoked weekly by a CloudWatch schedule
+func Handler(ctx context.Context, e events.CloudWatchEvent) (err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		err = errors.New("panic, see log for stack trace")
+	})
+	logging.Printf("Handler - invoke: %+v", e)
+	db, err := kanowins.NewClient()
+	if err != nil {
+		logging.Printf("Handler - NewClient error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return err
+	}
+	wins, err := db.Get()
+	if err != nil {
+		logging.Printf("Handler - Get error: %v", err)
+		alert.Notify(ctx, handler, err)
+		return err
+	}
+	teams := slack.Teams()
+	quiet, restQuiet := quietTeams(wins, teams, time.Now().AddDate(0, 0, -reminderDays()))
+	text := reminderMessage()
+	for _, teamID := range quiet {
+		err := slack.Call(slack.WithTeam(ctx, teamID), "chat.postMessage", map[string]interface{}{
+			"channel": teams[teamID].Channel,
+			"text":    text,
+		})
+		logging.Printf("Handler - chat.postMessage (%s) - error: %v", teamID, err)
+		alert.Notify(ctx, handler, err)
+	}
+	if !restQuiet {
+		logging.Printf("Handler - WINS logged recently, skipping reminder")
+		return nil
+	}
+	err = slack.PostWebhook(ctx, os.Getenv("SLACK_DIGEST_WEBHOOK_URL"), map[string]interface{}{"text": text})
+	logging.Printf("Handler - PostWebhook - error: %v", err)
+	alert.Notify(ctx, handler, err)
+	return err
+}
+
+// requiredEnv are the environment variables KanowinsReminder can't run without
+var requiredEnv = []string{"REGION", "TABLE_NAME", "SLACK_DIGEST_WEBHOOK_URL"}
+
+func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
+	lambda.Start(Handler)
+}
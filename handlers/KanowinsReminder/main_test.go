@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+func TestReminderDays(t *testing.T) {
+	for value, want := range map[string]int{"": 7, "3": 3, "0": 7, "-2": 7, "week": 7} {
+		t.Setenv("REMINDER_DAYS", value)
+		if got := reminderDays(); got != want {
+			t.Errorf("REMINDER_DAYS=%q: reminderDays = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestQuietTeams(t *testing.T) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -7)
+	teams := map[string]slack.TeamConfig{"T1": {Channel: "C1"}, "T2": {Channel: "C2"}, "T3": {}}
+	tests := []struct {
+		name          string
+		wins          []kanowins.Win
+		wantQuiet     []string
+		wantRestQuiet bool
+	}{
+		{"nothing logged", nil, []string{"T1", "T2"}, true},
+		{"one team active", []kanowins.Win{{TeamID: "T1", CreatedAt: now}}, []string{"T2"}, true},
+		{"default workspace active", []kanowins.Win{{CreatedAt: now}, {TeamID: "T3", CreatedAt: now}}, []string{"T1", "T2"}, false},
+		{"only old, archived or scheduled WINS", []kanowins.Win{
+			{TeamID: "T1", CreatedAt: since.Add(-time.Hour)},
+			{TeamID: "T2", CreatedAt: now, Archived: true},
+			{CreatedAt: now, VisibleAfter: now.Add(time.Hour)},
+		}, []string{"T1", "T2"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quiet, restQuiet := quietTeams(tt.wins, teams, since)
+			sort.Strings(quiet)
+			if strings.Join(quiet, ",") != strings.Join(tt.wantQuiet, ",") || restQuiet != tt.wantRestQuiet {
+				t.Errorf("quietTeams = %v, %t, want %v, %t", quiet, restQuiet, tt.wantQuiet, tt.wantRestQuiet)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		wins       []kanowins.Win
+		wantNudged bool
+	}{
+		{"quiet", []kanowins.Win{{WinID: "1", CreatedAt: time.Now().AddDate(0, 0, -10)}}, true},
+		{"WINS logged", []kanowins.Win{{WinID: "1", CreatedAt: time.Now().Add(-time.Hour)}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []map[string]*dynamodb.AttributeValue{}
+			for _, win := range tt.wins {
+				item, err := dynamodbattribute.MarshalMap(win)
+				if err != nil {
+					t.Fatalf("MarshalMap error: %v", err)
+				}
+				items = append(items, item)
+			}
+			scan, err := jsonutil.BuildJSON(&dynamodb.ScanOutput{Items: items})
+			if err != nil {
+				t.Fatalf("BuildJSON error: %v", err)
+			}
+			dynamo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+				w.Write(scan)
+			}))
+			defer dynamo.Close()
+			nudges := []string{}
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				nudges = append(nudges, string(body))
+				w.Write([]byte("ok"))
+			}))
+			defer webhook.Close()
+			t.Setenv("DYNAMODB_ENDPOINT", dynamo.URL)
+			t.Setenv("REGION", "us-west-1")
+			t.Setenv("TABLE_NAME", "wins")
+			t.Setenv("AWS_ACCESS_KEY_ID", "test")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+			t.Setenv("SCAN_CACHE_SECONDS", "0")
+			t.Setenv("SLACK_DIGEST_WEBHOOK_URL", webhook.URL)
+			t.Setenv("REMINDER_MESSAGE", "Share a WIN!")
+			if err := Handler(context.Background(), events.CloudWatchEvent{}); err != nil {
+				t.Fatalf("Handler error: %v", err)
+			}
+			if got := len(nudges) == 1 && strings.Contains(nudges[0], "Share a WIN!"); got != tt.wantNudged {
+				t.Errorf("nudges = %q, want nudged: %t", nudges, tt.wantNudged)
+			}
+		})
+	}
+}
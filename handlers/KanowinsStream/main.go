@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/alert"
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+const handler = "KanowinsStream"
+
+// decodeWin converts a stream record image into a Win through its DynamoDB
+// JSON
+func decodeWin(image map[string]events.DynamoDBAttributeValue) (win kanowins.Win, err error) {
+	data, err := json.Marshal(image)
+	if err != nil {
+		return
+	}
+	item := map[string]*dynamodb.AttributeValue{}
+	if err = json.Unmarshal(data, &item); err != nil {
+		return
+	}
+	err = dynamodbattribute.UnmarshalMap(item, &win)
+	return
+}
+
+// streamChannel returns the channel teamID's new WINS are announced in, the
+// `stream_channel` of its SLACK_TEAM_CONFIG entry or STREAM_NOTIFY_CHANNEL
+// for the default workspace, "" when it has none
+func streamChannel(teamID string, teams map[string]slack.TeamConfig) string {
+	if config, ok := teams[teamID]; ok && teamID != "" {
+		return config.StreamChannel
+	}
+	return os.Getenv("STREAM_NOTIFY_CHANNEL")
+}
+
+// announced reports whether win is a WIN new to its team's summaries, rather
+// than a request marker, private or scheduled for later
+func announced(win kanowins.Win, now time.Time) bool {
+	return !kanowins.IsMarker(win.WinID) && !win.Archived && !win.Hidden(now) && win.VisibleTo(kanowins.VisibilityTeam)
+}
+
+// notify posts a newly logged WIN to channel
+func notify(ctx context.Context, channel string, win kanowins.Win) error {
+	summary := kanowins.Summarize([]kanowins.Win{win})[0]
+	_, err := slack.PostMessage(ctx, map[string]interface{}{
+		"channel": channel,
+		"text":    "New WIN for " + kanowins.SanitizeMrkdwn(win.Who) + ": " + kanowins.SanitizeMrkdwn(win.Title),
+		"blocks":  kanowins.BuildSpotlightBlocks(":tada: New WIN", summary),
+	})
+	return err
+}
+
+// Handler is our lambda handler invoked by the WINs table stream. Only
+// inserted WINs are announced, each in its own team's channel. A failed post
+// is logged rather than returned so the batch isn't retried and announced
+// twice
+func Handler(ctx context.Context, e events.DynamoDBEvent) (err error) {
+	logging.Start(ctx, handler)
+	defer logging.Recover(func() {
+		err = errors.New("panic, see log for stack trace")
+	})
+	logging.Printf("Handler - invoke: %d records", len(e.Records))
+	teams := slack.Teams()
+	for _, record := range e.Records {
+		if record.EventName != string(events.DynamoDBOperationTypeInsert) {
+			continue
+		}
+		win, err := decodeWin(record.Change.NewImage)
+		if err != nil {
+			logging.Printf("Handler - decodeWin (%s) error: %v", record.EventID, err)
+			continue
+		}
+		if !announced(win, time.Now()) {
+			continue
+		}
+		channel := streamChannel(win.TeamID, teams)
+		if channel == "" {
+			logging.Printf("Handler - no stream channel for %s, skipping %s", win.TeamID, win.WinID)
+			continue
+		}
+		err = notify(slack.WithTeam(ctx, win.TeamID), channel, win)
+		logging.Printf("Handler - notify (%s/%s/%s) - error: %v", win.WinID, win.Who, win.Title, err)
+		alert.Notify(ctx, handler, err)
+	}
+	return nil
+}
+
+// requiredEnv are the environment variables KanowinsStream can't run without
+var requiredEnv = []string{"SLACK_ACCESS_TOKEN"}
+
+func main() {
+	for _, name := range requiredEnv {
+		kanowins.MustEnv(name)
+	}
+	lambda.Start(Handler)
+}
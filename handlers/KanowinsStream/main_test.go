@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+// sampleEvent is a WINs table stream batch as Lambda receives it, one new
+// WIN for each of two teams, an edit and a request marker
+const sampleEvent = `{"Records": [
+	{"eventID": "1", "eventName": "INSERT", "dynamodb": {"NewImage": {
+		"win_id": {"S": "w1"}, "team_id": {"S": "T1"}, "user_id": {"S": "U1"},
+		"who": {"S": "Alice"}, "title": {"S": "Shipped the release"},
+		"kudos": {"N": "0"}, "created_at": {"S": "2024-02-01T10:00:00Z"}}}},
+	{"eventID": "2", "eventName": "INSERT", "dynamodb": {"NewImage": {
+		"win_id": {"S": "w2"}, "team_id": {"S": "T2"}, "user_id": {"S": "U2"},
+		"who": {"S": "Bob"}, "title": {"S": "Closed the deal"}}}},
+	{"eventID": "3", "eventName": "MODIFY", "dynamodb": {"NewImage": {
+		"win_id": {"S": "w3"}, "team_id": {"S": "T1"}, "who": {"S": "Carol"}, "title": {"S": "Edited"}}}},
+	{"eventID": "4", "eventName": "INSERT", "dynamodb": {"NewImage": {
+		"win_id": {"S": "request#abc"}, "team_id": {"S": "T1"}}}}
+]}`
+
+func TestDecodeWin(t *testing.T) {
+	var e events.DynamoDBEvent
+	if err := json.Unmarshal([]byte(sampleEvent), &e); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	win, err := decodeWin(e.Records[0].Change.NewImage)
+	if err != nil {
+		t.Fatalf("decodeWin error: %v", err)
+	}
+	want := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+	if win.WinID != "w1" || win.TeamID != "T1" || win.Who != "Alice" || win.Title != "Shipped the release" || !win.CreatedAt.Equal(want) {
+		t.Errorf("decodeWin = %+v", win)
+	}
+}
+
+func TestAnnounced(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		win  kanowins.Win
+		want bool
+	}{
+		{"team", kanowins.Win{WinID: "w1"}, true},
+		{"public", kanowins.Win{WinID: "w1", Visibility: kanowins.VisibilityPublic}, true},
+		{"private", kanowins.Win{WinID: "w1", Visibility: kanowins.VisibilityPrivate}, false},
+		{"archived", kanowins.Win{WinID: "w1", Archived: true}, false},
+		{"scheduled", kanowins.Win{WinID: "w1", VisibleAfter: now.Add(time.Hour)}, false},
+		{"marker", kanowins.Win{WinID: "request#abc"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := announced(tt.win, now); got != tt.want {
+				t.Errorf("announced = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamChannel(t *testing.T) {
+	t.Setenv("STREAM_NOTIFY_CHANNEL", "CDEFAULT")
+	teams := map[string]slack.TeamConfig{
+		"T1": {StreamChannel: "C1"},
+		"T2": {Channel: "CDIGEST"},
+	}
+	tests := []struct {
+		teamID string
+		want   string
+	}{
+		{"T1", "C1"},
+		{"T2", ""},
+		{"T3", "CDEFAULT"},
+		{"", "CDEFAULT"},
+	}
+	for _, tt := range tests {
+		if got := streamChannel(tt.teamID, teams); got != tt.want {
+			t.Errorf("streamChannel(%q) = %q, want %q", tt.teamID, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	posted := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message struct {
+			Channel string `json:"channel"`
+			Text    string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&message)
+		posted[message.Channel] = message.Text
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "ts": "1.2"}`))
+	}))
+	defer server.Close()
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-default")
+	t.Setenv("STREAM_NOTIFY_CHANNEL", "CDEFAULT")
+	t.Setenv("SLACK_TEAM_CONFIG", `{"T2": {"access_token": "xoxb-t2"}}`)
+
+	var e events.DynamoDBEvent
+	if err := json.Unmarshal([]byte(sampleEvent), &e); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if err := Handler(context.Background(), e); err != nil {
+		t.Fatalf("Handler error: %v", err)
+	}
+	want := map[string]string{"CDEFAULT": "New WIN for Alice: Shipped the release"}
+	if len(posted) != len(want) || posted["CDEFAULT"] != want["CDEFAULT"] {
+		t.Errorf("posted = %v, want %v", posted, want)
+	}
+}
@@ -0,0 +1,74 @@
+// Package alert posts a redacted notice of unexpected handler failures to
+// the ops channel behind OPS_ALERT_WEBHOOK_URL, so operators notice storage
+// or Slack outages without watching CloudWatch. An alert names the handler,
+// an error code and the Lambda request ID, never the error text, which can
+// quote user content
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	"github.com/anzellai/kanowins/internal/apperror"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+// Interval is the least time between two alerts for the same error code,
+// tracked per Lambda container so a burst of failures sends one alert
+const Interval = time.Minute
+
+var (
+	mu   sync.Mutex
+	sent = map[string]time.Time{}
+)
+
+// Code classifies err for an alert, by the DynamoDB or Slack error code when
+// err came from either, else by its apperror Kind
+func Code(err error) string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return "aws:" + aerr.Code()
+	}
+	var serr *slack.Error
+	if errors.As(err, &serr) {
+		return "slack:" + serr.Code
+	}
+	return apperror.Code(err)
+}
+
+// allow reports whether an alert for code may be sent at now, recording it
+// as sent when it may
+func allow(code string, now time.Time) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if last, ok := sent[code]; ok && now.Sub(last) < Interval {
+		return false
+	}
+	sent[code] = now
+	return true
+}
+
+// Notify alerts the ops channel that handler failed with err, at most once
+// per code within Interval
+func Notify(ctx context.Context, handler string, err error) {
+	webhookURL := os.Getenv("OPS_ALERT_WEBHOOK_URL")
+	if webhookURL == "" || err == nil {
+		return
+	}
+	code := Code(err)
+	if !allow(code, time.Now()) {
+		logging.Printf("alert.Notify - %s alert suppressed, one was sent within %s", code, Interval)
+		return
+	}
+	text := fmt.Sprintf(":rotating_light: *%s* failed with `%s` (request %s)", handler, code, logging.RequestID())
+	if postErr := slack.PostWebhook(ctx, webhookURL, map[string]string{"text": text}); postErr != nil {
+		logging.Printf("alert.Notify - PostWebhook error: %v", postErr)
+	}
+}
@@ -0,0 +1,71 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	"github.com/anzellai/kanowins/internal/apperror"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"dynamodb", awserr.New("ProvisionedThroughputExceededException", "slow down", nil), "aws:ProvisionedThroughputExceededException"},
+		{"slack", &slack.Error{Code: "channel_not_found"}, "slack:channel_not_found"},
+		{"kind", apperror.Wrap(apperror.ErrStorage, errors.New("boom")), "storage"},
+		{"other", errors.New("boom"), "internal"},
+	}
+	for _, tt := range tests {
+		if got := Code(tt.err); got != tt.want {
+			t.Errorf("%s: Code = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	alerts := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message map[string]string
+		json.NewDecoder(r.Body).Decode(&message)
+		alerts = append(alerts, message["text"])
+	}))
+	defer server.Close()
+	t.Setenv("OPS_ALERT_WEBHOOK_URL", server.URL)
+	sent = map[string]time.Time{}
+	defer func() { sent = map[string]time.Time{} }()
+
+	storage := awserr.New("ResourceNotFoundException", "table wins for Alice's secret WIN not found", nil)
+	Notify(context.Background(), "KanowinsCommand", storage)
+	Notify(context.Background(), "KanowinsCommand", storage)
+	Notify(context.Background(), "KanowinsCommand", errors.New("Bob's WIN failed"))
+	Notify(context.Background(), "KanowinsCommand", nil)
+	if len(alerts) != 2 {
+		t.Fatalf("sent %d alerts %q, want one per code", len(alerts), alerts)
+	}
+	if !strings.Contains(alerts[0], "*KanowinsCommand*") || !strings.Contains(alerts[0], "`aws:ResourceNotFoundException`") {
+		t.Errorf("alert = %q, want the handler and error code", alerts[0])
+	}
+	for _, alert := range alerts {
+		if strings.Contains(alert, "Alice") || strings.Contains(alert, "Bob") {
+			t.Errorf("alert %q quotes the error text", alert)
+		}
+	}
+
+	sent["aws:ResourceNotFoundException"] = time.Now().Add(-Interval)
+	Notify(context.Background(), "KanowinsCommand", storage)
+	if len(alerts) != 3 {
+		t.Errorf("sent %d alerts, want another once Interval has passed", len(alerts))
+	}
+}
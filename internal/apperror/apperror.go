@@ -0,0 +1,91 @@
+// Package apperror maps handler failures to an HTTP status and a message
+// safe to show users
+package apperror
+
+import "errors"
+
+// Kind is a class of failure with its HTTP status and user facing message,
+// Code names it in alerts and logs
+type Kind struct {
+	Code    string
+	Status  int
+	Message string
+}
+
+func (k *Kind) Error() string {
+	return k.Message
+}
+
+var (
+	// ErrBadRequest is a request body or payload that couldn't be read
+	ErrBadRequest = &Kind{Code: "bad_request", Status: 400, Message: "The request couldn't be read."}
+
+	// ErrTooLarge is a request body over the configured size limit
+	ErrTooLarge = &Kind{Code: "too_large", Status: 413, Message: "The request is too large."}
+
+	// ErrInvalidToken is a request whose Slack signature didn't verify
+	ErrInvalidToken = &Kind{Code: "invalid_token", Status: 401, Message: "The request couldn't be verified."}
+
+	// ErrForbidden is a summary link that has expired or was tampered with
+	ErrForbidden = &Kind{Code: "forbidden", Status: 403, Message: "This link has expired or is invalid."}
+
+	// ErrStorage is a failed call to the WINs table
+	ErrStorage = &Kind{Code: "storage", Status: 500, Message: "WINS storage is unavailable, please try again."}
+
+	// ErrInternal is any other failure
+	ErrInternal = &Kind{Code: "internal", Status: 500, Message: "Something went wrong, please try again."}
+)
+
+// Error wraps an internal error with the Kind it is reported as
+type Error struct {
+	Kind *Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Message + " " + e.Err.Error()
+}
+
+// Unwrap returns the internal error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the error's Kind, so errors.Is(err,
+// ErrStorage) matches a wrapped storage failure
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Wrap classifies err as kind
+func Wrap(kind *Kind, err error) error {
+	return &Error{Kind: kind, Err: err}
+}
+
+// Public returns the HTTP status and user facing message for err, an error
+// without a Kind is reported as ErrInternal so its detail never reaches users
+func Public(err error) (int, string) {
+	var wrapped *Error
+	if errors.As(err, &wrapped) {
+		return wrapped.Kind.Status, wrapped.Kind.Message
+	}
+	var kind *Kind
+	if errors.As(err, &kind) {
+		return kind.Status, kind.Message
+	}
+	return ErrInternal.Status, ErrInternal.Message
+}
+
+// Code returns the Code of err's Kind, an error without a Kind is reported
+// as ErrInternal's
+func Code(err error) string {
+	var wrapped *Error
+	if errors.As(err, &wrapped) {
+		return wrapped.Kind.Code
+	}
+	var kind *Kind
+	if errors.As(err, &kind) {
+		return kind.Code
+	}
+	return ErrInternal.Code
+}
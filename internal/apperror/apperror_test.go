@@ -0,0 +1,51 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	internal := errors.New("ResourceNotFoundException: Requested resource not found\ngoroutine 1 [running]:\nmain.Handler(0xc000010000)")
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		want       string
+	}{
+		{"kind", ErrInvalidToken, 401, "invalid_token", ErrInvalidToken.Message},
+		{"wrapped", Wrap(ErrStorage, internal), 500, "storage", ErrStorage.Message},
+		{"wrapped again", fmt.Errorf("submitting: %w", Wrap(ErrBadRequest, internal)), 400, "bad_request", ErrBadRequest.Message},
+		{"unclassified", internal, 500, "internal", ErrInternal.Message},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := Public(tt.err)
+			if status != tt.wantStatus || message != tt.want {
+				t.Errorf("Public = %d %q, want %d %q", status, message, tt.wantStatus, tt.want)
+			}
+			for _, detail := range []string{"ResourceNotFoundException", "goroutine", "main.Handler"} {
+				if strings.Contains(message, detail) {
+					t.Errorf("Public message %q leaks %q", message, detail)
+				}
+			}
+			if code := Code(tt.err); code != tt.wantCode {
+				t.Errorf("Code = %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWrapIs(t *testing.T) {
+	internal := errors.New("throttled")
+	err := fmt.Errorf("saving: %w", Wrap(ErrStorage, internal))
+	if !errors.Is(err, ErrStorage) || !errors.Is(err, internal) {
+		t.Errorf("errors.Is(%v) doesn't match both the Kind and the internal error", err)
+	}
+	if errors.Is(err, ErrInternal) {
+		t.Errorf("errors.Is(%v, ErrInternal) = true, want false", err)
+	}
+}
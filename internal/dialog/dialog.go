@@ -0,0 +1,369 @@
+// Package dialog builds the WIN form shared by KanowinsCommand and
+// KanowinsInteractiveComponent, opened as a legacy dialog or, when
+// USE_MODALS is set, as a Block Kit modal
+package dialog
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+	"github.com/anzellai/kanowins/internal/logging"
+	"github.com/anzellai/kanowins/internal/slack"
+)
+
+const (
+	defaultDialogTitle       = "Submit a WIN"
+	defaultDialogSubmitLabel = "Submit"
+
+	// maxDialogLabel is Slack's character limit for a dialog title and
+	// submit label
+	maxDialogLabel = 24
+
+	// maxDialogElements is Slack's limit on the elements in a dialog
+	maxDialogElements = 10
+)
+
+// Payload is the dialog.open request body
+type Payload struct {
+	TriggerID string `json:"trigger_id"`
+	Dialog    Dialog `json:"dialog"`
+}
+
+// Dialog is a legacy Slack dialog
+type Dialog struct {
+	Title       string    `json:"title"`
+	CallbackID  string    `json:"callback_id"`
+	SubmitLabel string    `json:"submit_label"`
+	State       string    `json:"state,omitempty"`
+	Elements    []Element `json:"elements"`
+}
+
+// Element is one dialog form field
+type Element struct {
+	Label      string   `json:"label"`
+	Type       string   `json:"type"`
+	Name       string   `json:"name"`
+	Value      string   `json:"value,omitempty"`
+	Hint       string   `json:"hint,omitempty"`
+	Subtype    string   `json:"subtype,omitempty"`
+	MaxLength  int      `json:"max_length,omitempty"`
+	Optional   bool     `json:"optional"`
+	Options    []Option `json:"options,omitempty"`
+	DataSource string   `json:"data_source,omitempty"`
+}
+
+// Option is one choice of a select element
+type Option struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Title returns the submit dialog title from the team's config table row,
+// SLACK_TEAM_CONFIG entry or DIALOG_TITLE, truncated to Slack's limit
+func Title(teamID string) string {
+	title := kanowins.ConfigFor(teamID).DialogTitle
+	if title == "" {
+		title = slack.ConfigFor(teamID).DialogTitle
+	}
+	if title == "" {
+		title = os.Getenv("DIALOG_TITLE")
+	}
+	if title == "" {
+		return defaultDialogTitle
+	}
+	return truncate(title, maxDialogLabel)
+}
+
+// SubmitLabel returns the team's dialog submit label, the default unless it
+// is a single word
+func SubmitLabel(teamID string) string {
+	label := kanowins.ConfigFor(teamID).DialogSubmitLabel
+	if label == "" {
+		label = slack.ConfigFor(teamID).DialogSubmitLabel
+	}
+	if label == "" {
+		label = os.Getenv("DIALOG_SUBMIT_LABEL")
+	}
+	if label == "" {
+		return defaultDialogSubmitLabel
+	}
+	if strings.ContainsAny(label, " \t\n") {
+		logging.Printf("dialog.SubmitLabel - submit label %q must be a single word, using %q", label, defaultDialogSubmitLabel)
+		return defaultDialogSubmitLabel
+	}
+	return truncate(label, maxDialogLabel)
+}
+
+// truncate shortens text to at most max characters
+func truncate(text string, max int) string {
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max])
+	}
+	return text
+}
+
+// whoElements asks who win is for, by Slack user or by a typed name
+func whoElements(win kanowins.Win) []Element {
+	picker := Element{
+		Label:      "Who?",
+		Type:       "select",
+		DataSource: "users",
+		Name:       "who_user",
+		Value:      win.WhoUserID,
+		Hint:       "The person who has this WIN",
+	}
+	if !kanowins.WhoFreeText(win.TeamID) {
+		return []Element{picker}
+	}
+	picker.Label = "Their Slack user"
+	picker.Hint = "Pick them in Slack so they hear about their WIN"
+	picker.Optional = true
+	return []Element{
+		Element{
+			Label: "Who?",
+			Type:  "text",
+			Name:  "who",
+			Value: win.Who,
+			Hint:  "The name of the person who has this WIN",
+		},
+		picker,
+	}
+}
+
+// impact prefills the impact field with win's impact as typed, empty when
+// it has none
+func impact(win kanowins.Win) string {
+	if win.Impact == 0 {
+		return ""
+	}
+	return kanowins.FormatImpact(win.Impact, win.ImpactUnit)
+}
+
+// New builds the WIN dialog with its fields pre-populated from win, state
+// is passed back untouched to KanowinsInteractiveComponent on submit
+func New(title, submitLabel, callbackID, state string, win kanowins.Win) Dialog {
+	dialog := Dialog{
+		Title:       title,
+		CallbackID:  callbackID,
+		SubmitLabel: submitLabel,
+		State:       state,
+		Elements: append(whoElements(win), []Element{
+			Element{
+				Label:     "Title",
+				Type:      "text",
+				Name:      "title",
+				Value:     win.Title,
+				Hint:      "Title of this WIN",
+				MaxLength: kanowins.MaxTitleLength,
+			},
+			Element{
+				Label:     "Long description",
+				Type:      "textarea",
+				Name:      "description",
+				Value:     win.Description,
+				Hint:      "Long description of this WIN (if any)",
+				MaxLength: kanowins.MaxDescriptionLength,
+				Optional:  true,
+			},
+			Element{
+				Label:    "Link",
+				Type:     "text",
+				Subtype:  "url",
+				Name:     "url",
+				Value:    win.URL,
+				Hint:     "A PR, doc or ticket for this WIN (if any)",
+				Optional: true,
+			},
+			Element{
+				Label:    "Impact",
+				Type:     "text",
+				Name:     "impact",
+				Value:    impact(win),
+				Hint:     "What this WIN was worth (if measurable), such as $12,500 or 40 hours",
+				Optional: true,
+			},
+		}...),
+	}
+	anonymous := "no"
+	if win.Anonymous {
+		anonymous = "yes"
+	}
+	dialog.Elements = append(dialog.Elements, Element{
+		Label: "Submit anonymously?",
+		Type:  "select",
+		Name:  "anonymous",
+		Value: anonymous,
+		Hint:  "Anonymous WINS don't show your name",
+		Options: []Option{
+			Option{Label: "No", Value: "no"},
+			Option{Label: "Yes", Value: "yes"},
+		},
+	})
+	dialog.Elements = append(dialog.Elements, Element{
+		Label: "Who can see it?",
+		Type:  "select",
+		Name:  "visibility",
+		Value: win.Scope(),
+		Hint:  "Private WINS only show in your /wins mine, public ones also make the digest",
+		Options: []Option{
+			Option{Label: "Only me", Value: kanowins.VisibilityPrivate},
+			Option{Label: "My team", Value: kanowins.VisibilityTeam},
+			Option{Label: "Everyone", Value: kanowins.VisibilityPublic},
+		},
+	})
+	if callbackID == "submit-win" && !kanowins.DecodeDialogState(state).Preview {
+		// a preview shows a single WIN
+		dialog.Elements = append(dialog.Elements, Element{
+			Label:    "More WINS",
+			Type:     "textarea",
+			Name:     "more",
+			Hint:     "Any more WINS for them, one title per line",
+			Optional: true,
+		})
+	}
+	if categories := kanowins.ConfigFor(win.TeamID).Categories; len(categories) > 0 {
+		options := []Option{}
+		for _, category := range categories {
+			options = append(options, Option{Label: category, Value: category})
+		}
+		dialog.Elements = append(dialog.Elements, Element{
+			Label:    "Category",
+			Type:     "select",
+			Name:     "category",
+			Value:    win.Category,
+			Optional: true,
+			Options:  options,
+		})
+	}
+	return dialog
+}
+
+// Open asks Slack to open the dialog for the given trigger, as a
+// Block Kit modal through views.open when USE_MODALS is set since
+// dialog.open is deprecated
+func Open(ctx context.Context, triggerID string, dialog Dialog) error {
+	if useModals() {
+		return slack.Call(ctx, "views.open", map[string]interface{}{
+			"trigger_id": triggerID,
+			"view":       newModal(dialog),
+		})
+	}
+	return slack.Call(ctx, "dialog.open", Payload{
+		TriggerID: triggerID,
+		Dialog:    fit(dialog),
+	})
+}
+
+// droppable are the elements fit leaves out of a dialog over Slack's limit,
+// in order, each is optional with a default the submission falls back to
+var droppable = []string{"more"}
+
+// fit drops droppable elements from a dialog with more than Slack allows,
+// modals keep them all
+func fit(dialog Dialog) Dialog {
+	for _, name := range droppable {
+		if len(dialog.Elements) <= maxDialogElements {
+			break
+		}
+		elements := []Element{}
+		for _, element := range dialog.Elements {
+			if element.Name != name {
+				elements = append(elements, element)
+			}
+		}
+		dialog.Elements = elements
+		logging.Printf("dialog.fit - dropped %q to fit Slack's %d element limit", name, maxDialogElements)
+	}
+	return dialog
+}
+
+// useModals reports whether USE_MODALS switches the WIN form to modals
+func useModals() bool {
+	switch strings.ToLower(os.Getenv("USE_MODALS")) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// newModal converts a dialog to the equivalent modal view, an input block
+// per element
+//
+// https://api.slack.com/block-kit/dialogs-to-modals
+func newModal(dialog Dialog) map[string]interface{} {
+	blocks := []map[string]interface{}{}
+	for _, element := range dialog.Elements {
+		input := map[string]interface{}{
+			"type":      "plain_text_input",
+			"action_id": element.Name,
+		}
+		if element.Type == "textarea" {
+			input["multiline"] = true
+		}
+		if element.Subtype == "url" {
+			input["type"] = "url_text_input"
+		}
+		if element.Value != "" {
+			input["initial_value"] = element.Value
+		}
+		if element.MaxLength > 0 {
+			input["max_length"] = element.MaxLength
+		}
+		if element.DataSource == "users" {
+			input = map[string]interface{}{
+				"type":      "users_select",
+				"action_id": element.Name,
+			}
+			if element.Value != "" {
+				input["initial_user"] = element.Value
+			}
+		} else if element.Type == "select" {
+			input = map[string]interface{}{
+				"type":      "static_select",
+				"action_id": element.Name,
+			}
+			options := []map[string]interface{}{}
+			for _, option := range element.Options {
+				o := map[string]interface{}{
+					"text":  plainText(option.Label),
+					"value": option.Value,
+				}
+				if option.Value == element.Value {
+					input["initial_option"] = o
+				}
+				options = append(options, o)
+			}
+			input["options"] = options
+		}
+		block := map[string]interface{}{
+			"type":     "input",
+			"block_id": element.Name,
+			"label":    plainText(element.Label),
+			"element":  input,
+			"optional": element.Optional,
+		}
+		if element.Hint != "" {
+			block["hint"] = plainText(element.Hint)
+		}
+		blocks = append(blocks, block)
+	}
+	return map[string]interface{}{
+		"type":             "modal",
+		"callback_id":      dialog.CallbackID,
+		"private_metadata": dialog.State,
+		"title":            plainText(dialog.Title),
+		"submit":           plainText(dialog.SubmitLabel),
+		"close":            plainText("Cancel"),
+		"blocks":           blocks,
+	}
+}
+
+// plainText builds a Block Kit plain_text object
+func plainText(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "plain_text",
+		"text": text,
+	}
+}
@@ -0,0 +1,97 @@
+package dialog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anzellai/kanowins/internal/kanowins"
+)
+
+func TestOpenLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		title     string
+		label     string
+		wantTitle string
+		wantLabel string
+	}{
+		{"defaults", "", "", "Submit a WIN", "Submit"},
+		{"custom", "Log a Kanowin", "Log", "Log a Kanowin", "Log"},
+		{"truncated", "Tell us about your latest WIN", "Celebrate", "Tell us about your lates", "Celebrate"},
+		{"label with spaces", "", "Log it", "Submit a WIN", "Submit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DIALOG_TITLE", tt.title)
+			t.Setenv("DIALOG_SUBMIT_LABEL", tt.label)
+			var sent Payload
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+					t.Errorf("decoding dialog.open: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"ok": true}`))
+			}))
+			defer server.Close()
+			t.Setenv("SLACK_API_BASE", server.URL)
+			t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+			if err := Open(context.Background(), "1.2.3", New(Title("T1"), SubmitLabel("T1"), "submit-win", "", kanowins.Win{})); err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			if sent.Dialog.Title != tt.wantTitle || sent.Dialog.SubmitLabel != tt.wantLabel {
+				t.Errorf("dialog.open title %q, label %q, want %q and %q", sent.Dialog.Title, sent.Dialog.SubmitLabel, tt.wantTitle, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestWhoElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		freeText string
+		want     []string
+		optional bool
+	}{
+		{"user picker", "", []string{"who_user"}, false},
+		{"free text", "true", []string{"who", "who_user"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WHO_FREE_TEXT", tt.freeText)
+			elements := whoElements(kanowins.Win{TeamID: "T1", WhoUserID: "U2"})
+			names := []string{}
+			for _, element := range elements {
+				names = append(names, element.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("whoElements names = %v, want %v", names, tt.want)
+			}
+			picker := elements[len(elements)-1]
+			if picker.Type != "select" || picker.DataSource != "users" || picker.Value != "U2" || picker.Optional != tt.optional {
+				t.Errorf("picker = %+v, want users select of U2 with optional %v", picker, tt.optional)
+			}
+		})
+	}
+}
+
+func TestFit(t *testing.T) {
+	t.Setenv("WHO_FREE_TEXT", "true")
+	t.Setenv("WIN_CATEGORIES", "Shipping,Customer")
+	dialog := New("Submit a WIN", "Submit", "submit-win", "", kanowins.Win{TeamID: "T1"})
+	dialog.Elements = append(dialog.Elements, Element{Name: "more", Type: "textarea"})
+	fitted := fit(dialog)
+	if len(fitted.Elements) > maxDialogElements {
+		t.Fatalf("fit left %d elements, want at most %d", len(fitted.Elements), maxDialogElements)
+	}
+	names := map[string]bool{}
+	for _, element := range fitted.Elements {
+		names[element.Name] = true
+	}
+	if names["more"] || !names["visibility"] || !names["title"] {
+		t.Errorf("fit kept %v, want more dropped before visibility and title", names)
+	}
+}
@@ -0,0 +1,109 @@
+// Package gateway decodes REST API and HTTP API events into the one REST API
+// shape the handlers read
+package gateway
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Request is an API Gateway proxy request in the REST API shape, whichever
+// payload format it arrived in. Responses need no such care, HTTP APIs
+// accept the REST API response shape for either format
+type Request events.APIGatewayProxyRequest
+
+// httpAPIRequest is the part of an HTTP API payload format 2.0 event the
+// handlers use
+//
+// https://docs.aws.amazon.com/apigateway/latest/developerguide/http-api-develop-integrations-lambda.html
+type httpAPIRequest struct {
+	Version               string            `json:"version"`
+	RouteKey              string            `json:"routeKey"`
+	RawPath               string            `json:"rawPath"`
+	RawQueryString        string            `json:"rawQueryString"`
+	Cookies               []string          `json:"cookies"`
+	Headers               map[string]string `json:"headers"`
+	QueryStringParameters map[string]string `json:"queryStringParameters"`
+	PathParameters        map[string]string `json:"pathParameters"`
+	StageVariables        map[string]string `json:"stageVariables"`
+	Body                  string            `json:"body"`
+	IsBase64Encoded       bool              `json:"isBase64Encoded"`
+	RequestContext        struct {
+		AccountID string `json:"accountId"`
+		APIID     string `json:"apiId"`
+		RequestID string `json:"requestId"`
+		Stage     string `json:"stage"`
+		HTTP      struct {
+			Method    string `json:"method"`
+			Path      string `json:"path"`
+			SourceIP  string `json:"sourceIp"`
+			UserAgent string `json:"userAgent"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// UnmarshalJSON decodes a REST API event as is and converts an HTTP API
+// event with `version` 2.0
+func (r *Request) UnmarshalJSON(data []byte) error {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return err
+	}
+	if version.Version != "2.0" {
+		return json.Unmarshal(data, (*events.APIGatewayProxyRequest)(r))
+	}
+	var v2 httpAPIRequest
+	if err := json.Unmarshal(data, &v2); err != nil {
+		return err
+	}
+	*r = fromHTTPAPI(v2)
+	return nil
+}
+
+// fromHTTPAPI converts an HTTP API event to the REST API shape. Its cookies
+// go back into a Cookie header, and missing query parameters are parsed from
+// the raw query string
+func fromHTTPAPI(v2 httpAPIRequest) Request {
+	headers := map[string]string{}
+	for name, value := range v2.Headers {
+		headers[name] = value
+	}
+	if len(v2.Cookies) > 0 {
+		headers["cookie"] = strings.Join(v2.Cookies, "; ")
+	}
+	r := Request{
+		Resource:              v2.RouteKey,
+		Path:                  v2.RawPath,
+		HTTPMethod:            v2.RequestContext.HTTP.Method,
+		Headers:               headers,
+		QueryStringParameters: v2.QueryStringParameters,
+		PathParameters:        v2.PathParameters,
+		StageVariables:        v2.StageVariables,
+		Body:                  v2.Body,
+		IsBase64Encoded:       v2.IsBase64Encoded,
+	}
+	if r.Path == "" {
+		r.Path = v2.RequestContext.HTTP.Path
+	}
+	if r.QueryStringParameters == nil && v2.RawQueryString != "" {
+		if query, err := url.ParseQuery(v2.RawQueryString); err == nil {
+			r.QueryStringParameters = map[string]string{}
+			for name, values := range query {
+				r.QueryStringParameters[name] = values[len(values)-1]
+			}
+		}
+	}
+	r.RequestContext.AccountID = v2.RequestContext.AccountID
+	r.RequestContext.APIID = v2.RequestContext.APIID
+	r.RequestContext.RequestID = v2.RequestContext.RequestID
+	r.RequestContext.Stage = v2.RequestContext.Stage
+	r.RequestContext.HTTPMethod = v2.RequestContext.HTTP.Method
+	r.RequestContext.Identity.SourceIP = v2.RequestContext.HTTP.SourceIP
+	r.RequestContext.Identity.UserAgent = v2.RequestContext.HTTP.UserAgent
+	return r
+}
@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// httpAPIEvent is a sample HTTP API payload format 2.0 event for a slash
+// command
+const httpAPIEvent = `{
+	"version": "2.0",
+	"routeKey": "POST /command",
+	"rawPath": "/prod/command",
+	"rawQueryString": "team=T1&page=1&page=2",
+	"cookies": ["a=1", "b=2"],
+	"headers": {"content-type": "application/x-www-form-urlencoded", "x-slack-signature": "v0=abc"},
+	"body": "Y29tbWFuZD0lMkZ3aW5z",
+	"isBase64Encoded": true,
+	"requestContext": {
+		"accountId": "123456789012",
+		"apiId": "api-id",
+		"requestId": "id",
+		"stage": "prod",
+		"http": {"method": "POST", "path": "/prod/command", "sourceIp": "192.0.2.1", "userAgent": "Slackbot 1.0"}
+	}
+}`
+
+// restAPIEvent is a sample REST API proxy event
+const restAPIEvent = `{
+	"resource": "/command",
+	"path": "/command",
+	"httpMethod": "POST",
+	"headers": {"Content-Type": "application/x-www-form-urlencoded"},
+	"queryStringParameters": {"team": "T1"},
+	"body": "command=%2Fwins",
+	"requestContext": {"requestId": "id", "httpMethod": "POST", "identity": {"sourceIp": "192.0.2.1"}}
+}`
+
+func TestUnmarshalHTTPAPI(t *testing.T) {
+	var r Request
+	if err := json.Unmarshal([]byte(httpAPIEvent), &r); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	checks := []struct {
+		name, got, want string
+	}{
+		{"HTTPMethod", r.HTTPMethod, "POST"},
+		{"Path", r.Path, "/prod/command"},
+		{"Resource", r.Resource, "POST /command"},
+		{"team", r.QueryStringParameters["team"], "T1"},
+		{"page", r.QueryStringParameters["page"], "2"},
+		{"cookie", r.Headers["cookie"], "a=1; b=2"},
+		{"signature", r.Headers["x-slack-signature"], "v0=abc"},
+		{"Body", r.Body, "Y29tbWFuZD0lMkZ3aW5z"},
+		{"RequestID", r.RequestContext.RequestID, "id"},
+		{"RequestContext.HTTPMethod", r.RequestContext.HTTPMethod, "POST"},
+		{"SourceIP", r.RequestContext.Identity.SourceIP, "192.0.2.1"},
+		{"UserAgent", r.RequestContext.Identity.UserAgent, "Slackbot 1.0"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if !r.IsBase64Encoded {
+		t.Error("IsBase64Encoded = false, want true")
+	}
+}
+
+func TestUnmarshalRESTAPI(t *testing.T) {
+	var r Request
+	if err := json.Unmarshal([]byte(restAPIEvent), &r); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if r.HTTPMethod != "POST" || r.Path != "/command" || r.QueryStringParameters["team"] != "T1" || r.Body != "command=%2Fwins" || r.RequestContext.Identity.SourceIP != "192.0.2.1" {
+		t.Errorf("Request = %+v, want the REST API event as is", r)
+	}
+}
+
+func TestFromHTTPAPIQueryParameters(t *testing.T) {
+	v2 := httpAPIRequest{RawQueryString: "limit=5", QueryStringParameters: map[string]string{"limit": "10"}}
+	if got := fromHTTPAPI(v2).QueryStringParameters["limit"]; got != "10" {
+		t.Errorf("limit = %q, want the parsed parameters kept over the raw query", got)
+	}
+	v2 = httpAPIRequest{}
+	v2.RequestContext.HTTP.Path = "/wins"
+	if r := fromHTTPAPI(v2); r.Path != "/wins" || r.QueryStringParameters != nil {
+		t.Errorf("Path %q, query %v, want the context path and no query", r.Path, r.QueryStringParameters)
+	}
+}
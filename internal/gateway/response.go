@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/anzellai/kanowins/internal/apperror"
+)
+
+// Header returns the named request header, matched case-insensitively since
+// API Gateway passes headers through with the client's casing
+func (r Request) Header(name string) string {
+	for key, value := range r.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// DecodeBody replaces a base64 encoded body with its decoded form, API
+// Gateway encodes bodies it treats as binary
+func (r *Request) DecodeBody() error {
+	if !r.IsBase64Encoded {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = string(decoded)
+	r.IsBase64Encoded = false
+	return nil
+}
+
+// JSON builds a JSON proxy response with the given status and body
+func JSON(statusCode int, body string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode:      statusCode,
+		IsBase64Encoded: false,
+		Body:            body,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+// Error responds with err's status and user facing message as JSON, the
+// internal detail is left to the caller's log line
+func Error(err error) events.APIGatewayProxyResponse {
+	status, message := apperror.Public(err)
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return JSON(status, string(body))
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/anzellai/kanowins/internal/apperror"
+)
+
+func TestDecodeBody(t *testing.T) {
+	body := "text=Closed+%23123&user_id=U1"
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+		wantErr bool
+	}{
+		{"plain", Request{Body: body}, body, false},
+		{"base64", Request{Body: base64.StdEncoding.EncodeToString([]byte(body)), IsBase64Encoded: true}, body, false},
+		{"invalid base64", Request{Body: "not base64!", IsBase64Encoded: true}, "not base64!", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.DecodeBody()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeBody error = %v, want error: %t", err, tt.wantErr)
+			}
+			if tt.request.Body != tt.want {
+				t.Errorf("Body = %q, want %q", tt.request.Body, tt.want)
+			}
+			if !tt.wantErr && tt.request.IsBase64Encoded {
+				t.Error("IsBase64Encoded still set after decoding")
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	r := Request{Headers: map[string]string{"x-slack-signature": "v0=abc"}}
+	if got := r.Header("X-Slack-Signature"); got != "v0=abc" {
+		t.Errorf("Header = %q, want v0=abc", got)
+	}
+	if got := r.Header("X-Missing"); got != "" {
+		t.Errorf("Header(X-Missing) = %q, want empty", got)
+	}
+}
+
+func TestError(t *testing.T) {
+	resp := Error(apperror.Wrap(apperror.ErrStorage, errors.New("dynamodb: ValidationException at kanowins.go:120")))
+	if resp.StatusCode != 500 {
+		t.Errorf("StatusCode = %d, want 500", resp.StatusCode)
+	}
+	if want := `{"error":"` + apperror.ErrStorage.Message + `"}`; resp.Body != want {
+		t.Errorf("Body = %s, want %s", resp.Body, want)
+	}
+}
@@ -0,0 +1,91 @@
+package kanowins
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// DefaultScanCacheSeconds is how long a warm Lambda reuses a Get scan when
+// SCAN_CACHE_SECONDS is unset, off since a write from another Lambda only
+// shows once a cached scan expires
+const DefaultScanCacheSeconds = 0
+
+// scanCache holds the last Get scan of a table, cleared by writes through a
+// Client in this container
+var scanCache struct {
+	sync.RWMutex
+	table string
+	wins  []Win
+	at    time.Time
+}
+
+// ScanCacheTTL returns how long a Get scan is reused from SCAN_CACHE_SECONDS,
+// 0 turns the cache off, falling back to DefaultScanCacheSeconds when it is
+// unset or invalid. CONSISTENT_READS turns it off too
+func ScanCacheTTL() time.Duration {
+	if ConsistentReads() {
+		return 0
+	}
+	value := os.Getenv("SCAN_CACHE_SECONDS")
+	if value == "" {
+		return DefaultScanCacheSeconds * time.Second
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		logging.Printf("kanowins.ScanCacheTTL - invalid SCAN_CACHE_SECONDS %q, using %d", value, DefaultScanCacheSeconds)
+		return DefaultScanCacheSeconds * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cachedScan returns a deep copy of table's cached scan while it is younger
+// than ttl
+func cachedScan(table string, ttl time.Duration, now time.Time) ([]Win, bool) {
+	scanCache.RLock()
+	defer scanCache.RUnlock()
+	if ttl <= 0 || scanCache.wins == nil || scanCache.table != table || now.Sub(scanCache.at) >= ttl {
+		return nil, false
+	}
+	return copyWins(scanCache.wins), true
+}
+
+// cacheScan keeps a copy of table's scan taken at now
+func cacheScan(table string, wins []Win, now time.Time) {
+	scanCache.Lock()
+	defer scanCache.Unlock()
+	scanCache.table = table
+	scanCache.wins = copyWins(wins)
+	scanCache.at = now
+}
+
+// copyWins copies wins along with their Reactions and History, which a
+// plain slice copy would share
+func copyWins(wins []Win) []Win {
+	copied := make([]Win, len(wins))
+	for i, win := range wins {
+		if win.Reactions != nil {
+			reactions := make(map[string]int, len(win.Reactions))
+			for name, count := range win.Reactions {
+				reactions[name] = count
+			}
+			win.Reactions = reactions
+		}
+		if win.History != nil {
+			win.History = append([]Change{}, win.History...)
+		}
+		copied[i] = win
+	}
+	return copied
+}
+
+// invalidateScan drops the cached scan, deferred by every Client method
+// writing WINS so the next Get sees the write
+func invalidateScan() {
+	scanCache.Lock()
+	defer scanCache.Unlock()
+	scanCache.wins = nil
+}
@@ -0,0 +1,148 @@
+package kanowins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCachedScan(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		table string
+		ttl   time.Duration
+		at    time.Time
+		hit   bool
+	}{
+		{"hit", "wins", time.Minute, now.Add(30 * time.Second), true},
+		{"expired", "wins", time.Minute, now.Add(time.Minute), false},
+		{"other table", "other", time.Minute, now, false},
+		{"disabled", "wins", 0, now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheScan("wins", []Win{{WinID: "1"}}, now)
+			defer invalidateScan()
+			wins, ok := cachedScan(tt.table, tt.ttl, tt.at)
+			if ok != tt.hit {
+				t.Fatalf("cachedScan hit = %t, want %t", ok, tt.hit)
+			}
+			if ok && (len(wins) != 1 || wins[0].WinID != "1") {
+				t.Errorf("cachedScan = %+v, want WIN 1", wins)
+			}
+		})
+	}
+}
+
+func TestCachedScanInvalidated(t *testing.T) {
+	now := time.Now()
+	cacheScan("wins", []Win{{WinID: "1"}}, now)
+	invalidateScan()
+	if _, ok := cachedScan("wins", time.Minute, now); ok {
+		t.Error("cachedScan hit after invalidateScan")
+	}
+}
+
+func TestCachedScanCopies(t *testing.T) {
+	now := time.Now()
+	cacheScan("wins", []Win{{
+		WinID:     "1",
+		Reactions: map[string]int{"tada": 1},
+		History:   []Change{{Field: "title", Old: "a", New: "b"}},
+	}}, now)
+	defer invalidateScan()
+	wins, _ := cachedScan("wins", time.Minute, now)
+	wins[0].Title = "changed"
+	wins[0].Reactions["tada"] = 5
+	wins[0].History[0].New = "changed"
+	again, ok := cachedScan("wins", time.Minute, now)
+	if !ok {
+		t.Fatal("cachedScan missed")
+	}
+	if again[0].Title != "" || again[0].Reactions["tada"] != 1 || again[0].History[0].New != "b" {
+		t.Errorf("cache changed by a caller: %+v", again[0])
+	}
+}
+
+func TestGetCache(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "60")
+	defer invalidateScan()
+	invalidateScan()
+	scans := 0
+	db := &fakeDB{
+		scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			scans++
+			return &dynamodb.ScanOutput{Items: items(t, Win{WinID: "1"})}, nil
+		},
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	c := &Client{db: db, table: "wins"}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(); err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+	}
+	if scans != 1 {
+		t.Errorf("scans after two Gets = %d, want 1", scans)
+	}
+	if err := c.AddKudos("T1", "1"); err != nil {
+		t.Fatalf("AddKudos error: %v", err)
+	}
+	if _, err := c.Get(); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if scans != 2 {
+		t.Errorf("scans after a write = %d, want 2", scans)
+	}
+}
+
+func TestScanCacheTTL(t *testing.T) {
+	tests := []struct {
+		seconds    string
+		consistent string
+		want       time.Duration
+	}{
+		{"", "", 0},
+		{"60", "", time.Minute},
+		{"-1", "", 0},
+		{"60", "true", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("SCAN_CACHE_SECONDS", tt.seconds)
+		t.Setenv("CONSISTENT_READS", tt.consistent)
+		if got := ScanCacheTTL(); got != tt.want {
+			t.Errorf("ScanCacheTTL with SCAN_CACHE_SECONDS %q, CONSISTENT_READS %q = %v, want %v", tt.seconds, tt.consistent, got, tt.want)
+		}
+	}
+}
+
+func TestGetConsistentReadsScans(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "60")
+	t.Setenv("CONSISTENT_READS", "true")
+	defer invalidateScan()
+	cacheScan("wins", []Win{{WinID: "stale"}}, time.Now())
+	scans := 0
+	db := &fakeDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			scans++
+			return &dynamodb.ScanOutput{Items: items(t, Win{WinID: "1"})}, nil
+		},
+	}
+	c := &Client{db: db, table: "wins"}
+	for i := 0; i < 2; i++ {
+		wins, err := c.Get()
+		if err != nil {
+			t.Fatalf("Get error: %v", err)
+		}
+		if len(wins) != 1 || wins[0].WinID != "1" {
+			t.Errorf("Get = %+v, want the live scan", wins)
+		}
+	}
+	if scans != 2 {
+		t.Errorf("scans after two consistent Gets = %d, want 2", scans)
+	}
+}
@@ -0,0 +1,149 @@
+package kanowins
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// Config is one workspace's settings from the CONFIG_TABLE_NAME table, keyed
+// by team_id, any setting left out of the row falls back to its environment
+// variable
+type Config struct {
+	TeamID            string          `json:"team_id"`
+	DialogTitle       string          `json:"dialog_title"`
+	DialogSubmitLabel string          `json:"dialog_submit_label"`
+	TTLDays           int             `json:"ttl_days"`
+	Categories        []string        `json:"categories"`
+	Features          map[string]bool `json:"features"`
+	DisabledCommands  []string        `json:"disabled_commands"`
+}
+
+// Disabled reports whether the `/wins` subcommand, such as `export`, is
+// turned off for the workspace, `help` never is so the team can always see
+// what's left
+func (c Config) Disabled(command string) bool {
+	if command == "help" {
+		return false
+	}
+	for _, disabled := range c.DisabledCommands {
+		if strings.EqualFold(disabled, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisabledCommands returns the comma separated subcommands DISABLED_COMMANDS
+// turns off for every workspace without its own list
+func DisabledCommands() []string {
+	commands := []string{}
+	for _, command := range strings.Split(os.Getenv("DISABLED_COMMANDS"), ",") {
+		if command = strings.TrimSpace(command); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// Enabled reports whether the named feature flag is on for the workspace, or
+// the upper cased env var when unset
+func (c Config) Enabled(name string) bool {
+	if enabled, ok := c.Features[name]; ok {
+		return enabled
+	}
+	switch strings.ToLower(os.Getenv(strings.ToUpper(name))) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+var (
+	configMu sync.Mutex
+	configs  = map[string]Config{}
+	configDB dynamoAPI
+)
+
+// defaultConfig returns the configuration from environment variables, the
+// dialog labels are left empty so dialog falls back to SLACK_TEAM_CONFIG and
+// then DIALOG_TITLE and DIALOG_SUBMIT_LABEL
+func defaultConfig(teamID string) Config {
+	return Config{
+		TeamID:           teamID,
+		TTLDays:          TTLDays(),
+		Categories:       Categories(),
+		DisabledCommands: DisabledCommands(),
+	}
+}
+
+// LoadConfig returns teamID's configuration from CONFIG_TABLE_NAME, cached
+// per container. The environment defaults are returned without a row, or on
+// error
+func LoadConfig(teamID string) (Config, error) {
+	table := os.Getenv("CONFIG_TABLE_NAME")
+	if table == "" || teamID == "" {
+		return defaultConfig(teamID), nil
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	if config, ok := configs[teamID]; ok {
+		return config, nil
+	}
+	if configDB == nil {
+		sess, err := session.NewSession(awsConfig())
+		if err != nil {
+			return defaultConfig(teamID), err
+		}
+		configDB = dynamodb.New(sess)
+	}
+	result, err := configDB.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"team_id": {S: aws.String(teamID)},
+		},
+	})
+	if err != nil {
+		return defaultConfig(teamID), err
+	}
+	config := Config{}
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &config); err != nil {
+		return defaultConfig(teamID), err
+	}
+	defaults := defaultConfig(teamID)
+	config.TeamID = teamID
+	if config.TTLDays <= 0 {
+		config.TTLDays = defaults.TTLDays
+	}
+	if len(config.Categories) == 0 {
+		config.Categories = defaults.Categories
+	}
+	if config.DisabledCommands == nil {
+		config.DisabledCommands = defaults.DisabledCommands
+	}
+	configs[teamID] = config
+	return config, nil
+}
+
+// ConfigFor returns teamID's configuration like LoadConfig, logging a read
+// error and carrying on with the environment defaults
+func ConfigFor(teamID string) Config {
+	config, err := LoadConfig(teamID)
+	if err != nil {
+		logging.Printf("kanowins.ConfigFor - LoadConfig %s error: %v", teamID, err)
+	}
+	return config
+}
+
+// WhoFreeText reports whether teamID's WIN form asks who a WIN is for as
+// free text, for WINS for people outside Slack
+func WhoFreeText(teamID string) bool {
+	return ConfigFor(teamID).Enabled("who_free_text")
+}
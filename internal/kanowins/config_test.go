@@ -0,0 +1,120 @@
+package kanowins
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// fakeConfigTable swaps configDB for one serving rows by team, counting the
+// reads, and clears the cache around the test
+func fakeConfigTable(t *testing.T, rows map[string]Config) *int {
+	t.Helper()
+	reads := new(int)
+	configs = map[string]Config{}
+	configDB = &fakeDB{
+		getItem: func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			*reads++
+			teamID := aws.StringValue(in.Key["team_id"].S)
+			if teamID == "TERR" {
+				return nil, errors.New("throttled")
+			}
+			row, ok := rows[teamID]
+			if !ok {
+				return &dynamodb.GetItemOutput{}, nil
+			}
+			item, err := dynamodbattribute.MarshalMap(row)
+			if err != nil {
+				t.Fatalf("MarshalMap error: %v", err)
+			}
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		},
+	}
+	t.Cleanup(func() {
+		configs = map[string]Config{}
+		configDB = nil
+	})
+	t.Setenv("CONFIG_TABLE_NAME", "config")
+	return reads
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("WIN_TTL_DAYS", "30")
+	t.Setenv("WIN_CATEGORIES", "Shipping,Customer")
+	t.Setenv("DISABLED_COMMANDS", "export")
+	reads := fakeConfigTable(t, map[string]Config{
+		"T1": {DialogTitle: "Log a WIN", TTLDays: 90, Features: map[string]bool{"threaded_summary": true}},
+	})
+	tests := []struct {
+		name           string
+		teamID         string
+		wantTitle      string
+		wantTTL        int
+		wantCategories string
+		wantDisabled   bool
+		wantErr        bool
+	}{
+		{"row", "T1", "Log a WIN", 90, "Shipping,Customer", true, false},
+		{"no row", "T2", "", 30, "Shipping,Customer", true, false},
+		{"read error", "TERR", "", 30, "Shipping,Customer", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadConfig(tt.teamID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig error = %v, want error: %t", err, tt.wantErr)
+			}
+			if config.DialogTitle != tt.wantTitle || config.TTLDays != tt.wantTTL || strings.Join(config.Categories, ",") != tt.wantCategories || config.Disabled("export") != tt.wantDisabled {
+				t.Errorf("LoadConfig(%s) = %+v", tt.teamID, config)
+			}
+		})
+	}
+	if config, _ := LoadConfig("T1"); !config.Enabled("threaded_summary") {
+		t.Error("threaded_summary isn't enabled from the row")
+	}
+	if *reads != 3 {
+		t.Errorf("%d config reads, want a cache hit for T1 the second time", *reads)
+	}
+	if _, err := LoadConfig("TERR"); err == nil || *reads != 4 {
+		t.Errorf("read error cached: error %v after %d reads, want it read again", err, *reads)
+	}
+}
+
+func TestLoadConfigWithoutTable(t *testing.T) {
+	reads := fakeConfigTable(t, nil)
+	t.Setenv("CONFIG_TABLE_NAME", "")
+	t.Setenv("WIN_TTL_DAYS", "14")
+	config, err := LoadConfig("T1")
+	if err != nil || config.TTLDays != 14 || *reads != 0 {
+		t.Errorf("LoadConfig = %+v, %v after %d reads, want the environment defaults without reading", config, err, *reads)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	config := Config{DisabledCommands: []string{"export", "Search", "help"}}
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"export", true},
+		{"search", true},
+		{"summary", false},
+		{"help", false},
+	}
+	for _, tt := range tests {
+		if got := config.Disabled(tt.command); got != tt.want {
+			t.Errorf("Disabled(%q) = %t, want %t", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestDisabledCommands(t *testing.T) {
+	t.Setenv("DISABLED_COMMANDS", " export,, search ")
+	if got := strings.Join(DisabledCommands(), ","); got != "export,search" {
+		t.Errorf("DisabledCommands = %q, want export,search", got)
+	}
+}
@@ -0,0 +1,26 @@
+package kanowins
+
+import (
+	"bytes"
+	"encoding/csv"
+	"time"
+)
+
+// CSV renders WINs as CSV, fields holding commas, quotes or newlines are
+// quoted by encoding/csv so multi-line descriptions stay valid
+func CSV(wins []Win) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Who", "Title", "Description", "UserName", "CreatedAt"})
+	for _, win := range wins {
+		w.Write([]string{
+			win.Who,
+			win.Title,
+			win.Description,
+			win.Submitter(),
+			win.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
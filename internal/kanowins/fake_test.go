@@ -0,0 +1,63 @@
+package kanowins
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// fakeDB is a dynamoAPI answering the calls a test sets, any other call
+// panics on the nil embedded interface
+type fakeDB struct {
+	dynamoAPI
+	scan       func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	query      func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	getItem    func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	putItem    func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	updateItem func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	deleteItem func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	batchWrite func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+func (f *fakeDB) Scan(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return f.scan(in)
+}
+
+func (f *fakeDB) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	return f.query(in)
+}
+
+func (f *fakeDB) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return f.getItem(in)
+}
+
+func (f *fakeDB) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return f.putItem(in)
+}
+
+func (f *fakeDB) UpdateItem(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	return f.updateItem(in)
+}
+
+func (f *fakeDB) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	return f.deleteItem(in)
+}
+
+func (f *fakeDB) BatchWriteItem(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	return f.batchWrite(in)
+}
+
+// items marshals wins into DynamoDB items
+func items(t *testing.T, wins ...Win) []map[string]*dynamodb.AttributeValue {
+	t.Helper()
+	out := []map[string]*dynamodb.AttributeValue{}
+	for _, win := range wins {
+		item, err := dynamodbattribute.MarshalMap(win)
+		if err != nil {
+			t.Fatalf("MarshalMap(%+v) error: %v", win, err)
+		}
+		out = append(out, item)
+	}
+	return out
+}
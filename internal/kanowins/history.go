@@ -0,0 +1,83 @@
+package kanowins
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxHistory caps how many changes a WIN keeps, the oldest are dropped so
+// a much edited WIN doesn't grow towards DynamoDB's item size limit
+const MaxHistory = 10
+
+// Change is one field of a WIN amended by an edit, reassign or recategorize
+type Change struct {
+	Field string    `json:"field"`
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+	At    time.Time `json:"at"`
+}
+
+// field returns the value of the WIN attribute name as History records it
+func (w Win) field(name string) string {
+	switch name {
+	case "who":
+		return w.Who
+	case "who_user_id":
+		return w.WhoUserID
+	case "title":
+		return w.Title
+	case "description":
+		return w.Description
+	case "category":
+		return w.Category
+	case "anonymous":
+		return strconv.FormatBool(w.Anonymous)
+	case "url":
+		return w.URL
+	case "visibility":
+		return w.Scope()
+	case "impact":
+		return fmt.Sprint(w.Impact)
+	case "impact_unit":
+		return w.ImpactUnit
+	}
+	return ""
+}
+
+// changes lists the attributes that differ from existing at now, in name
+// order, leaving out updated_at
+func changes(existing Win, attributes map[string]interface{}, now time.Time) []Change {
+	changed := []Change{}
+	for name, value := range attributes {
+		if name == "updated_at" {
+			continue
+		}
+		old, updated := existing.field(name), fmt.Sprint(value)
+		if old != updated {
+			changed = append(changed, Change{Field: name, Old: old, New: updated, At: now})
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].Field < changed[j].Field
+	})
+	return changed
+}
+
+// updateTracked SETs attributes on the WIN with winID like update, also
+// appending what changed to its History, capped at MaxHistory. Two edits
+// racing may each keep only their own change, which is fine for an audit
+// trail of a single submitter's edits
+func (c *Client) updateTracked(winID, userID string, attributes map[string]interface{}) error {
+	existing, err := c.Find(winID)
+	if err != nil {
+		return err
+	}
+	history := append(existing.History, changes(existing, attributes, time.Now())...)
+	if len(history) > MaxHistory {
+		history = history[len(history)-MaxHistory:]
+	}
+	attributes["history"] = history
+	return c.update(winID, userID, attributes)
+}
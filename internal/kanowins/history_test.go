@@ -0,0 +1,83 @@
+package kanowins
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestChanges(t *testing.T) {
+	now := time.Now()
+	existing := Win{Who: "Alice", Title: "Shipped", Category: "Ops"}
+	got := changes(existing, map[string]interface{}{
+		"who":        "Alice",
+		"title":      "Shipped the release",
+		"category":   "",
+		"anonymous":  true,
+		"updated_at": now,
+	}, now)
+	want := []Change{
+		{Field: "anonymous", Old: "false", New: "true", At: now},
+		{Field: "category", Old: "Ops", New: "", At: now},
+		{Field: "title", Old: "Shipped", New: "Shipped the release", At: now},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("changes[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUpdateRecordsHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		history int
+		want    int
+	}{
+		{"first edit", 0, 1},
+		{"capped", MaxHistory, MaxHistory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := Win{WinID: "1", UserID: "U1", Who: "Alice", Title: "Shipped"}
+			for i := 0; i < tt.history; i++ {
+				existing.History = append(existing.History, Change{Field: "title", Old: strconv.Itoa(i), New: strconv.Itoa(i + 1)})
+			}
+			var update *dynamodb.UpdateItemInput
+			db := &fakeDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					return &dynamodb.GetItemOutput{Item: items(t, existing)[0]}, nil
+				},
+				updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					update = in
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			edited := existing
+			edited.Title = "Shipped the release"
+			if err := (&Client{db: db, table: "wins"}).Update(edited); err != nil {
+				t.Fatalf("Update error: %v", err)
+			}
+			var history []Change
+			if err := dynamodbattribute.Unmarshal(update.ExpressionAttributeValues[":history"], &history); err != nil {
+				t.Fatalf("unmarshalling :history: %v", err)
+			}
+			if len(history) != tt.want {
+				t.Fatalf("history has %d changes, want %d", len(history), tt.want)
+			}
+			last := history[len(history)-1]
+			if last.Field != "title" || last.Old != "Shipped" || last.New != "Shipped the release" || last.At.IsZero() {
+				t.Errorf("last change = %+v, want the title edit", last)
+			}
+			if tt.history > 0 && history[0].Old != "1" {
+				t.Errorf("oldest change kept = %+v, want the first dropped", history[0])
+			}
+		})
+	}
+}
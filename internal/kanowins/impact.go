@@ -0,0 +1,87 @@
+package kanowins
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidImpact is returned by ParseImpact for a value that isn't a number
+var ErrInvalidImpact = errors.New("invalid impact")
+
+// impactPattern matches an impact such as `12500`, `$12,500` or `40 hours`
+var impactPattern = regexp.MustCompile(`^([^\d\s.,+-]*)\s*([+-]?\d[\d,]*(?:\.\d+)?)\s*([^\d\s.,].*)?$`)
+
+// ParseImpact reads a WIN's impact as an amount and its unit, an empty value
+// is no impact
+func ParseImpact(value string) (float64, string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, "", nil
+	}
+	match := impactPattern.FindStringSubmatch(value)
+	if match == nil || (match[1] != "" && match[3] != "") {
+		return 0, "", ErrInvalidImpact
+	}
+	amount, err := strconv.ParseFloat(strings.Replace(match[2], ",", "", -1), 64)
+	if err != nil {
+		return 0, "", ErrInvalidImpact
+	}
+	return amount, strings.TrimSpace(match[1] + match[3]), nil
+}
+
+// FormatImpact renders an impact with thousands separators, a currency
+// symbol such as `$` before the amount and any other unit after it, as
+// "$12,500" or "40 hours"
+func FormatImpact(amount float64, unit string) string {
+	number := strconv.FormatFloat(amount, 'f', -1, 64)
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	whole, fraction := number, ""
+	if i := strings.Index(number, "."); i >= 0 {
+		whole, fraction = number[:i], number[i:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	number = sign + whole + fraction
+	if unit == "" {
+		return number
+	}
+	if r, size := utf8.DecodeRuneInString(unit); size == len(unit) && unicode.Is(unicode.Sc, r) {
+		return unit + number
+	}
+	return number + " " + unit
+}
+
+// TotalImpact sums the impact of wins per unit, as "$12,500 • 40 hours"
+// with the largest unit first, or an empty string when none has an impact
+func TotalImpact(wins []WinSummary) string {
+	totals := map[string]float64{}
+	for _, win := range wins {
+		if win.Impact != 0 {
+			totals[win.ImpactUnit] += win.Impact
+		}
+	}
+	units := []string{}
+	for unit := range totals {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		if totals[units[i]] != totals[units[j]] {
+			return totals[units[i]] > totals[units[j]]
+		}
+		return units[i] < units[j]
+	})
+	parts := []string{}
+	for _, unit := range units {
+		parts = append(parts, FormatImpact(totals[unit], unit))
+	}
+	return strings.Join(parts, " • ")
+}
@@ -0,0 +1,79 @@
+package kanowins
+
+import "testing"
+
+func TestParseImpact(t *testing.T) {
+	tests := []struct {
+		value   string
+		amount  float64
+		unit    string
+		wantErr bool
+	}{
+		{"", 0, "", false},
+		{"12500", 12500, "", false},
+		{"$12,500", 12500, "$", false},
+		{" 40 hours ", 40, "hours", false},
+		{"1.5 days", 1.5, "days", false},
+		{"-3 bugs", -3, "bugs", false},
+		{"lots", 0, "", true},
+		{"$40 hours", 0, "", true},
+		{"12.5.3", 0, "", true},
+	}
+	for _, tt := range tests {
+		amount, unit, err := ParseImpact(tt.value)
+		if amount != tt.amount || unit != tt.unit || (err != nil) != tt.wantErr {
+			t.Errorf("ParseImpact(%q) = %v, %q, %v, want %v, %q with error %t", tt.value, amount, unit, err, tt.amount, tt.unit, tt.wantErr)
+		}
+		if tt.wantErr && err != ErrInvalidImpact {
+			t.Errorf("ParseImpact(%q) error = %v, want ErrInvalidImpact", tt.value, err)
+		}
+	}
+}
+
+func TestFormatImpact(t *testing.T) {
+	tests := []struct {
+		amount float64
+		unit   string
+		want   string
+	}{
+		{12500, "$", "$12,500"},
+		{1234567.5, "€", "€1,234,567.5"},
+		{40, "hours", "40 hours"},
+		{-1500, "", "-1,500"},
+		{999, "", "999"},
+	}
+	for _, tt := range tests {
+		if got := FormatImpact(tt.amount, tt.unit); got != tt.want {
+			t.Errorf("FormatImpact(%v, %q) = %q, want %q", tt.amount, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestTotalImpact(t *testing.T) {
+	tests := []struct {
+		name string
+		wins []WinSummary
+		want string
+	}{
+		{"none", []WinSummary{{}, {}}, ""},
+		{"per unit, largest first", []WinSummary{
+			{Impact: 10000, ImpactUnit: "$"},
+			{Impact: 40, ImpactUnit: "hours"},
+			{},
+			{Impact: 2500, ImpactUnit: "$"},
+		}, "$12,500 • 40 hours"},
+	}
+	for _, tt := range tests {
+		if got := TotalImpact(tt.wins); got != tt.want {
+			t.Errorf("%s: TotalImpact = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	group := GroupSummaries([]WinSummary{
+		{Who: "Bob", Impact: 100, ImpactUnit: "$"},
+		{Who: "Bob", Impact: 50, ImpactUnit: "$"},
+		{Who: "Alice"},
+	}, "who")[0]
+	if group.Name != "Bob" || group.Impact != "$150" {
+		t.Errorf("largest group = %+v, want Bob with a total impact of $150", group)
+	}
+}
@@ -0,0 +1,1184 @@
+// Package kanowins holds the WIN type and DynamoDB storage shared by all
+// KanoWINS handlers
+package kanowins
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+const (
+	// maxAttempts bounds how many times a throttled DynamoDB call is tried
+	maxAttempts = 3
+
+	// retryBackoff is the base delay before retrying, doubled per attempt
+	retryBackoff = 100 * time.Millisecond
+
+	// maxBatchWrite is the BatchWriteItem limit of items per request
+	maxBatchWrite = 25
+)
+
+// UserIndex is the global secondary index of WINS by submitter
+const UserIndex = "user_id-index"
+
+// MaxTitleLength and MaxDescriptionLength cap WIN text in characters,
+// matching Slack's dialog text and textarea limits
+const (
+	MaxTitleLength       = 150
+	MaxDescriptionLength = 3000
+)
+
+// defaultDescription is stored for a WIN submitted without a description
+// when DEFAULT_DESCRIPTION is unset
+const defaultDescription = "Big WIN!"
+
+// DefaultTTLDays is how long a WIN is kept when WIN_TTL_DAYS is unset
+const DefaultTTLDays = 7
+
+// DefaultMaxBodyBytes is the largest request body accepted when
+// MAX_BODY_BYTES is unset, far above any Slack payload
+const DefaultMaxBodyBytes = 100 * 1024
+
+// requestPrefix marks the win_id of the request markers Claim stores in the
+// WINs table. Every other marker prefix starts with it, so Get skips them all
+const requestPrefix = "request#"
+
+// requestTTL is how long a claimed request is remembered, comfortably
+// outlasting Slack's retries
+const requestTTL = 10 * time.Minute
+
+// previewPrefix marks WINS held for a submitter's preview
+const previewPrefix = requestPrefix + "preview#"
+
+// previewTTL is how long a previewed WIN waits to be confirmed
+const previewTTL = time.Hour
+
+// ratePrefix marks the per-user submission counters Throttle keeps
+const ratePrefix = requestPrefix + "rate#"
+
+// dialogPrefix marks the opened dialogs TrackDialog records
+const dialogPrefix = requestPrefix + "dialog#"
+
+// dialogTTL is how long an opened dialog record is kept, long enough for a
+// weekly abandonment rate
+const dialogTTL = 8 * 24 * time.Hour
+
+// IsMarker reports whether winID belongs to a request marker, preview, rate
+// counter or dialog record stored in the WINs table rather than to a WIN
+func IsMarker(winID string) bool {
+	return strings.HasPrefix(winID, requestPrefix)
+}
+
+// Sources record how a WIN was logged, through the WIN dialog or modal or
+// quickly as `/wins "who" "title"`, WINs logged before sources were recorded
+// have none
+const (
+	SourceDialog = "dialog"
+	SourceQuick  = "quick"
+)
+
+// ErrDuplicate is returned by Claim for a request that was already handled
+var ErrDuplicate = errors.New("duplicate request")
+
+// ErrInvalidCursor is returned by GetPage for a cursor it didn't issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrNotFound is returned when no WIN exists with the given WinID, or it was
+// submitted by a different user
+var ErrNotFound = errors.New("WIN not found")
+
+// Win is a WIN as stored in the WINs table, keyed on win_id alone
+type Win struct {
+	WinID        string         `json:"win_id"`
+	UserID       string         `json:"user_id"`
+	UserName     string         `json:"user_name"`
+	TeamID       string         `json:"team_id"`
+	ChannelID    string         `json:"channel_id"`
+	ChannelName  string         `json:"channel_name"`
+	Permalink    string         `json:"permalink"`
+	URL          string         `json:"url"`
+	Who          string         `json:"who"`
+	WhoUserID    string         `json:"who_user_id,omitempty"`
+	Title        string         `json:"title"`
+	Description  string         `json:"description"`
+	Category     string         `json:"category"`
+	Impact       float64        `json:"impact,omitempty"`
+	ImpactUnit   string         `json:"impact_unit,omitempty"`
+	Source       string         `json:"source"`
+	Kudos        int            `json:"kudos"`
+	Reactions    map[string]int `json:"reactions,omitempty"`
+	Anonymous    bool           `json:"anonymous"`
+	Archived     bool           `json:"archived"`
+	Visibility   string         `json:"visibility,omitempty"`
+	VisibleAfter time.Time      `json:"visible_after"`
+	History      []Change       `json:"history,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	TTL          int64          `json:"ttl"`
+}
+
+// Expired reports whether the WIN's TTL has passed, DynamoDB only removes
+// expired items lazily so they may still be returned by a scan
+func (w Win) Expired(now time.Time) bool {
+	return w.TTL != 0 && w.TTL <= now.Unix()
+}
+
+// Hidden reports whether the WIN is scheduled to appear after now, it is
+// left out of summaries, counts and listings other than its submitter's
+// own until then
+func (w Win) Hidden(now time.Time) bool {
+	return w.VisibleAfter.After(now)
+}
+
+// Visibility scopes a submitter can give a WIN, from narrowest to widest
+const (
+	// VisibilityPrivate WINS only show in their submitter's `/wins mine`
+	VisibilityPrivate = "private"
+
+	// VisibilityTeam WINS show in team summaries, the default
+	VisibilityTeam = "team"
+
+	// VisibilityPublic WINS also make the public digest
+	VisibilityPublic = "public"
+)
+
+// visibilityRank orders the visibility scopes from narrowest to widest
+var visibilityRank = map[string]int{
+	VisibilityPrivate: 0,
+	VisibilityTeam:    1,
+	VisibilityPublic:  2,
+}
+
+// ParseVisibility returns the visibility scope named by value, VisibilityTeam
+// for an empty or unknown value
+func ParseVisibility(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if _, ok := visibilityRank[value]; ok {
+		return value
+	}
+	return VisibilityTeam
+}
+
+// Scope returns the WIN's visibility scope, VisibilityTeam for WINS stored
+// before scopes existed
+func (w Win) Scope() string {
+	return ParseVisibility(w.Visibility)
+}
+
+// VisibleTo reports whether the WIN may be shown to audience,
+// VisibilityTeam for team summaries or VisibilityPublic for the digest
+func (w Win) VisibleTo(audience string) bool {
+	return visibilityRank[w.Scope()] >= visibilityRank[audience]
+}
+
+// Submitter returns the name to show for whoever logged the WIN, an
+// anonymous WIN keeps its UserID for moderation but is never attributed
+func (w Win) Submitter() string {
+	if w.Anonymous {
+		return "Anonymous"
+	}
+	return w.UserName
+}
+
+// MustEnv returns the named environment variable, exiting when it is unset
+// so a misconfigured deployment fails at cold start rather than per request
+func MustEnv(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		logging.Printf("kanowins.MustEnv - missing required environment variable %s", name)
+		os.Exit(1)
+	}
+	return value
+}
+
+// TTLDays returns the WIN retention period in days from WIN_TTL_DAYS,
+// falling back to DefaultTTLDays when it is unset or invalid
+func TTLDays() int {
+	value := os.Getenv("WIN_TTL_DAYS")
+	if value == "" {
+		return DefaultTTLDays
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		logging.Printf("kanowins.TTLDays - invalid WIN_TTL_DAYS %q, using %d", value, DefaultTTLDays)
+		return DefaultTTLDays
+	}
+	return days
+}
+
+// MaxBodyBytes returns the largest request body the Slack handlers accept
+// from MAX_BODY_BYTES, falling back to DefaultMaxBodyBytes when it is unset
+// or invalid
+func MaxBodyBytes() int {
+	value := os.Getenv("MAX_BODY_BYTES")
+	if value == "" {
+		return DefaultMaxBodyBytes
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		logging.Printf("kanowins.MaxBodyBytes - invalid MAX_BODY_BYTES %q, using %d", value, DefaultMaxBodyBytes)
+		return DefaultMaxBodyBytes
+	}
+	return limit
+}
+
+// BodyTooLarge reports whether a proxy request body is over MaxBodyBytes,
+// allowing for base64
+func BodyTooLarge(body string, base64Encoded bool) bool {
+	limit := MaxBodyBytes()
+	if base64Encoded {
+		limit = (limit + 2) / 3 * 4
+	}
+	return len(body) > limit
+}
+
+// DefaultDescription returns the description stored for a WIN submitted
+// without one from DEFAULT_DESCRIPTION, which may be set empty to store no
+// description at all
+func DefaultDescription() string {
+	if description, ok := os.LookupEnv("DEFAULT_DESCRIPTION"); ok {
+		return description
+	}
+	return defaultDescription
+}
+
+// CleanWho trims who and collapses its inner whitespace, so " Alice  Smith "
+// is stored as "Alice Smith"
+func CleanWho(who string) string {
+	return strings.Join(strings.Fields(who), " ")
+}
+
+// WhoKey returns the key WINS are grouped by person on, case-folded when
+// WHO_CASE_INSENSITIVE is set
+func WhoKey(who string) string {
+	who = CleanWho(who)
+	switch strings.ToLower(os.Getenv("WHO_CASE_INSENSITIVE")) {
+	case "1", "true", "yes":
+		return strings.ToLower(who)
+	}
+	return who
+}
+
+// ConsistentReads reports whether CONSISTENT_READS asks for strongly
+// consistent Scans, which cost twice the read capacity
+func ConsistentReads() bool {
+	switch strings.ToLower(os.Getenv("CONSISTENT_READS")) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// Categories returns the WIN categories configured as a comma separated
+// WIN_CATEGORIES list, none when it is unset
+func Categories() []string {
+	categories := []string{}
+	for _, category := range strings.Split(os.Getenv("WIN_CATEGORIES"), ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// dynamoAPI is the subset of *dynamodb.DynamoDB used by Client, so tests can
+// substitute a fake table
+type dynamoAPI interface {
+	Scan(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItem(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	DescribeTable(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(*dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error)
+	WaitUntilTableExists(*dynamodb.DescribeTableInput) error
+	UpdateTimeToLive(*dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// Client is the WINs table handle
+type Client struct {
+	db    dynamoAPI
+	table string
+}
+
+// awsConfig returns the DynamoDB session config for REGION, pointed at
+// DYNAMODB_ENDPOINT when it is set, such as `http://localhost:8000` for
+// DynamoDB Local during development
+func awsConfig() *aws.Config {
+	config := &aws.Config{Region: aws.String(os.Getenv("REGION"))}
+	if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+	}
+	return config
+}
+
+// NewClient returns a Client for the table named by TABLE_NAME in REGION
+func NewClient() (*Client, error) {
+	sess, err := session.NewSession(awsConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		db:    dynamodb.New(sess),
+		table: os.Getenv("TABLE_NAME"),
+	}, nil
+}
+
+// Get returns latest weekly WINS, reusing this container's scan from within
+// the last ScanCacheTTL so warm read-heavy commands don't rescan the table
+func (c *Client) Get() ([]Win, error) {
+	ttl := ScanCacheTTL()
+	if wins, ok := cachedScan(c.table, ttl, time.Now()); ok {
+		return wins, nil
+	}
+	now := time.Now()
+	wins, err := c.scan()
+	if err == nil && ttl > 0 {
+		cacheScan(c.table, wins, now)
+	}
+	return wins, err
+}
+
+// scan reads every WIN in the table for Get, retrying each Scan page on its
+// own
+func (c *Client) scan() ([]Win, error) {
+	wins := []Win{}
+	params := &dynamodb.ScanInput{
+		TableName:        aws.String(c.table),
+		FilterExpression: aws.String("NOT begins_with(win_id, :marker)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":marker": {S: aws.String(requestPrefix)},
+		},
+		ConsistentRead: aws.Bool(ConsistentReads()),
+	}
+	for {
+		var page *dynamodb.ScanOutput
+		err := withRetry(func() (err error) {
+			page, err = c.db.Scan(params)
+			return
+		})
+		if err != nil {
+			return wins, err
+		}
+		items := []Win{}
+		if err = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return wins, err
+		}
+		wins = append(wins, items...)
+		if len(page.LastEvaluatedKey) == 0 {
+			return wins, nil
+		}
+		params.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// GetPage returns one Scan page of up to limit items from cursor, with the
+// next page's cursor or "" after the last
+func (c *Client) GetPage(cursor string, limit int64) ([]Win, string, error) {
+	wins := []Win{}
+	params := &dynamodb.ScanInput{
+		TableName:        aws.String(c.table),
+		FilterExpression: aws.String("NOT begins_with(win_id, :marker)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":marker": {S: aws.String(requestPrefix)},
+		},
+		ConsistentRead: aws.Bool(ConsistentReads()),
+		Limit:          aws.Int64(limit),
+	}
+	if cursor != "" {
+		key, err := decodeCursor(cursor)
+		if err != nil {
+			return wins, "", err
+		}
+		params.ExclusiveStartKey = key
+	}
+	var page *dynamodb.ScanOutput
+	err := withRetry(func() (err error) {
+		page, err = c.db.Scan(params)
+		return
+	})
+	if err != nil {
+		return wins, "", err
+	}
+	if err = dynamodbattribute.UnmarshalListOfMaps(page.Items, &wins); err != nil {
+		return wins, "", err
+	}
+	if len(page.LastEvaluatedKey) == 0 {
+		return wins, "", nil
+	}
+	next, err := encodeCursor(page.LastEvaluatedKey)
+	return wins, next, err
+}
+
+// encodeCursor turns a LastEvaluatedKey into a GetPage cursor
+func encodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	values := map[string]interface{}{}
+	if err := dynamodbattribute.UnmarshalMap(key, &values); err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor turns a GetPage cursor back into an ExclusiveStartKey
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	values := map[string]interface{}{}
+	if err = json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return dynamodbattribute.MarshalMap(values)
+}
+
+// GetByUser returns the WINS submitted by userID, newest first, from the
+// user_id-index rather than a scan. The index only supports eventually
+// consistent reads, so CONSISTENT_READS doesn't apply
+func (c *Client) GetByUser(userID string) ([]Win, error) {
+	wins := []Win{}
+	params := &dynamodb.QueryInput{
+		TableName:              aws.String(c.table),
+		IndexName:              aws.String(UserIndex),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":user_id": {S: aws.String(userID)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	for {
+		var page *dynamodb.QueryOutput
+		err := withRetry(func() (err error) {
+			page, err = c.db.Query(params)
+			return
+		})
+		if err != nil {
+			return wins, err
+		}
+		items := []Win{}
+		if err = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return wins, err
+		}
+		wins = append(wins, items...)
+		if len(page.LastEvaluatedKey) == 0 {
+			return wins, nil
+		}
+		params.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+// Find returns the WIN with winID, or ErrNotFound
+func (c *Client) Find(winID string) (Win, error) {
+	win := Win{}
+	result, err := c.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(winID)},
+		},
+	})
+	if err != nil {
+		return win, err
+	}
+	if len(result.Item) == 0 {
+		return win, ErrNotFound
+	}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &win)
+	return win, err
+}
+
+// Put upserts a WIN, keeping the CreatedAt and TTL of an existing WinID
+func (c *Client) Put(w Win) error {
+	_, err := c.Store(w)
+	return err
+}
+
+// Store upserts a WIN like Put, returning it as stored with its generated
+// WinID, timestamps and TTL
+func (c *Client) Store(w Win) (Win, error) {
+	defer invalidateScan()
+	w.Who = CleanWho(w.Who)
+	if w.WinID == "" {
+		id, err := newID()
+		if err != nil {
+			return w, err
+		}
+		w.WinID = id
+	} else {
+		existing, err := c.Find(w.WinID)
+		switch {
+		case err == nil:
+			w.CreatedAt = existing.CreatedAt
+		case err != ErrNotFound:
+			return w, err
+		}
+	}
+	now := time.Now()
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = now
+	}
+	w.UpdatedAt = now
+	w.TTL = w.CreatedAt.AddDate(0, 0, ConfigFor(w.TeamID).TTLDays).Unix()
+	item, err := dynamodbattribute.MarshalMap(w)
+	if err != nil {
+		return w, err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(c.table),
+	}
+	err = withRetry(func() error {
+		_, err := c.db.PutItem(input)
+		return err
+	})
+	return w, err
+}
+
+// BatchPut writes new WINs in chunks of 25, resubmitting any
+// UnprocessedItems
+func (c *Client) BatchPut(wins []Win) error {
+	requests := []*dynamodb.WriteRequest{}
+	now := time.Now()
+	for _, w := range wins {
+		w.Who = CleanWho(w.Who)
+		if w.WinID == "" {
+			id, err := newID()
+			if err != nil {
+				return err
+			}
+			w.WinID = id
+		}
+		if w.CreatedAt.IsZero() {
+			w.CreatedAt = now
+		}
+		w.UpdatedAt = now
+		w.TTL = w.CreatedAt.AddDate(0, 0, ConfigFor(w.TeamID).TTLDays).Unix()
+		item, err := dynamodbattribute.MarshalMap(w)
+		if err != nil {
+			return err
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: item},
+		})
+	}
+	for start := 0; start < len(requests); start += maxBatchWrite {
+		end := start + maxBatchWrite
+		if end > len(requests) {
+			end = len(requests)
+		}
+		if err := c.batchWrite(requests[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge deletes every WIN of teamID, archived or not, with BatchWriteItem
+// delete requests in chunks of 25, returning how many were removed. It scans
+// the table live rather than through the Get cache so no WIN is missed, and
+// leaves request markers to expire on their own
+func (c *Client) Purge(teamID string) (int, error) {
+	wins, err := c.scan()
+	if err != nil {
+		return 0, err
+	}
+	requests := []*dynamodb.WriteRequest{}
+	for _, w := range wins {
+		if w.TeamID != teamID {
+			continue
+		}
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					"win_id": {S: aws.String(w.WinID)},
+				},
+			},
+		})
+	}
+	for start := 0; start < len(requests); start += maxBatchWrite {
+		end := start + maxBatchWrite
+		if end > len(requests) {
+			end = len(requests)
+		}
+		if err := c.batchWrite(requests[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(requests), nil
+}
+
+// MissingTTL returns the wins without a TTL, such as WINS stored before TTLs
+// were set or imported without one, which DynamoDB never expires
+func MissingTTL(wins []Win) []Win {
+	missing := []Win{}
+	for _, w := range wins {
+		if w.TTL == 0 {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
+
+// BackfillTTL gives every WIN without a TTL its team's TTLDays from
+// CreatedAt, calling progress after every batchSize WINS. It returns how
+// many were set, a WIN renewed meanwhile is left alone
+func (c *Client) BackfillTTL(batchSize int, progress func(done, total int)) (int, error) {
+	defer invalidateScan()
+	wins, err := c.scan()
+	if err != nil {
+		return 0, err
+	}
+	missing := MissingTTL(wins)
+	set := 0
+	for i, w := range missing {
+		from := w.CreatedAt
+		if from.IsZero() {
+			from = time.Now()
+		}
+		input := &dynamodb.UpdateItemInput{
+			TableName: aws.String(c.table),
+			Key: map[string]*dynamodb.AttributeValue{
+				"win_id": {S: aws.String(w.WinID)},
+			},
+			ConditionExpression: aws.String("attribute_exists(win_id) AND (attribute_not_exists(#ttl) OR #ttl = :zero)"),
+			UpdateExpression:    aws.String("SET #ttl = :ttl"),
+			ExpressionAttributeNames: map[string]*string{
+				"#ttl": aws.String("ttl"),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":ttl":  {N: aws.String(strconv.FormatInt(from.AddDate(0, 0, ConfigFor(w.TeamID).TTLDays).Unix(), 10))},
+				":zero": {N: aws.String("0")},
+			},
+		}
+		err := withRetry(func() error {
+			_, err := c.db.UpdateItem(input)
+			return err
+		})
+		switch {
+		case notFound(err) == ErrNotFound:
+			// renewed or deleted since the scan
+		case err != nil:
+			return set, err
+		default:
+			set++
+		}
+		if done := i + 1; done%batchSize == 0 || done == len(missing) {
+			progress(done, len(missing))
+		}
+	}
+	return set, nil
+}
+
+// batchWrite sends one BatchWriteItem chunk, retrying its UnprocessedItems
+// until none are left or maxAttempts is reached
+func (c *Client) batchWrite(requests []*dynamodb.WriteRequest) error {
+	defer invalidateScan()
+	pending := map[string][]*dynamodb.WriteRequest{c.table: requests}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := retryBackoff << uint(attempt-1)
+			time.Sleep(backoff/2 + time.Duration(mathrand.Int63n(int64(backoff/2)+1)))
+		}
+		var result *dynamodb.BatchWriteItemOutput
+		err := withRetry(func() (err error) {
+			result, err = c.db.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: pending})
+			return
+		})
+		if err != nil {
+			return err
+		}
+		if len(result.UnprocessedItems[c.table]) == 0 {
+			return nil
+		}
+		pending = result.UnprocessedItems
+		logging.Printf("kanowins.batchWrite - attempt %d left %d items unprocessed", attempt+1, len(pending[c.table]))
+	}
+	return fmt.Errorf("%d items still unprocessed after %d attempts", len(pending[c.table]), maxAttempts)
+}
+
+// Update amends an existing WIN submitted by w.UserID, keeping its
+// CreatedAt and TTL and recording what changed in History
+func (c *Client) Update(w Win) error {
+	return c.updateTracked(w.WinID, w.UserID, map[string]interface{}{
+		"who":         CleanWho(w.Who),
+		"who_user_id": w.WhoUserID,
+		"title":       w.Title,
+		"description": w.Description,
+		"category":    w.Category,
+		"anonymous":   w.Anonymous,
+		"url":         w.URL,
+		"visibility":  w.Scope(),
+		"impact":      w.Impact,
+		"impact_unit": w.ImpactUnit,
+		"updated_at":  time.Now(),
+	})
+}
+
+// Reassign changes who the WIN with winID is for, only for its submitter
+// userID. The Slack user picked for the WIN is cleared
+func (c *Client) Reassign(winID, userID, who string) error {
+	return c.updateTracked(winID, userID, map[string]interface{}{
+		"who":         CleanWho(who),
+		"who_user_id": "",
+		"updated_at":  time.Now(),
+	})
+}
+
+// Recategorize changes the category of the WIN with winID, restricted to the
+// WIN's submitter userID, refreshing UpdatedAt and recording the change in
+// History
+func (c *Client) Recategorize(winID, userID, category string) error {
+	return c.updateTracked(winID, userID, map[string]interface{}{
+		"category":   category,
+		"updated_at": time.Now(),
+	})
+}
+
+// Renew pushes the TTL of the WIN with winID to days from now, restricted to
+// the WIN's submitter userID, refreshing UpdatedAt while keeping CreatedAt.
+// DynamoDB takes a while to delete expired items, so a WIN can be renewed
+// shortly after it expires as long as it is still in the table
+func (c *Client) Renew(winID, userID string, days int) error {
+	now := time.Now()
+	return c.update(winID, userID, map[string]interface{}{
+		"ttl":        now.AddDate(0, 0, days).Unix(),
+		"updated_at": now,
+	})
+}
+
+// update SETs the given attributes on the WIN with winID, restricted to WINs
+// submitted by userID when it is not empty
+func (c *Client) update(winID, userID string, attributes map[string]interface{}) error {
+	defer invalidateScan()
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+	sets := []string{}
+	for name, value := range attributes {
+		av, err := dynamodbattribute.Marshal(value)
+		if err != nil {
+			return err
+		}
+		names["#"+name] = aws.String(name)
+		values[":"+name] = av
+		sets = append(sets, fmt.Sprintf("#%s = :%s", name, name))
+	}
+	sort.Strings(sets)
+	condition := "attribute_exists(win_id)"
+	if userID != "" {
+		condition += " AND user_id = :owner"
+		values[":owner"] = &dynamodb.AttributeValue{S: aws.String(userID)}
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(winID)},
+		},
+		ConditionExpression:       aws.String(condition),
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	_, err := c.db.UpdateItem(input)
+	return notFound(err)
+}
+
+// visibleCondition restricts a kudos or reaction to a team summary WIN of
+// :team, never a request marker or an archived or private WIN
+const visibleCondition = "team_id = :team AND NOT begins_with(win_id, :marker) AND archived <> :true AND (attribute_not_exists(visibility) OR visibility <> :private)"
+
+// visibleValues returns the visibleCondition values for teamID, with values
+// merged in
+func visibleValues(teamID string, values map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	values[":team"] = &dynamodb.AttributeValue{S: aws.String(teamID)}
+	values[":marker"] = &dynamodb.AttributeValue{S: aws.String(requestPrefix)}
+	values[":true"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	values[":private"] = &dynamodb.AttributeValue{S: aws.String(VisibilityPrivate)}
+	return values
+}
+
+// AddKudos atomically increments the kudos of teamID's WIN with winID using
+// an ADD update expression, so concurrent kudos are never lost. A WIN of
+// another team, archived or private is ErrNotFound
+func (c *Client) AddKudos(teamID, winID string) error {
+	defer invalidateScan()
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(winID)},
+		},
+		ConditionExpression: aws.String(visibleCondition),
+		UpdateExpression:    aws.String("ADD kudos :one"),
+		ExpressionAttributeValues: visibleValues(teamID, map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+		}),
+	}
+	err := withRetry(func() error {
+		_, err := c.db.UpdateItem(input)
+		return err
+	})
+	return notFound(err)
+}
+
+// reactionPattern matches an emoji shortcode such as `:tada:` or `:+1:`
+var reactionPattern = regexp.MustCompile(`^:([a-z0-9_+'-]+):$`)
+
+// ReactionName returns the name of the emoji shortcode, `tada` for `:tada:`,
+// and whether it is a valid shortcode at all
+func ReactionName(shortcode string) (string, bool) {
+	match := reactionPattern.FindStringSubmatch(strings.ToLower(shortcode))
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// AddReaction atomically counts one emoji reaction on teamID's WIN with
+// winID, held to the same visibleCondition as AddKudos. DynamoDB can't ADD
+// into a missing map, so the first reaction SETs it
+func (c *Client) AddReaction(teamID, winID, emoji string) error {
+	defer invalidateScan()
+	update := func(input *dynamodb.UpdateItemInput) error {
+		err := withRetry(func() error {
+			_, err := c.db.UpdateItem(input)
+			return err
+		})
+		return notFound(err)
+	}
+	add := func() error {
+		return update(&dynamodb.UpdateItemInput{
+			TableName: aws.String(c.table),
+			Key: map[string]*dynamodb.AttributeValue{
+				"win_id": {S: aws.String(winID)},
+			},
+			ConditionExpression: aws.String(visibleCondition + " AND attribute_exists(#reactions)"),
+			UpdateExpression:    aws.String("ADD #reactions.#emoji :one"),
+			ExpressionAttributeNames: map[string]*string{
+				"#reactions": aws.String("reactions"),
+				"#emoji":     aws.String(emoji),
+			},
+			ExpressionAttributeValues: visibleValues(teamID, map[string]*dynamodb.AttributeValue{
+				":one": {N: aws.String("1")},
+			}),
+		})
+	}
+	if err := add(); err != ErrNotFound {
+		return err
+	}
+	err := update(&dynamodb.UpdateItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(winID)},
+		},
+		ConditionExpression: aws.String(visibleCondition + " AND attribute_not_exists(#reactions)"),
+		UpdateExpression:    aws.String("SET #reactions = :first"),
+		ExpressionAttributeNames: map[string]*string{
+			"#reactions": aws.String("reactions"),
+		},
+		ExpressionAttributeValues: visibleValues(teamID, map[string]*dynamodb.AttributeValue{
+			":first": {M: map[string]*dynamodb.AttributeValue{
+				emoji: {N: aws.String("1")},
+			}},
+		}),
+	})
+	if err != ErrNotFound {
+		return err
+	}
+	return add()
+}
+
+// Archive soft-deletes the WIN with winID by setting its archived flag,
+// restricted to WINs submitted by userID, keeping it for the audit trail
+func (c *Client) Archive(winID, userID string) error {
+	return c.update(winID, userID, map[string]interface{}{
+		"archived":   true,
+		"updated_at": time.Now(),
+	})
+}
+
+// Delete removes the WIN with winID, restricted to WINs submitted by userID
+func (c *Client) Delete(winID, userID string) error {
+	defer invalidateScan()
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(winID)},
+		},
+		ConditionExpression: aws.String("user_id = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(userID)},
+		},
+	}
+	_, err := c.db.DeleteItem(input)
+	return notFound(err)
+}
+
+// Fingerprint derives a Claim key from a raw request body, for requests
+// without a natural unique ID
+func Fingerprint(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// Duplicate reports whether the request identified by key was already
+// handled, such as a Slack retry
+func Duplicate(key string) bool {
+	db, err := NewClient()
+	if err == nil {
+		err = db.Claim(key)
+	}
+	if err == ErrDuplicate {
+		return true
+	}
+	if err != nil {
+		logging.Printf("kanowins.Duplicate - Claim error: %v", err)
+	}
+	return false
+}
+
+// Claim records that the request identified by key is being handled,
+// returning ErrDuplicate when it already was
+func (c *Client) Claim(key string) error {
+	now := time.Now()
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(requestPrefix + key)},
+			"ttl":    {N: aws.String(strconv.FormatInt(now.Add(requestTTL).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(win_id) OR #ttl <= :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ttl": aws.String("ttl"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	}
+	err := withRetry(func() error {
+		_, err := c.db.PutItem(input)
+		return err
+	})
+	if notFound(err) == ErrNotFound {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// PutPreview holds one submitter's wins until they confirm them, returning
+// the ID TakePreview fetches them by. The item has no user_id, so it stays
+// out of the user_id-index
+func (c *Client) PutPreview(wins []Win) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	pending, err := dynamodbattribute.Marshal(wins)
+	if err != nil {
+		return "", err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"win_id":  {S: aws.String(previewPrefix + id)},
+			"pending": pending,
+			"ttl":     {N: aws.String(strconv.FormatInt(time.Now().Add(previewTTL).Unix(), 10))},
+		},
+	}
+	err = withRetry(func() error {
+		_, err := c.db.PutItem(input)
+		return err
+	})
+	return id, err
+}
+
+// TakePreview removes and returns userID's WINS held by PutPreview under
+// id, or ErrNotFound once they were taken, have expired or aren't theirs
+func (c *Client) TakePreview(id, userID string) ([]Win, error) {
+	wins := []Win{}
+	result, err := c.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(previewPrefix + id)},
+		},
+		ConditionExpression: aws.String("pending[0].user_id = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(userID)},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		return wins, notFound(err)
+	}
+	pending, ok := result.Attributes["pending"]
+	if !ok || len(pending.L) == 0 {
+		return wins, ErrNotFound
+	}
+	if ttl, ok := result.Attributes["ttl"]; ok && ttl.N != nil {
+		if expiry, err := strconv.ParseInt(*ttl.N, 10, 64); err == nil && expiry <= time.Now().Unix() {
+			return wins, ErrNotFound
+		}
+	}
+	err = dynamodbattribute.Unmarshal(pending, &wins)
+	return wins, err
+}
+
+// Throttle counts n more WINS from userID in the current window of the
+// given length, reporting whether they stay within limit. Each window has
+// its own counter item whose TTL outlasts the window, so counters reset on
+// window boundaries rather than sliding
+func (c *Client) Throttle(userID string, n, limit int, window time.Duration) (bool, error) {
+	now := time.Now()
+	start := now.Truncate(window)
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(fmt.Sprintf("%s%s#%d", ratePrefix, userID, start.Unix()))},
+		},
+		UpdateExpression: aws.String("ADD hits :n SET #ttl = :ttl"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ttl": aws.String("ttl"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":n":   {N: aws.String(strconv.Itoa(n))},
+			":ttl": {N: aws.String(strconv.FormatInt(start.Add(2*window).Unix(), 10))},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+	var result *dynamodb.UpdateItemOutput
+	err := withRetry(func() (err error) {
+		result, err = c.db.UpdateItem(input)
+		return
+	})
+	if err != nil {
+		return true, err
+	}
+	hits := 0
+	if value, ok := result.Attributes["hits"]; ok && value.N != nil {
+		hits, _ = strconv.Atoi(*value.N)
+	}
+	return hits <= limit, nil
+}
+
+// TrackDialog records that the WIN dialog opened by triggerID was shown to
+// teamID
+func (c *Client) TrackDialog(triggerID, teamID string) error {
+	now := time.Now()
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"win_id":    {S: aws.String(dialogPrefix + triggerID)},
+			"team_id":   {S: aws.String(teamID)},
+			"opened_at": {S: aws.String(now.Format(time.RFC3339))},
+			"completed": {BOOL: aws.Bool(false)},
+			"ttl":       {N: aws.String(strconv.FormatInt(now.Add(dialogTTL).Unix(), 10))},
+		},
+	}
+	return withRetry(func() error {
+		_, err := c.db.PutItem(input)
+		return err
+	})
+}
+
+// CompleteDialog marks the dialog TrackDialog recorded for triggerID as
+// submitted, failing with ErrNotFound for a dialog that wasn't tracked
+func (c *Client) CompleteDialog(triggerID string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(c.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"win_id": {S: aws.String(dialogPrefix + triggerID)},
+		},
+		ConditionExpression: aws.String("attribute_exists(win_id)"),
+		UpdateExpression:    aws.String("SET completed = :true, completed_at = :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":true": {BOOL: aws.Bool(true)},
+			":now":  {S: aws.String(time.Now().Format(time.RFC3339))},
+		},
+	}
+	err := withRetry(func() error {
+		_, err := c.db.UpdateItem(input)
+		return err
+	})
+	return notFound(err)
+}
+
+// IsMissingTable reports whether err is DynamoDB's ResourceNotFoundException,
+// as seen on a fresh deploy before the WINs table is provisioned
+func IsMissingTable(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException
+}
+
+// notFound maps a failed ownership/existence condition to ErrNotFound
+func notFound(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrNotFound
+	}
+	return err
+}
+
+// retryable reports whether a DynamoDB error is transient and worth retrying
+func retryable(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeInternalServerError,
+		"ThrottlingException",
+		"RequestLimitExceeded":
+		return true
+	}
+	return false
+}
+
+// withRetry calls fn up to maxAttempts times, backing off exponentially with
+// jitter between attempts for as long as it fails with a retryable error
+func withRetry(fn func() error) (err error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := retryBackoff << uint(attempt-1)
+			time.Sleep(backoff/2 + time.Duration(mathrand.Int63n(int64(backoff/2)+1)))
+		}
+		if err = fn(); !retryable(err) {
+			return
+		}
+		logging.Printf("kanowins.withRetry - attempt %d failed: %v", attempt+1, err)
+	}
+	return
+}
+
+// newID returns a random (version 4) UUID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,793 @@
+package kanowins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestGetPaginates(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+	pages := []*dynamodb.ScanOutput{
+		{
+			Items:            items(t, Win{WinID: "1"}, Win{WinID: "2"}),
+			LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"win_id": {S: aws.String("2")}},
+		},
+		{Items: items(t, Win{WinID: "3"})},
+	}
+	starts := []string{}
+	db := &fakeDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			start := ""
+			if key, ok := in.ExclusiveStartKey["win_id"]; ok {
+				start = aws.StringValue(key.S)
+			}
+			starts = append(starts, start)
+			page := pages[0]
+			pages = pages[1:]
+			return page, nil
+		},
+	}
+	wins, err := (&Client{db: db, table: "wins"}).Get()
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if len(wins) != 3 || wins[0].WinID != "1" || wins[2].WinID != "3" {
+		t.Errorf("Get = %+v, want WINs 1, 2 and 3", wins)
+	}
+	if len(starts) != 2 || starts[0] != "" || starts[1] != "2" {
+		t.Errorf("scanned from %q, want the start then after WIN 2", starts)
+	}
+}
+
+func TestPutRetries(t *testing.T) {
+	throttled := awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+	tests := []struct {
+		name     string
+		failures []error
+		wantErr  bool
+		wantPuts int
+	}{
+		{"fails twice then succeeds", []error{throttled, throttled}, false, 3},
+		{"fails every attempt", []error{throttled, throttled, throttled}, true, 3},
+		{"isn't retried on other errors", []error{awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil)}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			puts := 0
+			db := &fakeDB{
+				putItem: func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+					puts++
+					if puts <= len(tt.failures) {
+						return nil, tt.failures[puts-1]
+					}
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			}
+			err := (&Client{db: db, table: "wins"}).Put(Win{Who: "Alice", Title: "Shipped the release"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Put error = %v, want error: %t", err, tt.wantErr)
+			}
+			if puts != tt.wantPuts {
+				t.Errorf("PutItem called %d times, want %d", puts, tt.wantPuts)
+			}
+		})
+	}
+}
+
+func TestGetByUserQueries(t *testing.T) {
+	var query *dynamodb.QueryInput
+	db := &fakeDB{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			query = in
+			return &dynamodb.QueryOutput{Items: items(t, Win{WinID: "1", UserID: "U1"})}, nil
+		},
+		scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			t.Error("GetByUser scanned the table")
+			return &dynamodb.ScanOutput{}, nil
+		},
+	}
+	wins, err := (&Client{db: db, table: "wins"}).GetByUser("U1")
+	if err != nil {
+		t.Fatalf("GetByUser error: %v", err)
+	}
+	if len(wins) != 1 || wins[0].WinID != "1" {
+		t.Errorf("GetByUser = %+v, want WIN 1", wins)
+	}
+	if query == nil {
+		t.Fatal("GetByUser issued no Query")
+	}
+	if aws.StringValue(query.IndexName) != UserIndex || aws.StringValue(query.ExpressionAttributeValues[":user_id"].S) != "U1" {
+		t.Errorf("Query = %v, want user U1 on %s", query, UserIndex)
+	}
+}
+
+func TestGet(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+	tests := []struct {
+		name    string
+		output  *dynamodb.ScanOutput
+		err     error
+		wantIDs []string
+	}{
+		{"happy path", &dynamodb.ScanOutput{Items: items(t, Win{WinID: "1"}, Win{WinID: "2"})}, nil, []string{"1", "2"}},
+		{"empty table", &dynamodb.ScanOutput{}, nil, []string{}},
+		{"scan error", nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{
+				scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					return tt.output, tt.err
+				},
+			}
+			wins, err := (&Client{db: db, table: "wins"}).Get()
+			if err != tt.err {
+				t.Errorf("Get error = %v, want %v", err, tt.err)
+			}
+			ids := []string{}
+			for _, win := range wins {
+				ids = append(ids, win.WinID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("Get = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestPutKeepsCreatedAt(t *testing.T) {
+	stored := map[string]map[string]*dynamodb.AttributeValue{}
+	db := &fakeDB{
+		getItem: func(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: stored[aws.StringValue(in.Key["win_id"].S)]}, nil
+		},
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			stored[aws.StringValue(in.Item["win_id"].S)] = in.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+	c := &Client{db: db, table: "wins"}
+	first, err := c.Store(Win{WinID: "1", Who: "Alice", Title: "Shipped the release", CreatedAt: time.Now().AddDate(0, 0, -3)})
+	if err != nil {
+		t.Fatalf("first Store error: %v", err)
+	}
+	second, err := c.Store(Win{WinID: "1", Who: "Alice", Title: "Shipped the release, again"})
+	if err != nil {
+		t.Fatalf("second Store error: %v", err)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("CreatedAt = %s after the second put, want %s", second.CreatedAt, first.CreatedAt)
+	}
+	if second.TTL != first.TTL {
+		t.Errorf("TTL = %d after the second put, want %d", second.TTL, first.TTL)
+	}
+	if second.UpdatedAt.Before(first.UpdatedAt) {
+		t.Errorf("UpdatedAt = %s, want no earlier than %s", second.UpdatedAt, first.UpdatedAt)
+	}
+}
+
+func TestIsMissingTable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"missing table", awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil), true},
+		{"throttled", awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsMissingTable(tt.err); got != tt.want {
+				t.Errorf("IsMissingTable(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReassign(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		wantErr error
+	}{
+		{"submitter", "U1", nil},
+		{"someone else", "U2", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var update *dynamodb.UpdateItemInput
+			db := &fakeDB{
+				getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					return &dynamodb.GetItemOutput{Item: items(t, Win{WinID: "1", UserID: "U1", Who: "Alice"})[0]}, nil
+				},
+				updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					update = in
+					if aws.StringValue(in.ExpressionAttributeValues[":owner"].S) != "U1" {
+						return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+					}
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			err := (&Client{db: db, table: "wins"}).Reassign("1", tt.caller, "  Bob  ")
+			if err != tt.wantErr {
+				t.Fatalf("Reassign error = %v, want %v", err, tt.wantErr)
+			}
+			if got := aws.StringValue(update.ConditionExpression); got != "attribute_exists(win_id) AND user_id = :owner" {
+				t.Errorf("ConditionExpression = %q", got)
+			}
+			expression := aws.StringValue(update.UpdateExpression)
+			for _, set := range []string{"#who = :who", "#updated_at = :updated_at", "#history = :history"} {
+				if !strings.Contains(expression, set) {
+					t.Errorf("UpdateExpression %q doesn't SET %s", expression, set)
+				}
+			}
+			if got := aws.StringValue(update.ExpressionAttributeValues[":who"].S); got != "Bob" {
+				t.Errorf(":who = %q, want Bob", got)
+			}
+		})
+	}
+}
+
+func TestGetRetriesThrottledPage(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+	calls := 0
+	db := &fakeDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			calls++
+			switch {
+			case calls == 1:
+				return nil, awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+			case in.ExclusiveStartKey == nil:
+				return &dynamodb.ScanOutput{
+					Items:            items(t, Win{WinID: "1"}),
+					LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"win_id": {S: aws.String("1")}},
+				}, nil
+			default:
+				return &dynamodb.ScanOutput{Items: items(t, Win{WinID: "2"})}, nil
+			}
+		},
+	}
+	wins, err := (&Client{db: db, table: "wins"}).Get()
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if len(wins) != 2 {
+		t.Errorf("Get = %+v, want both pages", wins)
+	}
+	if calls != 3 {
+		t.Errorf("Scan called %d times, want 3", calls)
+	}
+}
+
+func TestConsistentReads(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "0")
+	for _, tt := range []struct {
+		value string
+		want  bool
+	}{{"true", true}, {"1", true}, {"", false}, {"false", false}} {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("CONSISTENT_READS", tt.value)
+			reads := []bool{}
+			db := &fakeDB{
+				scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					reads = append(reads, aws.BoolValue(in.ConsistentRead))
+					return &dynamodb.ScanOutput{}, nil
+				},
+			}
+			c := &Client{db: db, table: "wins"}
+			if _, err := c.Get(); err != nil {
+				t.Fatalf("Get error: %v", err)
+			}
+			if _, _, err := c.GetPage("", 10); err != nil {
+				t.Fatalf("GetPage error: %v", err)
+			}
+			for _, got := range reads {
+				if got != tt.want {
+					t.Errorf("ConsistentRead = %t, want %t", got, tt.want)
+				}
+			}
+			if len(reads) != 2 {
+				t.Errorf("scanned %d times, want 2", len(reads))
+			}
+		})
+	}
+}
+
+func TestAWSConfigEndpoint(t *testing.T) {
+	t.Setenv("REGION", "us-west-1")
+	tests := []struct {
+		endpoint string
+		want     *string
+	}{
+		{"http://localhost:8000", aws.String("http://localhost:8000")},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Setenv("DYNAMODB_ENDPOINT", tt.endpoint)
+		config := awsConfig()
+		if aws.StringValue(config.Endpoint) != aws.StringValue(tt.want) || (config.Endpoint == nil) != (tt.want == nil) {
+			t.Errorf("DYNAMODB_ENDPOINT=%q: Endpoint = %v, want %v", tt.endpoint, aws.StringValue(config.Endpoint), aws.StringValue(tt.want))
+		}
+		if aws.StringValue(config.Region) != "us-west-1" {
+			t.Errorf("Region = %q, want us-west-1", aws.StringValue(config.Region))
+		}
+	}
+}
+
+func TestGetPageCursor(t *testing.T) {
+	var starts []map[string]*dynamodb.AttributeValue
+	db := &fakeDB{
+		scan: func(in *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			starts = append(starts, in.ExclusiveStartKey)
+			if in.ExclusiveStartKey == nil {
+				return &dynamodb.ScanOutput{
+					Items:            items(t, Win{WinID: "1"}),
+					LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"win_id": {S: aws.String("1")}},
+				}, nil
+			}
+			return &dynamodb.ScanOutput{Items: items(t, Win{WinID: "2"})}, nil
+		},
+	}
+	c := &Client{db: db, table: "wins"}
+	first, cursor, err := c.GetPage("", 1)
+	if err != nil {
+		t.Fatalf("first GetPage error: %v", err)
+	}
+	if len(first) != 1 || first[0].WinID != "1" || cursor == "" {
+		t.Fatalf("first page = %+v with cursor %q, want WIN 1 and a cursor", first, cursor)
+	}
+	if strings.ContainsAny(cursor, "{}\"+/=") {
+		t.Errorf("cursor %q isn't an opaque URL safe token", cursor)
+	}
+	second, next, err := c.GetPage(cursor, 1)
+	if err != nil {
+		t.Fatalf("second GetPage error: %v", err)
+	}
+	if len(second) != 1 || second[0].WinID != "2" || next != "" {
+		t.Errorf("second page = %+v with cursor %q, want WIN 2 and no cursor", second, next)
+	}
+	if len(starts) != 2 || aws.StringValue(starts[1]["win_id"].S) != "1" {
+		t.Errorf("second page started at %v, want after WIN 1", starts)
+	}
+	for _, bad := range []string{"not base64!", "bnVsbA", "e30"} {
+		if _, _, err := c.GetPage(bad, 1); err != ErrInvalidCursor {
+			t.Errorf("GetPage(%q) error = %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	hits := map[string]int{}
+	var ttl string
+	db := &fakeDB{
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			key := aws.StringValue(in.Key["win_id"].S)
+			if !strings.HasPrefix(key, ratePrefix+"U1#") {
+				t.Errorf("counter key = %q, want it under %sU1#", key, ratePrefix)
+			}
+			n, _ := strconv.Atoi(aws.StringValue(in.ExpressionAttributeValues[":n"].N))
+			hits[key] += n
+			ttl = aws.StringValue(in.ExpressionAttributeValues[":ttl"].N)
+			return &dynamodb.UpdateItemOutput{Attributes: map[string]*dynamodb.AttributeValue{
+				"hits": {N: aws.String(strconv.Itoa(hits[key]))},
+			}}, nil
+		},
+	}
+	client := &Client{db: db, table: "wins"}
+	tests := []struct {
+		name string
+		n    int
+		want bool
+	}{
+		{"first WIN", 1, true},
+		{"up to the limit", 2, true},
+		{"over the limit", 1, false},
+		{"still over", 1, false},
+	}
+	for _, tt := range tests {
+		allowed, err := client.Throttle("U1", tt.n, 3, time.Hour)
+		if err != nil {
+			t.Fatalf("%s: Throttle error: %v", tt.name, err)
+		}
+		if allowed != tt.want {
+			t.Errorf("%s: Throttle = %t, want %t", tt.name, allowed, tt.want)
+		}
+	}
+	expiry, _ := strconv.ParseInt(ttl, 10, 64)
+	if start := time.Now().Truncate(time.Hour); expiry != start.Add(2*time.Hour).Unix() {
+		t.Errorf("counter TTL = %d, want two windows from %s", expiry, start)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		wantErr error
+	}{
+		{"submitter", "U1", nil},
+		{"someone else", "U2", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var update *dynamodb.UpdateItemInput
+			db := &fakeDB{
+				updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					update = in
+					if aws.StringValue(in.ExpressionAttributeValues[":owner"].S) != "U1" {
+						return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+					}
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			before := time.Now()
+			err := (&Client{db: db, table: "wins"}).Renew("1", tt.caller, 30)
+			if err != tt.wantErr {
+				t.Fatalf("Renew error = %v, want %v", err, tt.wantErr)
+			}
+			ttl, _ := strconv.ParseInt(aws.StringValue(update.ExpressionAttributeValues[":ttl"].N), 10, 64)
+			if min, max := before.AddDate(0, 0, 30).Unix(), time.Now().AddDate(0, 0, 30).Unix(); ttl < min || ttl > max {
+				t.Errorf(":ttl = %d, want 30 days from now, between %d and %d", ttl, min, max)
+			}
+			expression := aws.StringValue(update.UpdateExpression)
+			if expression != "SET #ttl = :ttl, #updated_at = :updated_at" {
+				t.Errorf("UpdateExpression = %q, want only ttl and updated_at SET so CreatedAt is kept", expression)
+			}
+		})
+	}
+}
+
+func TestPurgeBatches(t *testing.T) {
+	t.Setenv("SCAN_CACHE_SECONDS", "60")
+	defer invalidateScan()
+	cacheScan("wins", []Win{{WinID: "0", TeamID: "T1"}}, time.Now())
+	wins := []Win{{WinID: "other", TeamID: "T2"}}
+	for i := 0; i < maxBatchWrite+5; i++ {
+		wins = append(wins, Win{WinID: strconv.Itoa(i), TeamID: "T1"})
+	}
+	batches := []int{}
+	db := &fakeDB{
+		scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: items(t, wins...)}, nil
+		},
+		batchWrite: func(in *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+			for _, requests := range in.RequestItems {
+				batches = append(batches, len(requests))
+				for _, request := range requests {
+					if aws.StringValue(request.DeleteRequest.Key["win_id"].S) == "other" {
+						t.Error("purged another team's WIN")
+					}
+				}
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+	count, err := (&Client{db: db, table: "wins"}).Purge("T1")
+	if err != nil || count != maxBatchWrite+5 {
+		t.Fatalf("Purge = %d, %v, want %d", count, err, maxBatchWrite+5)
+	}
+	if fmt.Sprint(batches) != fmt.Sprint([]int{maxBatchWrite, 5}) {
+		t.Errorf("batches of %v, want %d then 5", batches, maxBatchWrite)
+	}
+}
+
+// conditionHolds evaluates visibleCondition, with in's values, against w
+func conditionHolds(t *testing.T, in *dynamodb.UpdateItemInput, w Win) bool {
+	t.Helper()
+	if got := aws.StringValue(in.ConditionExpression); !strings.HasPrefix(got, visibleCondition) {
+		t.Errorf("ConditionExpression = %q, want it to start with %q", got, visibleCondition)
+	}
+	values := in.ExpressionAttributeValues
+	return w.TeamID == aws.StringValue(values[":team"].S) &&
+		!strings.HasPrefix(w.WinID, aws.StringValue(values[":marker"].S)) &&
+		w.Archived != aws.BoolValue(values[":true"].BOOL) &&
+		w.Visibility != aws.StringValue(values[":private"].S)
+}
+
+func TestAddKudos(t *testing.T) {
+	tests := []struct {
+		name    string
+		win     Win
+		wantErr error
+	}{
+		{"team WIN", Win{WinID: "1", TeamID: "T1"}, nil},
+		{"public WIN", Win{WinID: "1", TeamID: "T1", Visibility: VisibilityPublic}, nil},
+		{"another team", Win{WinID: "1", TeamID: "T2"}, ErrNotFound},
+		{"request marker", Win{WinID: ratePrefix + "T1#U1", TeamID: "T1"}, ErrNotFound},
+		{"archived", Win{WinID: "1", TeamID: "T1", Archived: true}, ErrNotFound},
+		{"private", Win{WinID: "1", TeamID: "T1", Visibility: VisibilityPrivate}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			db := &fakeDB{
+				updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					calls++
+					if calls == 1 {
+						return nil, awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+					}
+					if !conditionHolds(t, in, tt.win) {
+						return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+					}
+					if got := aws.StringValue(in.UpdateExpression); got != "ADD kudos :one" {
+						t.Errorf("UpdateExpression = %q", got)
+					}
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			err := (&Client{db: db, table: "wins"}).AddKudos("T1", tt.win.WinID)
+			if err != tt.wantErr {
+				t.Fatalf("AddKudos error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != 2 {
+				t.Errorf("UpdateItem called %d times, want the throttled call retried once", calls)
+			}
+		})
+	}
+}
+
+func TestAddReaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		reactions map[string]int
+		emoji     string
+		want      map[string]int
+	}{
+		{"first reaction", nil, "tada", map[string]int{"tada": 1}},
+		{"new emoji", map[string]int{"tada": 2}, "rocket", map[string]int{"tada": 2, "rocket": 1}},
+		{"existing emoji", map[string]int{"tada": 2}, "tada", map[string]int{"tada": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reactions := tt.reactions
+			failed := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+			calls := 0
+			db := &fakeDB{
+				updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					calls++
+					if calls == 1 {
+						return nil, awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+					}
+					if !conditionHolds(t, in, Win{WinID: "1", TeamID: "T1"}) {
+						return nil, failed
+					}
+					switch aws.StringValue(in.UpdateExpression) {
+					case "ADD #reactions.#emoji :one":
+						if reactions == nil {
+							return nil, failed
+						}
+						reactions[aws.StringValue(in.ExpressionAttributeNames["#emoji"])]++
+					case "SET #reactions = :first":
+						if reactions != nil {
+							return nil, failed
+						}
+						reactions = map[string]int{}
+						for emoji := range in.ExpressionAttributeValues[":first"].M {
+							reactions[emoji] = 1
+						}
+					default:
+						t.Fatalf("unexpected UpdateExpression %q", aws.StringValue(in.UpdateExpression))
+					}
+					return &dynamodb.UpdateItemOutput{}, nil
+				},
+			}
+			if err := (&Client{db: db, table: "wins"}).AddReaction("T1", "1", tt.emoji); err != nil {
+				t.Fatalf("AddReaction error: %v", err)
+			}
+			if fmt.Sprint(reactions) != fmt.Sprint(tt.want) {
+				t.Errorf("reactions = %v, want %v", reactions, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddReactionHidden(t *testing.T) {
+	tests := []struct {
+		name string
+		win  Win
+	}{
+		{"another team", Win{WinID: "1", TeamID: "T2"}},
+		{"request marker", Win{WinID: ratePrefix + "T1#U1", TeamID: "T1"}},
+		{"archived", Win{WinID: "1", TeamID: "T1", Archived: true}},
+		{"private", Win{WinID: "1", TeamID: "T1", Visibility: VisibilityPrivate}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{
+				updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+					if conditionHolds(t, in, tt.win) {
+						t.Errorf("%s with %v passed the condition", aws.StringValue(in.UpdateExpression), tt.win)
+					}
+					return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+				},
+			}
+			if err := (&Client{db: db, table: "wins"}).AddReaction("T1", tt.win.WinID, "tada"); err != ErrNotFound {
+				t.Errorf("AddReaction error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestReactionName(t *testing.T) {
+	tests := []struct {
+		shortcode string
+		want      string
+		wantOK    bool
+	}{
+		{":tada:", "tada", true},
+		{":+1:", "+1", true},
+		{":Party_Parrot:", "party_parrot", true},
+		{"tada", "", false},
+		{"::", "", false},
+		{":ta da:", "", false},
+		{":tada::rocket:", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := ReactionName(tt.shortcode); got != tt.want || ok != tt.wantOK {
+			t.Errorf("ReactionName(%q) = %q, %t, want %q, %t", tt.shortcode, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCompleteDialog(t *testing.T) {
+	rows := map[string]map[string]*dynamodb.AttributeValue{}
+	db := &fakeDB{
+		putItem: func(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+			rows[aws.StringValue(in.Item["win_id"].S)] = in.Item
+			return &dynamodb.PutItemOutput{}, nil
+		},
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			row, ok := rows[aws.StringValue(in.Key["win_id"].S)]
+			if !ok {
+				return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+			}
+			row["completed"] = in.ExpressionAttributeValues[":true"]
+			row["completed_at"] = in.ExpressionAttributeValues[":now"]
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	client := &Client{db: db, table: "wins"}
+	if err := client.TrackDialog("1.2.3", "T1"); err != nil {
+		t.Fatalf("TrackDialog error: %v", err)
+	}
+	row := rows[dialogPrefix+"1.2.3"]
+	if row == nil || aws.BoolValue(row["completed"].BOOL) || aws.StringValue(row["team_id"].S) != "T1" {
+		t.Fatalf("tracked row = %v, want an open dialog for T1", row)
+	}
+	if ttl, _ := strconv.ParseInt(aws.StringValue(row["ttl"].N), 10, 64); ttl <= time.Now().Unix() || ttl > time.Now().Add(dialogTTL).Unix() {
+		t.Errorf("tracked row TTL = %d, want within %s", ttl, dialogTTL)
+	}
+	if err := client.CompleteDialog("1.2.3"); err != nil {
+		t.Fatalf("CompleteDialog error: %v", err)
+	}
+	if !aws.BoolValue(row["completed"].BOOL) || aws.StringValue(row["completed_at"].S) == "" {
+		t.Errorf("row after CompleteDialog = %v, want it completed", row)
+	}
+	if err := client.CompleteDialog("9.9.9"); err != ErrNotFound {
+		t.Errorf("CompleteDialog of an untracked dialog error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	t.Setenv("MAX_BODY_BYTES", "9")
+	tests := []struct {
+		name   string
+		body   string
+		base64 bool
+		want   bool
+	}{
+		{"at the limit", "123456789", false, false},
+		{"over", "1234567890", false, true},
+		{"base64 at the limit", "MTIzNDU2Nzg5", true, false},
+		{"base64 over", "MTIzNDU2Nzg5MA==", true, true},
+	}
+	for _, tt := range tests {
+		if got := BodyTooLarge(tt.body, tt.base64); got != tt.want {
+			t.Errorf("%s: BodyTooLarge = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+	for value, want := range map[string]int{"": DefaultMaxBodyBytes, "2048": 2048, "-1": DefaultMaxBodyBytes, "big": DefaultMaxBodyBytes} {
+		t.Setenv("MAX_BODY_BYTES", value)
+		if got := MaxBodyBytes(); got != want {
+			t.Errorf("MaxBodyBytes with %q = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestHidden(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		visibleAfter time.Time
+		want         bool
+	}{
+		{"not scheduled", time.Time{}, false},
+		{"scheduled for later", now.Add(time.Hour), true},
+		{"its time has come", now.Add(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := (Win{VisibleAfter: tt.visibleAfter}).Hidden(now); got != tt.want {
+			t.Errorf("%s: Hidden = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	tests := []struct {
+		visibility string
+		team       bool
+		public     bool
+	}{
+		{"", true, false},
+		{VisibilityPrivate, false, false},
+		{VisibilityTeam, true, false},
+		{VisibilityPublic, true, true},
+		{"Public ", true, true},
+		{"everyone", true, false},
+	}
+	for _, tt := range tests {
+		win := Win{Visibility: tt.visibility}
+		if got := win.VisibleTo(VisibilityTeam); got != tt.team {
+			t.Errorf("%q VisibleTo(team) = %t, want %t", tt.visibility, got, tt.team)
+		}
+		if got := win.VisibleTo(VisibilityPublic); got != tt.public {
+			t.Errorf("%q VisibleTo(public) = %t, want %t", tt.visibility, got, tt.public)
+		}
+	}
+}
+
+func TestBackfillTTL(t *testing.T) {
+	t.Setenv("WIN_TTL_DAYS", "30")
+	t.Setenv("CONFIG_TABLE_NAME", "")
+	created := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+	wins := []Win{
+		{WinID: "1", TeamID: "T1", CreatedAt: created},
+		{WinID: "2", TeamID: "T1", CreatedAt: created, TTL: created.AddDate(0, 0, 90).Unix()},
+		{WinID: "3", TeamID: "T1", CreatedAt: created},
+		{WinID: "4", TeamID: "T1", CreatedAt: created, TTL: created.AddDate(0, 0, 30).Unix()},
+		{WinID: "5", TeamID: "T1", CreatedAt: created},
+	}
+	updated := map[string]string{}
+	db := &fakeDB{
+		scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: items(t, wins...)}, nil
+		},
+		updateItem: func(in *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			winID := aws.StringValue(in.Key["win_id"].S)
+			if winID == "3" {
+				// renewed since the scan
+				return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+			}
+			updated[winID] = aws.StringValue(in.ExpressionAttributeValues[":ttl"].N)
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	progress := []string{}
+	set, err := (&Client{db: db, table: "wins"}).BackfillTTL(2, func(done, total int) {
+		progress = append(progress, fmt.Sprintf("%d/%d", done, total))
+	})
+	if err != nil {
+		t.Fatalf("BackfillTTL error: %v", err)
+	}
+	if set != 2 {
+		t.Errorf("BackfillTTL set %d, want 2", set)
+	}
+	want := strconv.FormatInt(created.AddDate(0, 0, 30).Unix(), 10)
+	if len(updated) != 2 || updated["1"] != want || updated["5"] != want {
+		t.Errorf("updated %v, want WINS 1 and 5 given TTL %s", updated, want)
+	}
+	if strings.Join(progress, ",") != "2/3,3/3" {
+		t.Errorf("progress = %v, want 2/3 then 3/3", progress)
+	}
+	if got := MissingTTL(wins); len(got) != 3 {
+		t.Errorf("MissingTTL = %d WINS, want 3", len(got))
+	}
+}
@@ -0,0 +1,86 @@
+package kanowins
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkTTL is how long a summary link from SummaryLink stays valid
+const LinkTTL = 24 * time.Hour
+
+// ErrLinksNotConfigured is returned when SUMMARY_LINK_SECRET or
+// SUMMARY_LINK_URL is unset, so summary links can't be made
+var ErrLinksNotConfigured = errors.New("summary links are not configured")
+
+// ErrInvalidLink is returned by VerifyLink for a token it didn't sign, or
+// one that has been tampered with
+var ErrInvalidLink = errors.New("invalid summary link")
+
+// ErrExpiredLink is returned by VerifyLink for a token past its expiry
+var ErrExpiredLink = errors.New("expired summary link")
+
+// linkSignature is the HMAC-SHA256 of payload keyed with secret, base64url
+// encoded
+func linkSignature(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignLink returns a token granting read access to teamID's summary until
+// expires, `<team>.<expiry>.<signature>` signed with SUMMARY_LINK_SECRET
+func SignLink(teamID string, expires time.Time) (string, error) {
+	secret := os.Getenv("SUMMARY_LINK_SECRET")
+	if secret == "" {
+		return "", ErrLinksNotConfigured
+	}
+	payload := teamID + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + linkSignature(payload, secret), nil
+}
+
+// VerifyLink returns the team a SignLink token grants access to, checking
+// its signature before its expiry so a tampered token is never reported as
+// merely expired
+func VerifyLink(token string, now time.Time) (string, error) {
+	secret := os.Getenv("SUMMARY_LINK_SECRET")
+	if secret == "" {
+		return "", ErrLinksNotConfigured
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", ErrInvalidLink
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(linkSignature(payload, secret))) {
+		return "", ErrInvalidLink
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidLink
+	}
+	if now.Unix() >= expires {
+		return "", ErrExpiredLink
+	}
+	return parts[0], nil
+}
+
+// SummaryLink returns a URL to the KanowinsAPI endpoint at SUMMARY_LINK_URL
+// serving teamID's summary as HTML without Slack auth, valid for LinkTTL
+func SummaryLink(teamID string, now time.Time) (string, error) {
+	base := os.Getenv("SUMMARY_LINK_URL")
+	if base == "" {
+		return "", ErrLinksNotConfigured
+	}
+	token, err := SignLink(teamID, now.Add(LinkTTL))
+	if err != nil {
+		return "", err
+	}
+	return base + "?token=" + url.QueryEscape(token), nil
+}
@@ -0,0 +1,71 @@
+package kanowins
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyLink(t *testing.T) {
+	t.Setenv("SUMMARY_LINK_SECRET", "secret")
+	now := time.Now()
+	token, err := SignLink("T1", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SignLink error: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		want    string
+		wantErr error
+	}{
+		{"valid", token, now, "T1", nil},
+		{"expired", token, now.Add(2 * time.Hour), "", ErrExpiredLink},
+		{"other team", "T2." + parts[1] + "." + parts[2], now, "", ErrInvalidLink},
+		{"later expiry", parts[0] + "." + "9999999999" + "." + parts[2], now, "", ErrInvalidLink},
+		{"tampered and expired", "T2." + parts[1] + "." + parts[2], now.Add(2 * time.Hour), "", ErrInvalidLink},
+		{"malformed", "T1", now, "", ErrInvalidLink},
+	}
+	for _, tt := range tests {
+		got, err := VerifyLink(tt.token, tt.now)
+		if got != tt.want || err != tt.wantErr {
+			t.Errorf("%s: VerifyLink = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+	t.Setenv("SUMMARY_LINK_SECRET", "rotated")
+	if _, err := VerifyLink(token, now); err != ErrInvalidLink {
+		t.Errorf("VerifyLink with another secret error = %v, want ErrInvalidLink", err)
+	}
+}
+
+func TestSummaryLink(t *testing.T) {
+	now := time.Now()
+	t.Setenv("SUMMARY_LINK_SECRET", "secret")
+	t.Setenv("SUMMARY_LINK_URL", "")
+	if _, err := SummaryLink("T1", now); err != ErrLinksNotConfigured {
+		t.Errorf("SummaryLink without SUMMARY_LINK_URL error = %v, want ErrLinksNotConfigured", err)
+	}
+	t.Setenv("SUMMARY_LINK_URL", "https://api.example.com/wins")
+	link, err := SummaryLink("T1", now)
+	if err != nil {
+		t.Fatalf("SummaryLink error: %v", err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || !strings.HasPrefix(link, "https://api.example.com/wins?token=") {
+		t.Fatalf("SummaryLink = %q, want the API URL with a token", link)
+	}
+	token := parsed.Query().Get("token")
+	if team, err := VerifyLink(token, now.Add(LinkTTL-time.Minute)); team != "T1" || err != nil {
+		t.Errorf("VerifyLink before LinkTTL = %q, %v, want T1", team, err)
+	}
+	if _, err := VerifyLink(token, now.Add(LinkTTL)); err != ErrExpiredLink {
+		t.Errorf("VerifyLink after LinkTTL error = %v, want ErrExpiredLink", err)
+	}
+	t.Setenv("SUMMARY_LINK_SECRET", "")
+	if _, err := SignLink("T1", now); err != ErrLinksNotConfigured {
+		t.Errorf("SignLink without SUMMARY_LINK_SECRET error = %v, want ErrLinksNotConfigured", err)
+	}
+}
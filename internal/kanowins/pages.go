@@ -0,0 +1,168 @@
+package kanowins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// DefaultPageSize is how many WINS a `/wins mine` or `/wins search` page
+// shows when RESULTS_PAGE_SIZE is unset
+const DefaultPageSize = 10
+
+// Listings that can be paged through with Prev and Next buttons
+const (
+	ListMine   = "mine"
+	ListSearch = "search"
+)
+
+// PageCursor is the position in a paged listing, carried as the value of
+// its Prev and Next buttons
+type PageCursor struct {
+	List   string `json:"list"`
+	Term   string `json:"term,omitempty"`
+	Offset int    `json:"offset"`
+}
+
+// DecodePageCursor decodes a Prev or Next button value
+func DecodePageCursor(value string) (cursor PageCursor, err error) {
+	err = json.Unmarshal([]byte(value), &cursor)
+	return
+}
+
+// PageSize returns how many WINS a listing page shows from
+// RESULTS_PAGE_SIZE, or DefaultPageSize
+func PageSize() int {
+	size := DefaultPageSize
+	if value := os.Getenv("RESULTS_PAGE_SIZE"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			logging.Printf("kanowins.PageSize - invalid RESULTS_PAGE_SIZE %q, using %d", value, DefaultPageSize)
+		} else {
+			size = parsed
+		}
+	}
+	if size > maxBlocks-3 {
+		size = maxBlocks - 3
+	}
+	return size
+}
+
+// PageRange returns the start and end of the page of size at offset within
+// total results
+func PageRange(total, offset, size int) (start, end int) {
+	if offset >= total {
+		offset = (total - 1) / size * size
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	end = offset + size
+	if end > total {
+		end = total
+	}
+	return offset, end
+}
+
+// MatchWins returns the WINS whose Who, Title or Description contain term,
+// ignoring case
+func MatchWins(wins []Win, term string) []Win {
+	term = strings.ToLower(term)
+	matches := []Win{}
+	for _, win := range wins {
+		for _, field := range []string{win.Who, win.Title, win.Description} {
+			if strings.Contains(strings.ToLower(field), term) {
+				matches = append(matches, win)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// Listing returns the heading and summaries of cursor's listing of wins,
+// newest first. A search leaves out hidden and private WINS
+func Listing(cursor PageCursor, wins []Win, now time.Time) (string, []WinSummary) {
+	current := []Win{}
+	for _, win := range wins {
+		if !win.Expired(now) && !win.Archived && (cursor.List == ListMine || !win.Hidden(now) && win.VisibleTo(VisibilityTeam)) {
+			current = append(current, win)
+		}
+	}
+	if cursor.List == ListSearch {
+		current = MatchWins(current, cursor.Term)
+	}
+	sort.SliceStable(current, func(i, j int) bool {
+		return current[i].CreatedAt.After(current[j].CreatedAt)
+	})
+	summaries := Summarize(current)
+	if cursor.List != ListSearch {
+		return "Your WINS", summaries
+	}
+	for i := range summaries {
+		summaries[i] = Highlight(summaries[i], cursor.Term)
+	}
+	return "Search results", summaries
+}
+
+// BuildPageBlocks renders the page of wins at cursor's offset under heading,
+// with Prev and Next buttons carrying the neighbouring pages' cursors
+func BuildPageBlocks(heading string, wins []WinSummary, cursor PageCursor, size int) []map[string]interface{} {
+	start, end := PageRange(len(wins), cursor.Offset, size)
+	if len(wins) > size {
+		heading = fmt.Sprintf("%s (%d–%d of %d)", heading, start+1, end, len(wins))
+	} else {
+		heading = fmt.Sprintf("%s (%d)", heading, len(wins))
+	}
+	blocks := []map[string]interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  heading,
+				"emoji": true,
+			},
+		},
+		map[string]interface{}{
+			"type": "divider",
+		},
+	}
+	for _, win := range wins[start:end] {
+		blocks = append(blocks, winSection(win))
+	}
+	buttons := []map[string]interface{}{}
+	if start > 0 {
+		buttons = append(buttons, pageButton("page-prev", "Prev", cursor, start-size))
+	}
+	if end < len(wins) {
+		buttons = append(buttons, pageButton("page-next", "Next", cursor, end))
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "actions",
+			"elements": buttons,
+		})
+	}
+	return blocks
+}
+
+// pageButton builds a button moving cursor's listing to offset
+func pageButton(actionID, label string, cursor PageCursor, offset int) map[string]interface{} {
+	cursor.Offset = offset
+	value, _ := json.Marshal(cursor)
+	return map[string]interface{}{
+		"type":      "button",
+		"action_id": actionID,
+		"text": map[string]interface{}{
+			"type": "plain_text",
+			"text": label,
+		},
+		"value": string(value),
+	}
+}
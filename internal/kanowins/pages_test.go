@@ -0,0 +1,123 @@
+package kanowins
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatchWins(t *testing.T) {
+	wins := []Win{
+		{WinID: "who", Who: "Launch Team", Title: "Shipped"},
+		{WinID: "title", Who: "Alice", Title: "The LAUNCH went out"},
+		{WinID: "description", Who: "Bob", Title: "Fixed it", Description: "before the launch"},
+		{WinID: "none", Who: "Carol", Title: "Wrote the docs"},
+	}
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"launch", []string{"who", "title", "description"}},
+		{"LaUnCh", []string{"who", "title", "description"}},
+		{"docs", []string{"none"}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			got := []string{}
+			for _, win := range MatchWins(wins, tt.term) {
+				got = append(got, win.WinID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("MatchWins(%q) = %v, want %v", tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text string
+		term string
+		want string
+	}{
+		{"The LAUNCH went out", "launch", "The *LAUNCH* went out"},
+		{"relaunched twice, launch again", "launch", "*relaunched* twice, *launch* again"},
+		{"nothing here", "launch", "nothing here"},
+		{"anything", "", "anything"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.term); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.text, tt.term, got, tt.want)
+		}
+	}
+	win := Highlight(WinSummary{Who: "Launch Team", Title: "Launch day", Description: "a launch"}, "launch")
+	if win.Who != "Launch Team" || win.Title != "*Launch* day" || win.Description != "a *launch*" {
+		t.Errorf("Highlight = %+v, want title and description highlighted", win)
+	}
+}
+
+func TestPageRange(t *testing.T) {
+	tests := []struct {
+		name                string
+		total, offset, size int
+		wantStart, wantEnd  int
+	}{
+		{"first page", 25, 0, 10, 0, 10},
+		{"middle page", 25, 10, 10, 10, 20},
+		{"last partial page", 25, 20, 10, 20, 25},
+		{"exact last page", 20, 10, 10, 10, 20},
+		{"past the end", 25, 40, 10, 20, 25},
+		{"at the end of full pages", 20, 20, 10, 10, 20},
+		{"negative", 25, -10, 10, 0, 10},
+		{"one page", 3, 0, 10, 0, 3},
+		{"empty", 0, 0, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := PageRange(tt.total, tt.offset, tt.size)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("PageRange(%d, %d, %d) = %d, %d, want %d, %d", tt.total, tt.offset, tt.size, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestBuildPageBlocksButtons(t *testing.T) {
+	wins := []WinSummary{}
+	for i := 0; i < 25; i++ {
+		wins = append(wins, WinSummary{Who: "Bob", Title: "Shipped"})
+	}
+	tests := []struct {
+		offset      int
+		wantHeading string
+		wantButtons map[string]int
+	}{
+		{0, "Your WINS (1–10 of 25)", map[string]int{"page-next": 10}},
+		{10, "Your WINS (11–20 of 25)", map[string]int{"page-prev": 0, "page-next": 20}},
+		{20, "Your WINS (21–25 of 25)", map[string]int{"page-prev": 10}},
+	}
+	for _, tt := range tests {
+		blocks := BuildPageBlocks("Your WINS", wins, PageCursor{List: ListMine, Offset: tt.offset}, 10)
+		if heading := blocks[0]["text"].(map[string]interface{})["text"]; heading != tt.wantHeading {
+			t.Errorf("offset %d: heading = %q, want %q", tt.offset, heading, tt.wantHeading)
+		}
+		buttons := map[string]int{}
+		if actions := blocks[len(blocks)-1]; actions["type"] == "actions" {
+			for _, button := range actions["elements"].([]map[string]interface{}) {
+				var cursor PageCursor
+				if err := json.Unmarshal([]byte(button["value"].(string)), &cursor); err != nil {
+					t.Fatalf("button value %q: %v", button["value"], err)
+				}
+				buttons[button["action_id"].(string)] = cursor.Offset
+			}
+		}
+		if fmt.Sprint(buttons) != fmt.Sprint(tt.wantButtons) {
+			t.Errorf("offset %d: buttons = %v, want %v", tt.offset, buttons, tt.wantButtons)
+		}
+	}
+	if blocks := BuildPageBlocks("Your WINS", wins[:3], PageCursor{List: ListMine}, 10); blocks[len(blocks)-1]["type"] == "actions" {
+		t.Error("a single page has page buttons")
+	}
+}
@@ -0,0 +1,38 @@
+package kanowins
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DialogState is the context KanowinsCommand passes through a dialog's
+// state, Slack only returns the submission fields and the state to
+// KanowinsInteractiveComponent
+type DialogState struct {
+	WinID       string `json:"win_id,omitempty"`
+	TeamID      string `json:"team_id,omitempty"`
+	ChannelID   string `json:"channel_id,omitempty"`
+	ChannelName string `json:"channel_name,omitempty"`
+	Permalink   string `json:"permalink,omitempty"`
+	TriggerID   string `json:"trigger_id,omitempty"`
+
+	// Preview marks a form opened by `/wins preview`, whose WIN is shown to
+	// the submitter to confirm before it is saved
+	Preview bool `json:"preview,omitempty"`
+}
+
+// Encode renders the state as the JSON string set on the dialog
+func (s DialogState) Encode() string {
+	state, _ := json.Marshal(s)
+	return string(state)
+}
+
+// DecodeDialogState parses a dialog state set by Encode, or a bare WinID
+// from older dialogs
+func DecodeDialogState(state string) DialogState {
+	s := DialogState{}
+	if !strings.HasPrefix(state, "{") || json.Unmarshal([]byte(state), &s) != nil {
+		return DialogState{WinID: state}
+	}
+	return s
+}
@@ -0,0 +1,24 @@
+package kanowins
+
+import "testing"
+
+func TestDialogStateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		state string
+		want  DialogState
+	}{
+		{"encoded", DialogState{WinID: "w1", TeamID: "T1", ChannelID: "C1", ChannelName: "general", TriggerID: "1.2.3", Preview: true}.Encode(),
+			DialogState{WinID: "w1", TeamID: "T1", ChannelID: "C1", ChannelName: "general", TriggerID: "1.2.3", Preview: true}},
+		{"empty", DialogState{}.Encode(), DialogState{}},
+		{"bare WinID from an older dialog", "w1", DialogState{WinID: "w1"}},
+		{"no state", "", DialogState{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeDialogState(tt.state); got != tt.want {
+				t.Errorf("DecodeDialogState(%q) = %+v, want %+v", tt.state, got, tt.want)
+			}
+		})
+	}
+}
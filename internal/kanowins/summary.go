@@ -0,0 +1,709 @@
+package kanowins
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// maxBlocks is Slack's limit on the blocks in a single message
+const maxBlocks = 50
+
+// maxShownReactions is how many of a WIN's emoji reactions a summary shows
+const maxShownReactions = 3
+
+// DefaultSummaryMax is how many WINS a summary shows when SUMMARY_MAX is unset
+const DefaultSummaryMax = 50
+
+// DefaultDescriptionTruncate is how many characters of a description a
+// summary shows when SUMMARY_DESC_TRUNCATE is unset
+const DefaultDescriptionTruncate = 200
+
+// createdLayout is how a WIN's CreatedAt is shown in summaries
+const createdLayout = "Mon 2 Jan 15:04 MST"
+
+// RepeatWindow is how close together identical WINS must be logged to be
+// collapsed as a double submit
+const RepeatWindow = 10 * time.Minute
+
+// linkPattern matches Slack's `<url>` and `<url|text>` link syntax
+var linkPattern = regexp.MustCompile(`<(?:https?://|mailto:)[^<>|\s]+(?:\|[^<>]*)?>`)
+
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SanitizeMrkdwn escapes &, < and > as Slack's formatting rules require,
+// keeping valid `<url|text>` links. A typed `<!channel>` is left inert
+//
+// https://api.slack.com/reference/surfaces/formatting#escaping
+func SanitizeMrkdwn(text string) string {
+	escaped := ""
+	last := 0
+	for _, loc := range linkPattern.FindAllStringIndex(text, -1) {
+		escaped += mrkdwnEscaper.Replace(text[last:loc[0]]) + text[loc[0]:loc[1]]
+		last = loc[1]
+	}
+	return escaped + mrkdwnEscaper.Replace(text[last:])
+}
+
+// WinSummary is a WIN as shown in summaries
+type WinSummary struct {
+	WinID       string         `json:"win_id"`
+	Who         string         `json:"who"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	CreatedAt   string         `json:"created_at"`
+	Kudos       int            `json:"kudos"`
+	Reactions   map[string]int `json:"reactions,omitempty"`
+	Category    string         `json:"category,omitempty"`
+	Impact      float64        `json:"impact,omitempty"`
+	ImpactUnit  string         `json:"impact_unit,omitempty"`
+	UserID      string         `json:"user_id,omitempty"`
+	Repeats     int            `json:"repeats,omitempty"`
+	Permalink   string         `json:"permalink,omitempty"`
+	URL         string         `json:"url,omitempty"`
+}
+
+// SummaryMax returns how many WINS a summary shows from SUMMARY_MAX,
+// falling back to DefaultSummaryMax when it is unset or invalid
+func SummaryMax() int {
+	value := os.Getenv("SUMMARY_MAX")
+	if value == "" {
+		return DefaultSummaryMax
+	}
+	max, err := strconv.Atoi(value)
+	if err != nil || max <= 0 {
+		logging.Printf("kanowins.SummaryMax - invalid SUMMARY_MAX %q, using %d", value, DefaultSummaryMax)
+		return DefaultSummaryMax
+	}
+	return max
+}
+
+// DescriptionTruncate returns how many characters of a description a
+// summary shows from SUMMARY_DESC_TRUNCATE, falling back to
+// DefaultDescriptionTruncate when it is unset or invalid
+func DescriptionTruncate() int {
+	value := os.Getenv("SUMMARY_DESC_TRUNCATE")
+	if value == "" {
+		return DefaultDescriptionTruncate
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		logging.Printf("kanowins.DescriptionTruncate - invalid SUMMARY_DESC_TRUNCATE %q, using %d", value, DefaultDescriptionTruncate)
+		return DefaultDescriptionTruncate
+	}
+	return limit
+}
+
+// truncateDescription shortens description to limit characters ending in an
+// ellipsis, the stored WIN keeps the full text for export
+func truncateDescription(description string, limit int) string {
+	runes := []rune(description)
+	if len(runes) <= limit {
+		return description
+	}
+	return strings.TrimRightFunc(string(runes[:limit]), unicode.IsSpace) + "…"
+}
+
+// Location returns the team's time zone from TEAM_TIMEZONE, an IANA name
+// such as `Europe/London`, falling back to UTC when it is unset or unknown
+func Location() *time.Location {
+	name := os.Getenv("TEAM_TIMEZONE")
+	if name == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		logging.Printf("kanowins.Location - invalid TEAM_TIMEZONE %q, using UTC: %v", name, err)
+		return time.UTC
+	}
+	return location
+}
+
+// mention renders userID with Slack's `<@USERID>` syntax, so Slack shows the
+// user's current display name rather than the username stored with the WIN
+func mention(userID string) string {
+	return "<@" + userID + ">"
+}
+
+// ShowSubmitter reports whether summaries credit who logged each WIN
+// alongside who it is for, on unless SHOW_SUBMITTER turns it off, anonymous
+// WINS are never credited either way
+func ShowSubmitter() bool {
+	switch strings.ToLower(os.Getenv("SHOW_SUBMITTER")) {
+	case "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// Summarize maps WINS to their rendered summary form in the team's time zone
+func Summarize(wins []Win) []WinSummary {
+	winsSummary := []WinSummary{}
+	location := Location()
+	limit := DescriptionTruncate()
+	for _, win := range wins {
+		userID := win.UserID
+		if win.Anonymous {
+			userID = ""
+		}
+		winsSummary = append(winsSummary, WinSummary{
+			WinID:       win.WinID,
+			Who:         win.Who,
+			Title:       win.Title,
+			Description: truncateDescription(win.Description, limit),
+			CreatedAt:   win.CreatedAt.In(location).Format(createdLayout),
+			Kudos:       win.Kudos,
+			Reactions:   win.Reactions,
+			Category:    win.Category,
+			Impact:      win.Impact,
+			ImpactUnit:  win.ImpactUnit,
+			UserID:      userID,
+			Permalink:   win.Permalink,
+			URL:         win.URL,
+		})
+	}
+	return winsSummary
+}
+
+// CollapseRepeats summarises WINS like Summarize, folding repeats logged
+// within `within` into one entry
+func CollapseRepeats(wins []Win, within time.Duration) []WinSummary {
+	winsSummary := []WinSummary{}
+	firsts := []Win{}
+	for _, win := range wins {
+		repeat := -1
+		for i, first := range firsts {
+			gap := win.CreatedAt.Sub(first.CreatedAt)
+			if gap < 0 {
+				gap = -gap
+			}
+			if WhoKey(first.Who) == WhoKey(win.Who) && first.Title == win.Title && first.UserID == win.UserID && gap <= within {
+				repeat = i
+				break
+			}
+		}
+		if repeat >= 0 {
+			winsSummary[repeat].Repeats++
+			winsSummary[repeat].Kudos += win.Kudos
+			continue
+		}
+		summary := Summarize([]Win{win})[0]
+		summary.Repeats = 1
+		winsSummary = append(winsSummary, summary)
+		firsts = append(firsts, win)
+	}
+	return winsSummary
+}
+
+// Highlight bolds the words of the summary's title and description that
+// contain term, matched case-insensitively, the already bold Who is left as is
+func Highlight(win WinSummary, term string) WinSummary {
+	win.Title = highlight(win.Title, term)
+	win.Description = highlight(win.Description, term)
+	return win
+}
+
+// highlight wraps each word of text containing term in `*`, whole words
+// since Slack only applies mrkdwn formatting at word boundaries
+func highlight(text, term string) string {
+	lower, needle := strings.ToLower(text), strings.ToLower(term)
+	if needle == "" || len(lower) != len(text) {
+		// lowercasing changed the byte offsets, leave the text plain
+		return text
+	}
+	highlighted := ""
+	last := 0
+	for {
+		i := strings.Index(lower[last:], needle)
+		if i < 0 {
+			break
+		}
+		start, end := last+i, last+i+len(needle)
+		for start > last && isWordByte(text[start-1]) {
+			start--
+		}
+		for end < len(text) && isWordByte(text[end]) {
+			end++
+		}
+		highlighted += text[last:start] + "*" + text[start:end] + "*"
+		last = end
+	}
+	return highlighted + text[last:]
+}
+
+// isWordByte reports whether b is part of a word for highlight
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// BuildSummaryBlocks renders WIN summaries as Block Kit with a kudos button
+// per WIN
+func BuildSummaryBlocks(wins []WinSummary) []map[string]interface{} {
+	return BuildHeadedSummaryBlocks(fmt.Sprintf("WINS summary (%d)", len(wins)), wins)
+}
+
+// BuildHeadedSummaryBlocks renders WIN summaries like BuildSummaryBlocks
+// under the given heading
+func BuildHeadedSummaryBlocks(heading string, wins []WinSummary) []map[string]interface{} {
+	blocks := []map[string]interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  heading,
+				"emoji": true,
+			},
+		},
+		map[string]interface{}{
+			"type": "divider",
+		},
+	}
+	// show at most SUMMARY_MAX, keeping room for the header, divider and the
+	// truncation footer
+	limit := SummaryMax()
+	if limit > maxBlocks-3 {
+		limit = maxBlocks - 3
+	}
+	shown := wins
+	if len(shown) > limit {
+		shown = shown[:limit]
+	}
+	for _, win := range shown {
+		blocks = append(blocks, winSection(win))
+	}
+	footer := Scoreboard(wins)
+	if len(shown) < len(wins) {
+		footer = fmt.Sprintf("Showing %d of %d — run `/wins export` for all. %s", len(shown), len(wins), footer)
+	}
+	return append(blocks, map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]interface{}{
+			map[string]interface{}{
+				"type": "mrkdwn",
+				"text": footer,
+			},
+		},
+	})
+}
+
+// Scoreboard sums up wins for a summary footer as
+// "N WINS • M kudos • total impact: $12,500 • top contributor: <@USERID>".
+// The top contributor logged the most of them, and isn't named while
+// ShowSubmitter is off
+func Scoreboard(wins []WinSummary) string {
+	kudos := 0
+	logged := map[string]int{}
+	for _, win := range wins {
+		kudos += win.Kudos
+		if win.UserID != "" {
+			logged[win.UserID]++
+		}
+	}
+	scoreboard := fmt.Sprintf("%d WINS • %d kudos", len(wins), kudos)
+	if impact := TotalImpact(wins); impact != "" {
+		scoreboard += " • total impact: " + impact
+	}
+	top := ""
+	for userID, count := range logged {
+		if top == "" || count > logged[top] || count == logged[top] && userID < top {
+			top = userID
+		}
+	}
+	if top != "" && ShowSubmitter() {
+		scoreboard += " • top contributor: " + mention(top)
+	}
+	return scoreboard
+}
+
+// uncategorized heads the section of WINS without a category in
+// BuildCategorySummaryBlocks
+const uncategorized = "Uncategorized"
+
+// BuildCategorySummaryBlocks renders WIN summaries under heading with a
+// section per category, largest first, and a Scoreboard footer
+func BuildCategorySummaryBlocks(heading string, wins []WinSummary) []map[string]interface{} {
+	groups := map[string][]WinSummary{}
+	for _, win := range wins {
+		category := win.Category
+		if category == "" {
+			category = uncategorized
+		}
+		groups[category] = append(groups[category], win)
+	}
+	categories := []string{}
+	for category := range groups {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if len(groups[categories[i]]) != len(groups[categories[j]]) {
+			return len(groups[categories[i]]) > len(groups[categories[j]])
+		}
+		return categories[i] < categories[j]
+	})
+	blocks := []map[string]interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  heading,
+				"emoji": true,
+			},
+		},
+	}
+	// keep room for the truncation note and the grand total
+	limit := maxBlocks - 2
+	max := SummaryMax()
+	shown := 0
+	for _, category := range categories {
+		if len(blocks) >= limit-1 || shown >= max {
+			break
+		}
+		subtotal := fmt.Sprintf("%d", len(groups[category]))
+		if impact := TotalImpact(groups[category]); impact != "" {
+			subtotal += ", total impact: " + SanitizeMrkdwn(impact)
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s* (%s)", SanitizeMrkdwn(category), subtotal),
+			},
+		})
+		for _, win := range groups[category] {
+			if len(blocks) >= limit || shown >= max {
+				break
+			}
+			blocks = append(blocks, winSection(win))
+			shown++
+		}
+	}
+	footer := Scoreboard(wins)
+	if shown < len(wins) {
+		footer = fmt.Sprintf("Showing %d of %d — run `/wins export` for all. %s", shown, len(wins), footer)
+	}
+	return append(blocks, map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]interface{}{
+			map[string]interface{}{
+				"type": "mrkdwn",
+				"text": footer,
+			},
+		},
+	})
+}
+
+// maxGroupTitles is how many titles a grouped summary lists per group
+const maxGroupTitles = 5
+
+// Group is one person's or category's WINS in a grouped summary, Impact
+// being their TotalImpact
+type Group struct {
+	Name   string
+	Count  int
+	Impact string
+	Titles []string
+}
+
+// GroupSummaries aggregates wins by `who` or `category`, largest group
+// first
+func GroupSummaries(wins []WinSummary, by string) []Group {
+	groups := []Group{}
+	members := [][]WinSummary{}
+	index := map[string]int{}
+	for _, win := range wins {
+		name, key := win.Who, WhoKey(win.Who)
+		if by == "category" {
+			name = win.Category
+			if name == "" {
+				name = uncategorized
+			}
+			key = name
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, Group{Name: name})
+			members = append(members, nil)
+		}
+		groups[i].Count++
+		groups[i].Titles = append(groups[i].Titles, win.Title)
+		members[i] = append(members[i], win)
+	}
+	for i := range groups {
+		groups[i].Impact = TotalImpact(members[i])
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name)
+	})
+	return groups
+}
+
+// groupText renders a group as its name, count, total impact and first
+// maxGroupTitles titles collapsed onto one line
+func groupText(group Group) string {
+	titles := []string{}
+	for i, title := range group.Titles {
+		if i == maxGroupTitles {
+			titles = append(titles, fmt.Sprintf("and %d more", len(group.Titles)-maxGroupTitles))
+			break
+		}
+		titles = append(titles, SanitizeMrkdwn(title))
+	}
+	count := fmt.Sprintf("%d", group.Count)
+	if group.Impact != "" {
+		count += ", total impact: " + SanitizeMrkdwn(group.Impact)
+	}
+	return fmt.Sprintf("*%s* (%s): %s", SanitizeMrkdwn(group.Name), count, strings.Join(titles, "; "))
+}
+
+// BuildGroupedSummaryBlocks renders wins under heading as a section per
+// GroupSummaries group, capped at Slack's block limit, with the Scoreboard
+// footer
+func BuildGroupedSummaryBlocks(heading string, wins []WinSummary, by string) []map[string]interface{} {
+	blocks := []map[string]interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  heading,
+				"emoji": true,
+			},
+		},
+	}
+	groups := GroupSummaries(wins, by)
+	// keep room for the footer
+	shown := groups
+	if len(shown) > maxBlocks-2 {
+		shown = shown[:maxBlocks-2]
+	}
+	for _, group := range shown {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": groupText(group),
+			},
+		})
+	}
+	footer := Scoreboard(wins)
+	if len(shown) < len(groups) {
+		footer = fmt.Sprintf("Showing %d of %d groups. %s", len(shown), len(groups), footer)
+	}
+	return append(blocks, map[string]interface{}{
+		"type": "context",
+		"elements": []map[string]interface{}{
+			map[string]interface{}{
+				"type": "mrkdwn",
+				"text": footer,
+			},
+		},
+	})
+}
+
+// BuildWinBlocks builds the Block Kit message for a single WIN, as posted
+// in reply to a threaded summary
+func BuildWinBlocks(win WinSummary) []map[string]interface{} {
+	return []map[string]interface{}{winSection(win)}
+}
+
+// SummaryText renders WIN summaries as plain text, one WIN per paragraph,
+// for uploading a summary too long to post as a file
+func SummaryText(wins []WinSummary) string {
+	paragraphs := []string{}
+	for _, win := range wins {
+		text := win.Who + " — " + win.Title
+		if win.Repeats > 1 {
+			text += fmt.Sprintf(" (×%d)", win.Repeats)
+		}
+		if win.Kudos > 0 {
+			text += fmt.Sprintf(" [%d kudos]", win.Kudos)
+		}
+		if win.Description != "" {
+			text += "\n  " + strings.Replace(win.Description, "\n", "\n  ", -1)
+		}
+		if win.CreatedAt != "" {
+			text += "\n  " + win.CreatedAt
+		}
+		for _, link := range []string{win.URL, win.Permalink} {
+			if link != "" {
+				text += "\n  " + link
+			}
+		}
+		paragraphs = append(paragraphs, text)
+	}
+	return strings.Join(paragraphs, "\n\n") + "\n"
+}
+
+// markdownEscaper backslash escapes the characters Markdown would otherwise
+// read as formatting in user supplied text
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "~", `\~`,
+)
+
+// SummaryMarkdown renders WIN summaries as a Markdown report for pasting
+// into docs
+func SummaryMarkdown(wins []WinSummary) string {
+	lines := []string{"## WINs", ""}
+	for _, win := range wins {
+		line := fmt.Sprintf("- **%s**: %s", markdownText(win.Who), markdownText(win.Title))
+		if win.Description != "" {
+			line += " — " + markdownText(win.Description)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// markdownText escapes text for a single Markdown bullet
+func markdownText(text string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(text), " "))
+}
+
+// BuildSpotlightBlocks builds the Block Kit message celebrating a single WIN
+// under the given heading
+func BuildSpotlightBlocks(heading string, win WinSummary) []map[string]interface{} {
+	return []map[string]interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  heading,
+				"emoji": true,
+			},
+		},
+		winSection(win),
+	}
+}
+
+// BuildPreviewBlocks builds the message showing a WIN before it's stored
+// under the given prompt, with buttons to confirm or discard the WINS held as
+// previewID
+func BuildPreviewBlocks(prompt string, win WinSummary, previewID string) []map[string]interface{} {
+	return []map[string]interface{}{
+		map[string]interface{}{
+			"type": "context",
+			"elements": []map[string]interface{}{
+				map[string]interface{}{
+					"type": "mrkdwn",
+					"text": prompt,
+				},
+			},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": winText(win),
+			},
+		},
+		map[string]interface{}{
+			"type": "actions",
+			"elements": []map[string]interface{}{
+				map[string]interface{}{
+					"type":      "button",
+					"action_id": "confirm-win",
+					"value":     previewID,
+					"style":     "primary",
+					"text": map[string]interface{}{
+						"type": "plain_text",
+						"text": "Confirm",
+					},
+				},
+				map[string]interface{}{
+					"type":      "button",
+					"action_id": "discard-win",
+					"value":     previewID,
+					"text": map[string]interface{}{
+						"type": "plain_text",
+						"text": "Discard",
+					},
+				},
+			},
+		},
+	}
+}
+
+// winSection renders a WIN as a section block with a kudos button
+func winSection(win WinSummary) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{
+			"type": "mrkdwn",
+			"text": winText(win),
+		},
+		"accessory": map[string]interface{}{
+			"type":      "button",
+			"action_id": "kudos",
+			"value":     win.WinID,
+			"text": map[string]interface{}{
+				"type":  "plain_text",
+				"text":  ":clap: Kudos",
+				"emoji": true,
+			},
+		},
+	}
+}
+
+// topReactions returns the names of the most used reactions, at most
+// maxShownReactions of them, ties in alphabetical order
+func topReactions(reactions map[string]int) []string {
+	names := []string{}
+	for name := range reactions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if reactions[names[i]] != reactions[names[j]] {
+			return reactions[names[i]] > reactions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxShownReactions {
+		names = names[:maxShownReactions]
+	}
+	return names
+}
+
+// winText renders a WIN's who, title and details as mrkdwn
+func winText(win WinSummary) string {
+	text := fmt.Sprintf("*%s* — %s", SanitizeMrkdwn(win.Who), SanitizeMrkdwn(win.Title))
+	if win.UserID != "" && ShowSubmitter() {
+		text += " (logged by " + mention(win.UserID) + ")"
+	}
+	if win.Repeats > 1 {
+		text += fmt.Sprintf(" (×%d)", win.Repeats)
+	}
+	if win.Kudos > 0 {
+		text += fmt.Sprintf("  :clap: %d", win.Kudos)
+	}
+	if win.Impact != 0 {
+		text += "  :chart_with_upwards_trend: " + SanitizeMrkdwn(FormatImpact(win.Impact, win.ImpactUnit))
+	}
+	for _, emoji := range topReactions(win.Reactions) {
+		text += fmt.Sprintf("  :%s: %d", emoji, win.Reactions[emoji])
+	}
+	if win.Description != "" {
+		text += "\n" + SanitizeMrkdwn(win.Description)
+	}
+	if win.CreatedAt != "" {
+		text += "\n_" + win.CreatedAt + "_"
+	}
+	if win.Permalink != "" {
+		text += " · <" + win.Permalink + "|jump to thread>"
+	}
+	if win.URL != "" {
+		text += " · <" + win.URL + "|link>"
+	}
+	return text
+}
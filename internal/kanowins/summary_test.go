@@ -0,0 +1,498 @@
+package kanowins
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeMrkdwn(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "Shipped the release", "Shipped the release"},
+		{"link", "See <https://example.com/pr/1|the PR>", "See <https://example.com/pr/1|the PR>"},
+		{"bare link", "<https://example.com>", "<https://example.com>"},
+		{"ampersand", "Sales & marketing", "Sales &amp; marketing"},
+		{"angle brackets", "latency <200ms, errors >1%", "latency &lt;200ms, errors &gt;1%"},
+		{"link beside escapes", "R&D <https://example.com?a=1&b=2|docs> <b>", "R&amp;D <https://example.com?a=1&b=2|docs> &lt;b&gt;"},
+		{"broadcast", "<!channel> look", "&lt;!channel&gt; look"},
+		{"not a link", "<javascript:alert(1)|x>", "&lt;javascript:alert(1)|x&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeMrkdwn(tt.text); got != tt.want {
+				t.Errorf("SanitizeMrkdwn(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSummaryBlocksEscapes(t *testing.T) {
+	blocks := BuildSummaryBlocks([]WinSummary{{
+		WinID:       "1",
+		Who:         "Alice & Bob",
+		Title:       "Cut p99 to <200ms",
+		Description: "Details in <https://example.com/pr/1|the PR> \"quoted\"",
+	}})
+	body := new(strings.Builder)
+	encoder := json.NewEncoder(body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(blocks); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal([]byte(body.String()), &decoded); err != nil {
+		t.Fatalf("blocks aren't valid JSON: %v", err)
+	}
+	text := body.String()
+	for _, want := range []string{"Alice &amp; Bob", "&lt;200ms", "<https://example.com/pr/1|the PR>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("blocks %s don't contain %s", text, want)
+		}
+	}
+}
+
+func TestAnonymousRendering(t *testing.T) {
+	tests := []struct {
+		name          string
+		anonymous     bool
+		wantSubmitter string
+		wantMention   bool
+	}{
+		{"attributed", false, "alice", true},
+		{"anonymous", true, "Anonymous", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			win := Win{WinID: "1", UserID: "U1", UserName: "alice", Who: "Bob", Title: "Shipped the release", Anonymous: tt.anonymous}
+			if got := win.Submitter(); got != tt.wantSubmitter {
+				t.Errorf("Submitter = %q, want %q", got, tt.wantSubmitter)
+			}
+			summary := Summarize([]Win{win})[0]
+			text := winText(summary)
+			if got := strings.Contains(text, "<@U1>"); got != tt.wantMention {
+				t.Errorf("winText = %q, want the submitter mentioned: %t", text, tt.wantMention)
+			}
+			csv, err := CSV([]Win{win})
+			if err != nil {
+				t.Fatalf("CSV error: %v", err)
+			}
+			if strings.Contains(string(csv), "U1") || !strings.Contains(string(csv), tt.wantSubmitter) {
+				t.Errorf("CSV = %q, want %q and no user ID", csv, tt.wantSubmitter)
+			}
+		})
+	}
+}
+
+func TestCollapseRepeats(t *testing.T) {
+	now := time.Now()
+	win := Win{WinID: "1", UserID: "U1", Who: "Alice", Title: "Shipped the release", CreatedAt: now, Kudos: 1}
+	again := func(change func(*Win)) Win {
+		w := win
+		w.WinID = "2"
+		change(&w)
+		return w
+	}
+	tests := []struct {
+		name        string
+		wins        []Win
+		wantRepeats []int
+	}{
+		{"identical", []Win{win, again(func(w *Win) { w.CreatedAt = now.Add(time.Minute) })}, []int{2}},
+		{"who differs only in spacing", []Win{win, again(func(w *Win) { w.Who = " Alice " })}, []int{2}},
+		{"outside the window", []Win{win, again(func(w *Win) { w.CreatedAt = now.Add(RepeatWindow + time.Second) })}, []int{1, 1}},
+		{"different title", []Win{win, again(func(w *Win) { w.Title = "Fixed the build" })}, []int{1, 1}},
+		{"different submitter", []Win{win, again(func(w *Win) { w.UserID = "U2" })}, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int{}
+			for _, summary := range CollapseRepeats(tt.wins, RepeatWindow) {
+				got = append(got, summary.Repeats)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantRepeats) {
+				t.Errorf("repeats = %v, want %v", got, tt.wantRepeats)
+			}
+		})
+	}
+	collapsed := CollapseRepeats([]Win{win, again(func(*Win) {})}, RepeatWindow)
+	if collapsed[0].Kudos != 2 || !strings.Contains(winText(collapsed[0]), "(×2)") {
+		t.Errorf("collapsed entry = %+v, want kudos 2 shown as ×2", collapsed[0])
+	}
+}
+
+func TestSummarizeTimezone(t *testing.T) {
+	created := time.Date(2024, 7, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		zone string
+		want string
+	}{
+		{"Europe/London", "Mon 1 Jul 13:30 BST"},
+		{"America/New_York", "Mon 1 Jul 08:30 EDT"},
+		{"", "Mon 1 Jul 12:30 UTC"},
+		{"Not/AZone", "Mon 1 Jul 12:30 UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			t.Setenv("TEAM_TIMEZONE", tt.zone)
+			if got := Summarize([]Win{{CreatedAt: created}})[0].CreatedAt; got != tt.want {
+				t.Errorf("CreatedAt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSummaryBlocksCap(t *testing.T) {
+	wins := []WinSummary{}
+	for i := 0; i < 5; i++ {
+		wins = append(wins, WinSummary{WinID: fmt.Sprint(i), Who: "Alice", Title: fmt.Sprintf("WIN %d", i)})
+	}
+	tests := []struct {
+		name       string
+		max        string
+		wantShown  int
+		wantFooter string
+	}{
+		{"capped", "2", 2, "Showing 2 of 5 — run `/wins export` for all. 5 WINS"},
+		{"under the cap", "10", 5, "5 WINS"},
+		{"default", "", 5, "5 WINS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SUMMARY_MAX", tt.max)
+			blocks := BuildSummaryBlocks(wins)
+			sections := 0
+			for _, block := range blocks {
+				if block["type"] == "section" {
+					sections++
+				}
+			}
+			if sections != tt.wantShown {
+				t.Errorf("shown %d WINS, want %d", sections, tt.wantShown)
+			}
+			footer := blocks[len(blocks)-1]["elements"].([]map[string]interface{})[0]["text"].(string)
+			if !strings.HasPrefix(footer, tt.wantFooter) {
+				t.Errorf("footer = %q, want it to start %q", footer, tt.wantFooter)
+			}
+		})
+	}
+}
+
+func TestSummaryMentionsSubmitter(t *testing.T) {
+	if got := mention("U123"); got != "<@U123>" {
+		t.Errorf("mention = %q, want <@U123>", got)
+	}
+	tests := []struct {
+		name string
+		show string
+		want bool
+	}{
+		{"shown", "", true},
+		{"SHOW_SUBMITTER off", "false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHOW_SUBMITTER", tt.show)
+			summary := Summarize([]Win{{WinID: "1", UserID: "U123", UserName: "alice", Who: "Bob", Title: "Shipped"}})
+			text := winText(summary[0])
+			if got := strings.Contains(text, "logged by <@U123>"); got != tt.want {
+				t.Errorf("winText = %q, want the mention: %t", text, tt.want)
+			}
+			if strings.Contains(text, "alice") {
+				t.Errorf("winText = %q shows the username", text)
+			}
+		})
+	}
+}
+
+func TestShowSubmitter(t *testing.T) {
+	tests := []struct {
+		show      string
+		anonymous bool
+		want      string
+	}{
+		{"", false, "*Bob* — Shipped (logged by <@U123>)"},
+		{"true", false, "*Bob* — Shipped (logged by <@U123>)"},
+		{"off", false, "*Bob* — Shipped (logged by <@U123>)"},
+		{"0", false, "*Bob* — Shipped"},
+		{"No", false, "*Bob* — Shipped"},
+		{"", true, "*Bob* — Shipped"},
+		{"false", true, "*Bob* — Shipped"},
+	}
+	for _, tt := range tests {
+		t.Setenv("SHOW_SUBMITTER", tt.show)
+		summary := Summarize([]Win{{WinID: "1", UserID: "U123", Who: "Bob", Title: "Shipped", Anonymous: tt.anonymous}})
+		if got := strings.SplitN(winText(summary[0]), "\n", 2)[0]; got != tt.want {
+			t.Errorf("winText with SHOW_SUBMITTER=%q, anonymous %t = %q, want %q", tt.show, tt.anonymous, got, tt.want)
+		}
+	}
+}
+
+func TestBroadcastTokensInert(t *testing.T) {
+	for _, token := range []string{"<!channel>", "<!here>", "<@U123>", "<!subteam^S123>"} {
+		t.Run(token, func(t *testing.T) {
+			summary := Summarize([]Win{{WinID: "1", Who: token, Title: "hey " + token, Description: token + " again"}})[0]
+			text := winText(summary)
+			if strings.Contains(text, token) {
+				t.Errorf("winText = %q renders %s live", text, token)
+			}
+			if want := "&lt;" + token[1:len(token)-1] + "&gt;"; strings.Count(text, want) != 3 {
+				t.Errorf("winText = %q, want %s shown as text in who, title and description", text, want)
+			}
+		})
+	}
+}
+
+func TestGroupSummariesByWho(t *testing.T) {
+	wins := Summarize([]Win{
+		{Who: "Alice", Title: "one"},
+		{Who: " alice ", Title: "two"},
+		{Who: "Alice  Smith", Title: "three"},
+		{Who: "alice smith", Title: "four"},
+	})
+	tests := []struct {
+		name            string
+		caseInsensitive string
+		want            string
+	}{
+		{"case sensitive", "", "Alice Smith:1, Alice:1, alice smith:1, alice:1"},
+		{"case insensitive", "true", "Alice Smith:2, Alice:2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WHO_CASE_INSENSITIVE", tt.caseInsensitive)
+			got := []string{}
+			for _, group := range GroupSummaries(wins, "who") {
+				got = append(got, fmt.Sprintf("%s:%d", CleanWho(group.Name), group.Count))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("groups = %s, want %s", strings.Join(got, ", "), tt.want)
+			}
+		})
+	}
+}
+
+func TestWhoKey(t *testing.T) {
+	tests := []struct {
+		who             string
+		caseInsensitive string
+		want            string
+	}{
+		{" Alice  Smith ", "", "Alice Smith"},
+		{" Alice  Smith ", "true", "alice smith"},
+		{"ALICE", "1", "alice"},
+	}
+	for _, tt := range tests {
+		t.Setenv("WHO_CASE_INSENSITIVE", tt.caseInsensitive)
+		if got := WhoKey(tt.who); got != tt.want {
+			t.Errorf("WhoKey(%q) with WHO_CASE_INSENSITIVE=%q = %q, want %q", tt.who, tt.caseInsensitive, got, tt.want)
+		}
+	}
+	if got := CleanWho(" Alice \t Smith "); got != "Alice Smith" {
+		t.Errorf("CleanWho = %q, want the display name kept in its case", got)
+	}
+}
+
+func TestWinTextLink(t *testing.T) {
+	if text := winText(WinSummary{Who: "Bob", Title: "Shipped", URL: "https://example.com/pr/1"}); !strings.Contains(text, "<https://example.com/pr/1|link>") {
+		t.Errorf("winText = %q, want a link to the WIN URL", text)
+	}
+	if text := winText(WinSummary{Who: "Bob", Title: "Shipped"}); strings.Contains(text, "|link>") {
+		t.Errorf("winText = %q, want no link without a URL", text)
+	}
+}
+
+func TestTruncateDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        string
+	}{
+		{"short", "Shipped", "Shipped"},
+		{"at the limit", "0123456789", "0123456789"},
+		{"one over", "0123456789x", "0123456789…"},
+		{"multibyte at the limit", "éééééééééé", "éééééééééé"},
+		{"multibyte over", "ééééééééééé", "éééééééééé…"},
+		{"trailing space trimmed", "01234 6789x", "01234 6789…"},
+		{"space at the cut", "012345678 x", "012345678…"},
+	}
+	for _, tt := range tests {
+		if got := truncateDescription(tt.description, 10); got != tt.want {
+			t.Errorf("%s: truncateDescription(%q, 10) = %q, want %q", tt.name, tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeTruncatesDescription(t *testing.T) {
+	long := strings.Repeat("d", DefaultDescriptionTruncate+1)
+	tests := []struct {
+		limit string
+		want  int
+	}{
+		{"", DefaultDescriptionTruncate},
+		{"5", 5},
+		{"not a number", DefaultDescriptionTruncate},
+	}
+	for _, tt := range tests {
+		t.Setenv("SUMMARY_DESC_TRUNCATE", tt.limit)
+		win := Win{WinID: "1", Who: "Bob", Title: "Shipped", Description: long}
+		summary := Summarize([]Win{win})[0]
+		if want := strings.Repeat("d", tt.want) + "…"; summary.Description != want {
+			t.Errorf("SUMMARY_DESC_TRUNCATE=%q: Description is %d runes, want %d and an ellipsis", tt.limit, len([]rune(summary.Description)), tt.want)
+		}
+		if csv, err := CSV([]Win{win}); err != nil || !strings.Contains(string(csv), long) {
+			t.Errorf("CSV = %q, %v, want the full description exported", csv, err)
+		}
+	}
+}
+
+func TestSummaryMarkdown(t *testing.T) {
+	got := SummaryMarkdown([]WinSummary{
+		{Who: "Bob", Title: "Shipped *v2*", Description: "with `go test`\nand snake_case names"},
+		{Who: "_alice_", Title: "Fixed [the] build"},
+	})
+	want := "## WINs\n\n" +
+		"- **Bob**: Shipped \\*v2\\* — with \\`go test\\` and snake\\_case names\n" +
+		"- **\\_alice\\_**: Fixed \\[the\\] build\n"
+	if got != want {
+		t.Errorf("SummaryMarkdown =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkdownText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain words", "plain words"},
+		{"**bold**", `\*\*bold\*\*`},
+		{"_italic_", `\_italic\_`},
+		{"```code```", "\\`\\`\\`code\\`\\`\\`"},
+		{`back\slash`, `back\\slash`},
+		{"# heading | table ~strike~ <b>", `\# heading \| table \~strike\~ \<b\>`},
+	}
+	for _, tt := range tests {
+		if got := markdownText(tt.text); got != tt.want {
+			t.Errorf("markdownText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCategorySummaryBlocks(t *testing.T) {
+	wins := Summarize([]Win{
+		{WinID: "1", Who: "Alice", Title: "Customer call", Category: "Customer"},
+		{WinID: "2", Who: "Bob", Title: "Shipped v1", Category: "Shipping"},
+		{WinID: "3", Who: "Carol", Title: "Shipped v2", Category: "Shipping"},
+		{WinID: "4", Who: "Dan", Title: "Shipped v3", Category: "Shipping"},
+	})
+	blocks := BuildCategorySummaryBlocks("Summary (4)", wins)
+	got := []string{}
+	for _, block := range blocks[1 : len(blocks)-1] {
+		text := block["text"].(map[string]interface{})["text"].(string)
+		got = append(got, strings.SplitN(text, "\n", 2)[0])
+	}
+	want := []string{
+		"*Shipping* (3)",
+		"*Bob* — Shipped v1",
+		"*Carol* — Shipped v2",
+		"*Dan* — Shipped v3",
+		"*Customer* (1)",
+		"*Alice* — Customer call",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sections =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	footer := blocks[len(blocks)-1]["elements"].([]map[string]interface{})[0]["text"].(string)
+	if !strings.HasPrefix(footer, "4 WINS") {
+		t.Errorf("footer = %q, want the grand total", footer)
+	}
+}
+
+func TestScoreboard(t *testing.T) {
+	tests := []struct {
+		name string
+		show string
+		wins []Win
+		want string
+	}{
+		{"empty", "", nil, "0 WINS • 0 kudos"},
+		{"top contributor", "", []Win{
+			{WinID: "1", UserID: "U2", Kudos: 3},
+			{WinID: "2", UserID: "U1", Kudos: 1},
+			{WinID: "3", UserID: "U2", Kudos: 2},
+		}, "3 WINS • 6 kudos • top contributor: <@U2>"},
+		{"tie to lowest user ID", "", []Win{
+			{WinID: "1", UserID: "U2"},
+			{WinID: "2", UserID: "U1", Kudos: 4},
+		}, "2 WINS • 4 kudos • top contributor: <@U1>"},
+		{"all anonymous", "", []Win{
+			{WinID: "1", UserID: "U1", Kudos: 2, Anonymous: true},
+		}, "1 WINS • 2 kudos"},
+		{"SHOW_SUBMITTER off", "false", []Win{
+			{WinID: "1", UserID: "U1", Kudos: 1},
+		}, "1 WINS • 1 kudos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHOW_SUBMITTER", tt.show)
+			summary := Summarize(tt.wins)
+			if got := Scoreboard(summary); got != tt.want {
+				t.Errorf("Scoreboard = %q, want %q", got, tt.want)
+			}
+			blocks := BuildSummaryBlocks(summary)
+			footer := blocks[len(blocks)-1]["elements"].([]map[string]interface{})[0]["text"]
+			if footer != tt.want {
+				t.Errorf("summary footer = %q, want %q", footer, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupSummaries(t *testing.T) {
+	wins := Summarize([]Win{
+		{Who: "Bob", Title: "one", Category: "Ops"},
+		{Who: "Alice", Title: "two", Category: "Sales"},
+		{Who: "Bob", Title: "three", Category: "Sales"},
+		{Who: "Carol", Title: "four"},
+		{Who: "Alice", Title: "five", Category: "Sales"},
+		{Who: "Bob", Title: "six", Category: "Ops"},
+	})
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{"who", "Bob 3 [one three six], Alice 2 [two five], Carol 1 [four]"},
+		{"category", "Sales 3 [two three five], Ops 2 [one six], Uncategorized 1 [four]"},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, group := range GroupSummaries(wins, tt.by) {
+			got = append(got, fmt.Sprintf("%s %d %v", group.Name, group.Count, group.Titles))
+		}
+		if strings.Join(got, ", ") != tt.want {
+			t.Errorf("GroupSummaries by %s = %s, want %s", tt.by, strings.Join(got, ", "), tt.want)
+		}
+	}
+}
+
+func TestGroupText(t *testing.T) {
+	tests := []struct {
+		group Group
+		want  string
+	}{
+		{Group{Name: "Bob", Count: 2, Titles: []string{"one", "two"}}, "*Bob* (2): one; two"},
+		{Group{Name: "Ops", Count: 7, Titles: []string{"1", "2", "3", "4", "5", "6", "7"}}, "*Ops* (7): 1; 2; 3; 4; 5; and 2 more"},
+	}
+	for _, tt := range tests {
+		if got := groupText(tt.group); got != tt.want {
+			t.Errorf("groupText = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package kanowins
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// tableInput is the WINs table as serverless.yml provisions it, keyed by
+// win_id with the UserIndex of WINS by submitter and a NEW_IMAGE stream for
+// KanowinsStream
+func tableInput(table string) *dynamodb.CreateTableInput {
+	throughput := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(1),
+		WriteCapacityUnits: aws.Int64(1),
+	}
+	return &dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("win_id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("user_id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("created_at"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("win_id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(UserIndex),
+				KeySchema: []*dynamodb.KeySchemaElement{
+					{AttributeName: aws.String("user_id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+					{AttributeName: aws.String("created_at"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+				},
+				Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				ProvisionedThroughput: throughput,
+			},
+		},
+		ProvisionedThroughput: throughput,
+		StreamSpecification: &dynamodb.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: aws.String(dynamodb.StreamViewTypeNewImage),
+		},
+	}
+}
+
+// EnsureTable creates the WINs table with its TTL when it doesn't exist
+// yet, leaving an existing table as it is
+func (c *Client) EnsureTable() error {
+	_, err := c.db.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(c.table)})
+	if !IsMissingTable(err) {
+		return err
+	}
+	logging.Printf("kanowins.EnsureTable - creating missing table %s", c.table)
+	if _, err = c.db.CreateTable(tableInput(c.table)); err != nil {
+		return err
+	}
+	if err = c.db.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(c.table)}); err != nil {
+		return err
+	}
+	_, err = c.db.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(c.table),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String("ttl"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
@@ -0,0 +1,92 @@
+package kanowins
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// tableDB fakes the table management calls EnsureTable makes, the table
+// existing once created and the calls recorded in order
+type tableDB struct {
+	dynamoAPI
+	exists   bool
+	describe error
+	calls    []string
+	created  *dynamodb.CreateTableInput
+	ttl      *dynamodb.UpdateTimeToLiveInput
+}
+
+func (f *tableDB) DescribeTable(*dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	f.calls = append(f.calls, "DescribeTable")
+	if f.describe != nil {
+		return nil, f.describe
+	}
+	if !f.exists {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "table not found", nil)
+	}
+	return &dynamodb.DescribeTableOutput{}, nil
+}
+
+func (f *tableDB) CreateTable(in *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	f.calls = append(f.calls, "CreateTable")
+	f.created, f.exists = in, true
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (f *tableDB) WaitUntilTableExists(*dynamodb.DescribeTableInput) error {
+	f.calls = append(f.calls, "WaitUntilTableExists")
+	if !f.exists {
+		return errors.New("table never became active")
+	}
+	return nil
+}
+
+func (f *tableDB) UpdateTimeToLive(in *dynamodb.UpdateTimeToLiveInput) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	f.calls = append(f.calls, "UpdateTimeToLive")
+	f.ttl = in
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func TestEnsureTable(t *testing.T) {
+	db := &tableDB{}
+	client := &Client{db: db, table: "wins"}
+	if err := client.EnsureTable(); err != nil {
+		t.Fatalf("EnsureTable error: %v", err)
+	}
+	if got := strings.Join(db.calls, ","); got != "DescribeTable,CreateTable,WaitUntilTableExists,UpdateTimeToLive" {
+		t.Errorf("calls = %s, want the table created, waited for and given a TTL", got)
+	}
+	if key := db.created.KeySchema; len(key) != 1 || aws.StringValue(key[0].AttributeName) != "win_id" || aws.StringValue(key[0].KeyType) != dynamodb.KeyTypeHash {
+		t.Errorf("KeySchema = %v, want win_id as the hash key", key)
+	}
+	if aws.StringValue(db.created.TableName) != "wins" || aws.StringValue(db.created.GlobalSecondaryIndexes[0].IndexName) != UserIndex {
+		t.Errorf("created %s with index %s, want wins with %s", aws.StringValue(db.created.TableName), aws.StringValue(db.created.GlobalSecondaryIndexes[0].IndexName), UserIndex)
+	}
+	if spec := db.ttl.TimeToLiveSpecification; aws.StringValue(spec.AttributeName) != "ttl" || !aws.BoolValue(spec.Enabled) {
+		t.Errorf("TimeToLiveSpecification = %v, want ttl enabled", spec)
+	}
+
+	db.calls = nil
+	if err := client.EnsureTable(); err != nil {
+		t.Fatalf("second EnsureTable error: %v", err)
+	}
+	if got := strings.Join(db.calls, ","); got != "DescribeTable" {
+		t.Errorf("calls for an existing table = %s, want only DescribeTable", got)
+	}
+}
+
+func TestEnsureTableDescribeError(t *testing.T) {
+	denied := awserr.New("AccessDeniedException", "not allowed", nil)
+	db := &tableDB{describe: denied}
+	if err := (&Client{db: db, table: "wins"}).EnsureTable(); err != denied {
+		t.Errorf("EnsureTable error = %v, want %v", err, denied)
+	}
+	if len(db.calls) != 1 {
+		t.Errorf("calls = %v, want nothing created after a describe error", db.calls)
+	}
+}
@@ -0,0 +1,79 @@
+// Package logging writes JSON log lines carrying the handler, Lambda request
+// ID and Slack user
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// entry is a single JSON log line
+type entry struct {
+	Handler   string `json:"handler,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	TeamID    string `json:"team_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+var (
+	mu      sync.Mutex
+	out     io.Writer = os.Stderr
+	current entry
+)
+
+// Start resets the log context for a new invocation of handler, a Lambda
+// container serves one invocation at a time so the context is kept globally
+func Start(ctx context.Context, handler string) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = entry{Handler: handler}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		current.RequestID = lc.AwsRequestID
+	}
+}
+
+// SetUser adds the calling Slack user and team to subsequent log lines
+func SetUser(userID, teamID string) {
+	mu.Lock()
+	defer mu.Unlock()
+	current.UserID = userID
+	current.TeamID = teamID
+}
+
+// RequestID returns the Lambda request ID of the current invocation
+func RequestID() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return current.RequestID
+}
+
+// Printf writes a JSON log line with the formatted message
+func Printf(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	e := current
+	e.Message = fmt.Sprintf(format, args...)
+	line, _ := json.Marshal(e)
+	fmt.Fprintln(out, string(line))
+}
+
+// Recover, when deferred by a handler, logs a panic with its stack and calls
+// onPanic
+func Recover(onPanic func()) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	Printf("panic: %v\n%s", p, debug.Stack())
+	if onPanic != nil {
+		onPanic()
+	}
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var buf strings.Builder
+	defer func(w io.Writer) { out = w }(out)
+	out = &buf
+	Start(context.Background(), "KanowinsTest")
+	result := func() (result string) {
+		defer Recover(func() { result = "recovered" })
+		var wins []string
+		return wins[1]
+	}()
+	if result != "recovered" {
+		t.Errorf("result = %q, want onPanic's", result)
+	}
+	line := buf.String()
+	if !strings.Contains(line, `"handler":"KanowinsTest"`) || !strings.Contains(line, "panic: runtime error: index out of range") || !strings.Contains(line, "TestRecover") {
+		t.Errorf("log = %s, want the panic and its stack", line)
+	}
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	called := false
+	func() {
+		defer Recover(func() { called = true })
+	}()
+	if called {
+		t.Error("onPanic called without a panic")
+	}
+}
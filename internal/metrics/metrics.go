@@ -0,0 +1,116 @@
+// Package metrics counts KanoWINS usage as CloudWatch custom metrics when
+// METRICS_ENABLED is set
+package metrics
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// Namespace is the CloudWatch namespace all KanoWINS metrics are published to
+const Namespace = "KanoWINS"
+
+// maxDatums is the PutMetricData limit of metric data per call
+const maxDatums = 20
+
+// counter is a buffered count for one metric name and set of dimensions
+type counter struct {
+	name       string
+	dimensions map[string]string
+	value      float64
+}
+
+var (
+	mu       sync.Mutex
+	counters = map[string]*counter{}
+)
+
+// Enabled reports whether METRICS_ENABLED turns metrics on
+func Enabled() bool {
+	switch strings.ToLower(os.Getenv("METRICS_ENABLED")) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// key identifies a metric name and its dimensions within the buffer
+func key(name string, dimensions map[string]string) string {
+	parts := []string{name}
+	for dimension, value := range dimensions {
+		parts = append(parts, dimension+"="+value)
+	}
+	sort.Strings(parts[1:])
+	return strings.Join(parts, "|")
+}
+
+// Count adds one to the named metric with the given dimensions, nothing is
+// recorded while metrics are disabled
+func Count(name string, dimensions map[string]string) {
+	if !Enabled() {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	k := key(name, dimensions)
+	if c, ok := counters[k]; ok {
+		c.value++
+		return
+	}
+	counters[k] = &counter{name: name, dimensions: dimensions, value: 1}
+}
+
+// Flush publishes the buffered counts, a failure is logged rather than
+// returned since metrics must never fail a Slack request
+func Flush(ctx context.Context) {
+	mu.Lock()
+	pending := counters
+	counters = map[string]*counter{}
+	mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	data := []*cloudwatch.MetricDatum{}
+	for _, c := range pending {
+		datum := &cloudwatch.MetricDatum{
+			MetricName: aws.String(c.name),
+			Unit:       aws.String(cloudwatch.StandardUnitCount),
+			Value:      aws.Float64(c.value),
+		}
+		for dimension, value := range c.dimensions {
+			datum.Dimensions = append(datum.Dimensions, &cloudwatch.Dimension{
+				Name:  aws.String(dimension),
+				Value: aws.String(value),
+			})
+		}
+		data = append(data, datum)
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(os.Getenv("REGION"))})
+	if err != nil {
+		logging.Printf("metrics.Flush - NewSession error: %v", err)
+		return
+	}
+	svc := cloudwatch.New(sess)
+	for start := 0; start < len(data); start += maxDatums {
+		end := start + maxDatums
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err := svc.PutMetricDataWithContext(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(Namespace),
+			MetricData: data[start:end],
+		})
+		if err != nil {
+			logging.Printf("metrics.Flush - PutMetricData error: %v", err)
+		}
+	}
+}
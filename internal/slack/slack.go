@@ -0,0 +1,254 @@
+// Package slack holds helpers for calling the Slack Web API shared by all
+// KanoWINS handlers
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// DefaultAPIBase is the Slack Web API used when SLACK_API_BASE is unset
+const DefaultAPIBase = "https://slack.com/api"
+
+// Timeout bounds every outbound request to Slack, a slow Slack must not hold
+// the Lambda until its own timeout
+const Timeout = 5 * time.Second
+
+// HTTPClient is shared by all outbound requests to Slack, including posts to
+// response URLs and incoming webhooks
+var HTTPClient = &http.Client{Timeout: Timeout}
+
+// defaultRetryAfter is the wait before retrying a rate limited request that
+// came back without a Retry-After header
+const defaultRetryAfter = time.Second
+
+// Error is a Slack Web API call answered with `ok: false`, Code holds
+// Slack's error code such as `expired_trigger_id`
+type Error struct {
+	Method string
+	Code   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("slack %s failed: %s", e.Method, e.Code)
+}
+
+// ErrNoToken is a Web API call that wasn't attempted because neither the
+// team's SLACK_TEAM_CONFIG entry nor SLACK_ACCESS_TOKEN holds a token
+var ErrNoToken = errors.New("slack: no access token configured")
+
+// Endpoint returns the URL of a Slack Web API method such as `dialog.open`,
+// rooted at SLACK_API_BASE so tests or a proxy can stand in for Slack
+func Endpoint(method string) string {
+	base := os.Getenv("SLACK_API_BASE")
+	if base == "" {
+		base = DefaultAPIBase
+	}
+	return strings.TrimSuffix(base, "/") + "/" + method
+}
+
+// Call posts payload as JSON to a Slack Web API method using the bot token
+// of the team on ctx
+func Call(ctx context.Context, method string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return call(ctx, method, "application/json", body, nil)
+}
+
+// CallForm posts form values to a Slack Web API method using the bot token
+// of the team on ctx
+func CallForm(ctx context.Context, method string, form url.Values) error {
+	return call(ctx, method, "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// PostWebhook sends message to an incoming webhook, which replies with a
+// plain `ok` rather than JSON
+func PostWebhook(ctx context.Context, webhookURL string, message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	resp, err := Do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// PostMessage posts message with chat.postMessage using the bot token of
+// the team on ctx, returning the posted message's ts so replies can be
+// threaded under it
+func PostMessage(ctx context.Context, message interface{}) (string, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		TS string `json:"ts"`
+	}
+	err = call(ctx, "chat.postMessage", "application/json", body, &result)
+	return result.TS, err
+}
+
+// Permalink returns the permalink of the message with timestamp ts in
+// channel
+func Permalink(ctx context.Context, channel, ts string) (string, error) {
+	var result struct {
+		Permalink string `json:"permalink"`
+	}
+	form := url.Values{"channel": {channel}, "message_ts": {ts}}
+	err := call(ctx, "chat.getPermalink", "application/x-www-form-urlencoded", []byte(form.Encode()), &result)
+	return result.Permalink, err
+}
+
+// call posts body to a Slack Web API method, decoding a successful response
+// into result when it is not nil
+func call(ctx context.Context, method, contentType string, body []byte, result interface{}) error {
+	if Token(ctx) == "" {
+		logging.Printf("slack.call - %s not attempted: %v, set SLACK_ACCESS_TOKEN", method, ErrNoToken)
+		return ErrNoToken
+	}
+	resp, err := Do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", Endpoint(method), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+Token(ctx))
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// Slack's edge answers outages with an HTML error page rather than JSON,
+	// which would otherwise surface as a baffling decode error
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logging.Printf("slack.call - %s responded %s: %s", method, resp.Status, clipBody(raw))
+		return fmt.Errorf("slack %s responded %s", method, resp.Status)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.Contains(contentType, "json") {
+		logging.Printf("slack.call - %s responded with %q: %s", method, contentType, clipBody(raw))
+		return fmt.Errorf("slack %s responded with %q rather than JSON", method, contentType)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err = json.Unmarshal(raw, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return &Error{Method: method, Code: status.Error}
+	}
+	if result != nil {
+		return json.Unmarshal(raw, result)
+	}
+	return nil
+}
+
+// Do sends the request built by newRequest with HTTPClient, retrying once
+// after the Retry-After delay when Slack answers 429 Too Many Requests. The
+// 429 is returned as is when the wait and retry wouldn't fit before ctx's
+// deadline, the rest of the Lambda's time.
+func Do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+	wait := retryAfter(resp.Header.Get("Retry-After"))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait+Timeout {
+		logging.Printf("slack.Do - rate limited, no time left to retry after %s", wait)
+		return resp, nil
+	}
+	resp.Body.Close()
+	logging.Printf("slack.Do - rate limited, retrying after %s", wait)
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if req, err = newRequest(); err != nil {
+		return nil, err
+	}
+	return HTTPClient.Do(req)
+}
+
+// retryAfter parses a Retry-After header given in seconds, falling back to
+// defaultRetryAfter when it is missing or invalid
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return defaultRetryAfter
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// maxLoggedBody caps how much of an unexpected Slack response is logged
+const maxLoggedBody = 512
+
+// clipBody returns at most maxLoggedBody bytes of body for a log line
+func clipBody(body []byte) string {
+	if len(body) > maxLoggedBody {
+		return string(body[:maxLoggedBody]) + "…"
+	}
+	return string(body)
+}
+
+// SSLCheck reports whether body is one of the `ssl_check=1` pings Slack
+// sends to verify a request URL's certificate, which only needs a 200
+func SSLCheck(body string) bool {
+	query, err := url.ParseQuery(body)
+	return err == nil && query.Get("ssl_check") == "1"
+}
+
+// URLVerification returns the challenge of an Events API style
+// `url_verification` body, which Slack expects echoed back
+//
+// https://api.slack.com/events/url_verification
+func URLVerification(body string) (string, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(body), "{") {
+		return "", false
+	}
+	var event struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal([]byte(body), &event); err != nil || event.Type != "url_verification" {
+		return "", false
+	}
+	return event.Challenge, true
+}
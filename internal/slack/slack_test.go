@@ -0,0 +1,197 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowSlack(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+	if HTTPClient.Timeout != Timeout {
+		t.Errorf("HTTPClient timeout = %s, want %s", HTTPClient.Timeout, Timeout)
+	}
+
+	t.Run("client timeout", func(t *testing.T) {
+		defer func(client *http.Client) { HTTPClient = client }(HTTPClient)
+		HTTPClient = &http.Client{Timeout: 50 * time.Millisecond}
+		start := time.Now()
+		if err := Call(context.Background(), "chat.postMessage", map[string]string{}); err == nil {
+			t.Error("Call to a hung Slack succeeded")
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Errorf("Call took %s to give up", took)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := PostWebhook(ctx, server.URL, map[string]string{"text": "hi"}); err == nil {
+			t.Error("PostWebhook to a hung Slack succeeded")
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Errorf("PostWebhook took %s to give up", took)
+		}
+	})
+}
+
+func TestRateLimitedRetry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	t.Run("retried", func(t *testing.T) {
+		calls = 0
+		if err := PostWebhook(context.Background(), server.URL, map[string]string{"text": "hi"}); err != nil {
+			t.Errorf("PostWebhook error: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("Slack called %d times, want 2", calls)
+		}
+	})
+
+	t.Run("no time left", func(t *testing.T) {
+		calls = 0
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := PostWebhook(ctx, server.URL, map[string]string{"text": "hi"}); err == nil {
+			t.Error("PostWebhook succeeded without retrying")
+		}
+		if calls != 1 {
+			t.Errorf("Slack called %d times, want 1", calls)
+		}
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"3", 3 * time.Second},
+		{"", defaultRetryAfter},
+		{"0", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCallWithoutToken(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "")
+	if _, err := PostMessage(context.Background(), map[string]string{"channel": "C1", "text": "hi"}); err != ErrNoToken {
+		t.Errorf("PostMessage error = %v, want ErrNoToken", err)
+	}
+	if calls != 0 {
+		t.Errorf("%d HTTP calls made without a token, want none", calls)
+	}
+}
+
+func TestSSLCheck(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"ssl_check=1&token=legacy", true},
+		{"ssl_check=0", false},
+		{"command=%2Fwins&text=hi", false},
+		{`{"type": "url_verification"}`, false},
+	}
+	for _, tt := range tests {
+		if got := SSLCheck(tt.body); got != tt.want {
+			t.Errorf("SSLCheck(%q) = %t, want %t", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestURLVerification(t *testing.T) {
+	tests := []struct {
+		body   string
+		want   string
+		wantOK bool
+	}{
+		{`{"token": "legacy", "challenge": "abc123", "type": "url_verification"}`, "abc123", true},
+		{` {"type": "event_callback", "challenge": "abc123"}`, "", false},
+		{`{"type": "url_verification"`, "", false},
+		{"payload=%7B%22type%22%3A%22url_verification%22%7D", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := URLVerification(tt.body); got != tt.want || ok != tt.wantOK {
+			t.Errorf("URLVerification(%q) = %q, %t, want %q, %t", tt.body, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCallNonJSONResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		want        string
+	}{
+		{"HTML 500", 500, "text/html", "slack chat.postMessage responded 500 Internal Server Error"},
+		{"HTML 200", 200, "text/html; charset=utf-8", `slack chat.postMessage responded with "text/html; charset=utf-8" rather than JSON`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte("<html><body><h1>Internal Server Error</h1></body></html>"))
+			}))
+			defer server.Close()
+			t.Setenv("SLACK_API_BASE", server.URL)
+			t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+			_, err := PostMessage(context.Background(), map[string]string{"channel": "C1", "text": "hi"})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("PostMessage error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClipBody(t *testing.T) {
+	long := make([]byte, maxLoggedBody+10)
+	for i := range long {
+		long[i] = 'x'
+	}
+	if got := clipBody(long); len(got) != maxLoggedBody+len("…") {
+		t.Errorf("clipBody kept %d bytes, want %d and an ellipsis", len(got), maxLoggedBody)
+	}
+	if got := clipBody([]byte("short")); got != "short" {
+		t.Errorf("clipBody(short) = %q, want it as is", got)
+	}
+}
@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+// TeamConfig is the configuration for one Slack workspace the app is
+// installed in
+type TeamConfig struct {
+	AccessToken       string `json:"access_token"`
+	Channel           string `json:"channel"`
+	StreamChannel     string `json:"stream_channel"`
+	SummaryChannel    string `json:"summary_channel"`
+	DialogTitle       string `json:"dialog_title"`
+	DialogSubmitLabel string `json:"dialog_submit_label"`
+
+	// EmailRecipients are who the workspace's weekly digest is emailed to
+	EmailRecipients []string `json:"email_recipients"`
+}
+
+type teamKey struct{}
+
+// Teams returns the per-workspace configuration from SLACK_TEAM_CONFIG, a
+// JSON object keyed by team ID such as
+// `{"T012AB3C4": {"access_token": "xoxb-...", "channel": "C0123"}}`
+func Teams() map[string]TeamConfig {
+	teams := map[string]TeamConfig{}
+	value := os.Getenv("SLACK_TEAM_CONFIG")
+	if value == "" {
+		return teams
+	}
+	if err := json.Unmarshal([]byte(value), &teams); err != nil {
+		logging.Printf("slack.Teams - invalid SLACK_TEAM_CONFIG: %v", err)
+		return map[string]TeamConfig{}
+	}
+	return teams
+}
+
+// ConfigFor returns the configuration for teamID, falling back to the
+// SLACK_ACCESS_TOKEN default for any workspace without its own entry
+func ConfigFor(teamID string) TeamConfig {
+	config := Teams()[teamID]
+	if config.AccessToken == "" {
+		config.AccessToken = os.Getenv("SLACK_ACCESS_TOKEN")
+	}
+	return config
+}
+
+// WithTeam returns a copy of ctx whose Slack calls are made with teamID's
+// access token
+func WithTeam(ctx context.Context, teamID string) context.Context {
+	return context.WithValue(ctx, teamKey{}, ConfigFor(teamID))
+}
+
+// Token returns the access token for the team set on ctx by WithTeam, or the
+// SLACK_ACCESS_TOKEN default
+func Token(ctx context.Context) string {
+	if config, ok := ctx.Value(teamKey{}).(TeamConfig); ok && config.AccessToken != "" {
+		return config.AccessToken
+	}
+	return os.Getenv("SLACK_ACCESS_TOKEN")
+}
@@ -0,0 +1,100 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/logging"
+)
+
+const (
+	// maxLookups bounds how many users.info calls DisplayNames makes at once
+	maxLookups = 5
+
+	// lookupTimeout bounds each users.info call
+	lookupTimeout = 2 * time.Second
+)
+
+var (
+	namesMu sync.Mutex
+	names   = map[string]string{}
+)
+
+// DisplayNames looks up the current Slack display names of userIDs with
+// users.info, at most maxLookups at a time, caching them for the Lambda's
+// lifetime. Users whose lookup fails are left out so callers fall back to
+// the name stored with the WIN
+func DisplayNames(ctx context.Context, userIDs []string) map[string]string {
+	found := map[string]string{}
+	pending := []string{}
+	namesMu.Lock()
+	for _, userID := range userIDs {
+		if _, seen := found[userID]; seen || userID == "" {
+			continue
+		}
+		if name, ok := names[userID]; ok {
+			found[userID] = name
+			continue
+		}
+		found[userID] = ""
+		pending = append(pending, userID)
+	}
+	namesMu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxLookups)
+	for _, userID := range pending {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(userID string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			name, err := displayName(ctx, userID)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || name == "" {
+				logging.Printf("slack.DisplayNames - users.info %s error: %v", userID, err)
+				delete(found, userID)
+				return
+			}
+			found[userID] = name
+		}(userID)
+	}
+	wg.Wait()
+
+	namesMu.Lock()
+	for userID, name := range found {
+		names[userID] = name
+	}
+	namesMu.Unlock()
+	return found
+}
+
+// displayName returns the display name of userID, or their real name or
+// username when no display name is set
+func displayName(ctx context.Context, userID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	var result struct {
+		User struct {
+			Name    string `json:"name"`
+			Profile struct {
+				DisplayName string `json:"display_name"`
+				RealName    string `json:"real_name"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	form := url.Values{"user": {userID}}
+	if err := call(ctx, "users.info", "application/x-www-form-urlencoded", []byte(form.Encode()), &result); err != nil {
+		return "", err
+	}
+	for _, name := range []string{result.User.Profile.DisplayName, result.User.Profile.RealName, result.User.Name} {
+		if name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,63 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDisplayNames(t *testing.T) {
+	names = map[string]string{}
+	var mu sync.Mutex
+	inFlight, most, calls := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		inFlight++
+		if inFlight > most {
+			most = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		user := r.FormValue("user")
+		w.Header().Set("Content-Type", "application/json")
+		if user == "U7" {
+			fmt.Fprint(w, `{"ok": false, "error": "user_not_found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok": true, "user": {"name": "user%s", "profile": {"display_name": "Name %s"}}}`, user, user)
+	}))
+	defer server.Close()
+	t.Setenv("SLACK_API_BASE", server.URL)
+	t.Setenv("SLACK_ACCESS_TOKEN", "xoxb-test")
+
+	userIDs := []string{}
+	for i := 1; i <= 12; i++ {
+		userIDs = append(userIDs, fmt.Sprintf("U%d", i))
+	}
+	got := DisplayNames(context.Background(), append(userIDs, "U1", ""))
+	if most > maxLookups || most < 2 {
+		t.Errorf("%d users.info calls at once, want up to %d in parallel", most, maxLookups)
+	}
+	if calls != len(userIDs) {
+		t.Errorf("%d users.info calls, want one per user: %d", calls, len(userIDs))
+	}
+	if len(got) != len(userIDs)-1 || got["U3"] != "Name U3" {
+		t.Errorf("DisplayNames = %v, want every user but U7", got)
+	}
+	if _, ok := got["U7"]; ok {
+		t.Errorf("DisplayNames has %q for a failed lookup, want it left out", got["U7"])
+	}
+
+	calls = 0
+	if got := DisplayNames(context.Background(), []string{"U3"}); got["U3"] != "Name U3" || calls != 0 {
+		t.Errorf("cached DisplayNames = %v after %d calls, want Name U3 with none", got, calls)
+	}
+}
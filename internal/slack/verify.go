@@ -0,0 +1,43 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/gateway"
+)
+
+// MaxRequestAge bounds how old a signed Slack request may be before it is
+// rejected as a possible replay
+const MaxRequestAge = 5 * time.Minute
+
+// VerifyRequest checks the Slack request signature against
+// SLACK_SIGNING_SECRET
+//
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func VerifyRequest(r gateway.Request) error {
+	timestamp := r.Header("X-Slack-Request-Timestamp")
+	signature := r.Header("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("missing request signature")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid request timestamp")
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return errors.New("stale request timestamp")
+	}
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SLACK_SIGNING_SECRET")))
+	mac.Write([]byte("v0:" + timestamp + ":" + r.Body))
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid request signature")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anzellai/kanowins/internal/gateway"
+)
+
+// sign returns the Slack signature of body sent at ts with secret
+func sign(secret, ts, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	body := "command=%2Fwins&text=help"
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-MaxRequestAge-time.Minute).Unix(), 10)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantErr bool
+	}{
+		{"signed", map[string]string{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": sign("secret", now, body)}, false},
+		{"lower case headers", map[string]string{"x-slack-request-timestamp": now, "x-slack-signature": sign("secret", now, body)}, false},
+		{"unsigned", map[string]string{}, true},
+		{"wrong secret", map[string]string{"X-Slack-Request-Timestamp": now, "X-Slack-Signature": sign("other", now, body)}, true},
+		{"stale", map[string]string{"X-Slack-Request-Timestamp": stale, "X-Slack-Signature": sign("secret", stale, body)}, true},
+		{"bad timestamp", map[string]string{"X-Slack-Request-Timestamp": "soon", "X-Slack-Signature": sign("secret", "soon", body)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyRequest(gateway.Request{Headers: tt.headers, Body: body})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyRequest error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}